@@ -0,0 +1,49 @@
+package observe
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"main.go/global"
+	"main.go/model/common/response"
+	observe "main.go/model/observe"
+)
+
+type AdmissionAuditApi struct {
+}
+
+// GetAdmissionAudit 根据ID获取准入审计记录
+func (m *AdmissionAuditApi) GetAdmissionAudit(c *gin.Context) {
+	idStr := c.Param("auditId")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		response.FailWithMessage("参数错误", c)
+		return
+	}
+
+	if err, audit := admissionAuditService.GetAdmissionAudit(id); err != nil {
+		global.GVA_LOG.Error("查询失败!", zap.Error(err))
+		response.FailWithMessage("查询失败", c)
+	} else {
+		response.OkWithData(audit, c)
+	}
+}
+
+// GetAdmissionAuditList 分页获取准入审计记录, 支持按集群、命名空间、工作负载、容器、决策、跳过原因和时间范围过滤
+func (m *AdmissionAuditApi) GetAdmissionAuditList(c *gin.Context) {
+	var search observe.AdmissionAuditSearch
+	_ = c.ShouldBindQuery(&search)
+
+	if err, list, total := admissionAuditService.GetAdmissionAuditList(search); err != nil {
+		global.GVA_LOG.Error("获取失败!", zap.Error(err))
+		response.FailWithMessage("获取失败: "+err.Error(), c)
+	} else {
+		response.OkWithDetailed(response.PageResult{
+			List:       list,
+			TotalCount: total,
+			CurrPage:   search.PageNumber,
+			PageSize:   search.PageSize,
+		}, "获取成功", c)
+	}
+}
@@ -0,0 +1,110 @@
+package observe
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"main.go/global"
+	"main.go/model/common/response"
+	observe "main.go/model/observe"
+)
+
+type AlertRouteApi struct {
+}
+
+// CreateRoute 创建告警路由
+func (m *AlertRouteApi) CreateRoute(c *gin.Context) {
+	var req observe.AlertRouteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.FailWithMessage("参数错误: "+err.Error(), c)
+		return
+	}
+
+	if err, route := alertRouteService.CreateRoute(req); err != nil {
+		global.GVA_LOG.Error("创建失败!", zap.Error(err))
+		response.FailWithMessage("创建失败: "+err.Error(), c)
+	} else {
+		response.OkWithData(route, c)
+	}
+}
+
+// UpdateRoute 更新告警路由
+func (m *AlertRouteApi) UpdateRoute(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("routeId"))
+	if err != nil {
+		response.FailWithMessage("参数错误", c)
+		return
+	}
+
+	var req observe.AlertRouteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.FailWithMessage("参数错误: "+err.Error(), c)
+		return
+	}
+
+	if err := alertRouteService.UpdateRoute(id, req); err != nil {
+		global.GVA_LOG.Error("更新失败!", zap.Error(err))
+		response.FailWithMessage("更新失败: "+err.Error(), c)
+	} else {
+		response.OkWithMessage("更新成功", c)
+	}
+}
+
+// DeleteRoute 删除告警路由
+func (m *AlertRouteApi) DeleteRoute(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("routeId"))
+	if err != nil {
+		response.FailWithMessage("参数错误", c)
+		return
+	}
+
+	if err := alertRouteService.DeleteRoute(id); err != nil {
+		global.GVA_LOG.Error("删除失败!", zap.Error(err))
+		response.FailWithMessage("删除失败: "+err.Error(), c)
+	} else {
+		response.OkWithMessage("删除成功", c)
+	}
+}
+
+// GetRoute 根据ID获取告警路由
+func (m *AlertRouteApi) GetRoute(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("routeId"))
+	if err != nil {
+		response.FailWithMessage("参数错误", c)
+		return
+	}
+
+	if err, route := alertRouteService.GetRoute(id); err != nil {
+		global.GVA_LOG.Error("查询失败!", zap.Error(err))
+		response.FailWithMessage("查询失败", c)
+	} else {
+		response.OkWithData(route, c)
+	}
+}
+
+// GetRouteTree 获取告警路由树
+func (m *AlertRouteApi) GetRouteTree(c *gin.Context) {
+	if err, tree := alertRouteService.GetRouteTree(); err != nil {
+		global.GVA_LOG.Error("获取失败!", zap.Error(err))
+		response.FailWithMessage("获取失败", c)
+	} else {
+		response.OkWithData(tree, c)
+	}
+}
+
+// TestRoute 返回给定标签的告警会发往的路由
+func (m *AlertRouteApi) TestRoute(c *gin.Context) {
+	var req observe.AlertRouteTestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.FailWithMessage("参数错误: "+err.Error(), c)
+		return
+	}
+
+	if err, targets := alertRouteService.TestRoute(req.Labels); err != nil {
+		global.GVA_LOG.Error("路由测试失败!", zap.Error(err))
+		response.FailWithMessage("路由测试失败: "+err.Error(), c)
+	} else {
+		response.OkWithData(targets, c)
+	}
+}
@@ -0,0 +1,102 @@
+package observe
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"main.go/global"
+	"main.go/model/common/response"
+	observe "main.go/model/observe"
+)
+
+type AlertSilenceApi struct {
+}
+
+// CreateSilence 创建静默
+func (m *AlertSilenceApi) CreateSilence(c *gin.Context) {
+	var req observe.AlertSilenceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.FailWithMessage("参数错误: "+err.Error(), c)
+		return
+	}
+
+	if err, silence := alertSilenceService.CreateSilence(req); err != nil {
+		global.GVA_LOG.Error("创建失败!", zap.Error(err))
+		response.FailWithMessage("创建失败: "+err.Error(), c)
+	} else {
+		response.OkWithData(silence, c)
+	}
+}
+
+// UpdateSilence 更新静默
+func (m *AlertSilenceApi) UpdateSilence(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("silenceId"))
+	if err != nil {
+		response.FailWithMessage("参数错误", c)
+		return
+	}
+
+	var req observe.AlertSilenceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.FailWithMessage("参数错误: "+err.Error(), c)
+		return
+	}
+
+	if err := alertSilenceService.UpdateSilence(id, req); err != nil {
+		global.GVA_LOG.Error("更新失败!", zap.Error(err))
+		response.FailWithMessage("更新失败: "+err.Error(), c)
+	} else {
+		response.OkWithMessage("更新成功", c)
+	}
+}
+
+// DeleteSilence 删除静默
+func (m *AlertSilenceApi) DeleteSilence(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("silenceId"))
+	if err != nil {
+		response.FailWithMessage("参数错误", c)
+		return
+	}
+
+	if err := alertSilenceService.DeleteSilence(id); err != nil {
+		global.GVA_LOG.Error("删除失败!", zap.Error(err))
+		response.FailWithMessage("删除失败", c)
+	} else {
+		response.OkWithMessage("删除成功", c)
+	}
+}
+
+// GetSilence 根据ID获取静默
+func (m *AlertSilenceApi) GetSilence(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("silenceId"))
+	if err != nil {
+		response.FailWithMessage("参数错误", c)
+		return
+	}
+
+	if err, silence := alertSilenceService.GetSilence(id); err != nil {
+		global.GVA_LOG.Error("查询失败!", zap.Error(err))
+		response.FailWithMessage("查询失败", c)
+	} else {
+		response.OkWithData(silence, c)
+	}
+}
+
+// GetSilenceList 分页获取静默列表
+func (m *AlertSilenceApi) GetSilenceList(c *gin.Context) {
+	var search observe.AlertSilenceSearch
+	_ = c.ShouldBindQuery(&search)
+
+	if err, list, total := alertSilenceService.GetSilenceList(search); err != nil {
+		global.GVA_LOG.Error("获取失败!", zap.Error(err))
+		response.FailWithMessage("获取失败", c)
+	} else {
+		response.OkWithDetailed(response.PageResult{
+			List:       list,
+			TotalCount: total,
+			CurrPage:   search.PageNumber,
+			PageSize:   search.PageSize,
+		}, "获取成功", c)
+	}
+}
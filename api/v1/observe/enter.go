@@ -4,6 +4,14 @@ import "main.go/service"
 
 type ObserveGroup struct {
 	ObserveAlertApi
+	AdmissionAuditApi
+	AlertRouteApi
+	NotifyOutboxApi
+	AlertSilenceApi
 }
 
 var observeService = service.ServiceGroupApp.ObserveServiceGroup.ObserveAlertService
+var admissionAuditService = service.ServiceGroupApp.ObserveServiceGroup.AdmissionAuditService
+var alertRouteService = service.ServiceGroupApp.ObserveServiceGroup.AlertRouteService
+var notifyOutboxService = service.ServiceGroupApp.ObserveServiceGroup.NotifyOutboxService
+var alertSilenceService = service.ServiceGroupApp.ObserveServiceGroup.AlertSilenceService
@@ -0,0 +1,77 @@
+package observe
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"main.go/global"
+	"main.go/model/common/response"
+	observe "main.go/model/observe"
+)
+
+type NotifyOutboxApi struct {
+}
+
+// GetNotification 根据ID获取通知发件箱记录
+func (m *NotifyOutboxApi) GetNotification(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("outboxId"), 10, 64)
+	if err != nil {
+		response.FailWithMessage("参数错误", c)
+		return
+	}
+
+	if err, row := notifyOutboxService.GetNotification(id); err != nil {
+		global.GVA_LOG.Error("查询失败!", zap.Error(err))
+		response.FailWithMessage("查询失败", c)
+	} else {
+		response.OkWithData(row, c)
+	}
+}
+
+// GetNotificationList 分页获取通知发件箱记录, 可按 alertId、channel、status 过滤
+func (m *NotifyOutboxApi) GetNotificationList(c *gin.Context) {
+	var search observe.NotifyOutboxSearch
+	_ = c.ShouldBindQuery(&search)
+
+	if err, list, total := notifyOutboxService.GetNotificationList(search); err != nil {
+		global.GVA_LOG.Error("获取失败!", zap.Error(err))
+		response.FailWithMessage("获取失败", c)
+	} else {
+		response.OkWithDetailed(response.PageResult{
+			List:       list,
+			TotalCount: total,
+			CurrPage:   search.PageNumber,
+			PageSize:   search.PageSize,
+		}, "获取成功", c)
+	}
+}
+
+// RetryNotification 重新投递一条 dead 状态的通知
+func (m *NotifyOutboxApi) RetryNotification(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("outboxId"), 10, 64)
+	if err != nil {
+		response.FailWithMessage("参数错误", c)
+		return
+	}
+	retryNotifications([]int64{id}, c)
+}
+
+// RetryNotificationBatch 批量重新投递 dead 状态的通知
+func (m *NotifyOutboxApi) RetryNotificationBatch(c *gin.Context) {
+	var ids observe.NotifyOutboxIdsReq
+	if err := c.ShouldBindJSON(&ids); err != nil {
+		response.FailWithMessage("参数错误", c)
+		return
+	}
+	retryNotifications(ids.Ids, c)
+}
+
+func retryNotifications(ids []int64, c *gin.Context) {
+	if err, retried := notifyOutboxService.RetryNotifications(ids); err != nil {
+		global.GVA_LOG.Error("重新投递失败!", zap.Error(err))
+		response.FailWithMessage("重新投递失败: "+err.Error(), c)
+	} else {
+		response.OkWithDetailed(gin.H{"retried": retried}, "重新投递成功", c)
+	}
+}
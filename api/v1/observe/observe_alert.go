@@ -3,7 +3,9 @@ package observe
 import (
 	"bytes"
 	"io"
+	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -11,6 +13,7 @@ import (
 	"main.go/model/common/request"
 	"main.go/model/common/response"
 	observe "main.go/model/observe"
+	serviceObserve "main.go/service/observe"
 )
 
 type ObserveAlertApi struct {
@@ -57,6 +60,40 @@ func (m *ObserveAlertApi) CreateAlert(c *gin.Context) {
 	}
 }
 
+// ReceiveAlertmanagerWebhook 接收 Alertmanager webhook(version 4) 推送的分组告警
+func (m *ObserveAlertApi) ReceiveAlertmanagerWebhook(c *gin.Context) {
+	var msg observe.AlertmanagerWebhook
+	if err := c.ShouldBindJSON(&msg); err != nil {
+		global.GVA_LOG.Error("Alertmanager webhook 参数绑定失败", zap.Error(err))
+		// 4xx 不会被 Alertmanager 重试
+		c.JSON(http.StatusBadRequest, response.Response{ResultCode: response.ERROR, Data: map[string]interface{}{}, Msg: "参数错误: " + err.Error()})
+		return
+	}
+	createAlertBatch(serviceObserve.AlertRequestsFromWebhook(msg, time.Now()), c)
+}
+
+// PostAlertsV2 兼容 Alertmanager 的 POST /api/v2/alerts, Prometheus 可直接推送告警
+func (m *ObserveAlertApi) PostAlertsV2(c *gin.Context) {
+	var alerts []observe.AlertmanagerAlert
+	if err := c.ShouldBindJSON(&alerts); err != nil {
+		global.GVA_LOG.Error("/api/v2/alerts 参数绑定失败", zap.Error(err))
+		c.JSON(http.StatusBadRequest, response.Response{ResultCode: response.ERROR, Data: map[string]interface{}{}, Msg: "参数错误: " + err.Error()})
+		return
+	}
+	createAlertBatch(serviceObserve.AlertRequestsFromPostable(alerts, time.Now()), c)
+}
+
+// createAlertBatch 批量写入告警并返回每条告警的处理结果
+// 整批写入失败时返回 500, 以便发送方重试
+func createAlertBatch(reqs []observe.AlertRequest, c *gin.Context) {
+	if err, results := observeService.CreateAlertBatch(reqs); err != nil {
+		global.GVA_LOG.Error("批量创建告警失败!", zap.Error(err), zap.Int("alerts", len(reqs)))
+		c.JSON(http.StatusInternalServerError, response.Response{ResultCode: response.ERROR, Data: map[string]interface{}{}, Msg: "创建失败: " + err.Error()})
+	} else {
+		response.OkWithData(results, c)
+	}
+}
+
 // DeleteAlert 删除告警
 func (m *ObserveAlertApi) DeleteAlert(c *gin.Context) {
 	idStr := c.Param("alertId")
@@ -2,15 +2,20 @@ package webhook
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
+	"log"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	admissionv1 "k8s.io/api/admission/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
+	modelWebhook "main.go/model/webhook"
 	"main.go/service"
+	serviceWebhook "main.go/service/webhook"
 )
 
 type RecommendationApi struct{}
@@ -18,6 +23,10 @@ type RecommendationApi struct{}
 var recommendationService = &service.ServiceGroupApp.WebhookServiceGroup.RecommendationService
 
 func (r *RecommendationApi) ServeMutate(c *gin.Context) {
+	start := time.Now()
+	outcome := "error"
+	defer func() { recommendationService.ObserveAdmission(outcome, time.Since(start)) }()
+
 	body, err := io.ReadAll(c.Request.Body)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, "could not read request")
@@ -37,15 +46,31 @@ func (r *RecommendationApi) ServeMutate(c *gin.Context) {
 		return
 	}
 
-	patches, _ := recommendationService.MutatePod(&pod, req.Namespace)
+	// Member clusters post to /mutate/{clusterId}; plain /mutate is the cluster of system.cluster-id.
+	// A server-side dry run gets the patch but must not cause side effects such as HPA writes.
+	dryRun := req.DryRun != nil && *req.DryRun
+	result, err := mutatePodSafely(c.Param("clusterId"), &pod, req.Namespace, dryRun)
+	if err != nil {
+		// Never block pod creation on a webhook failure; admit the pod unchanged.
+		log.Printf("Mutating pod %s in %s failed, admitting unchanged: %v", pod.GenerateName, req.Namespace, err)
+		result = &modelWebhook.MutationResult{SkipReason: serviceWebhook.SkipReasonError, DryRun: dryRun}
+	}
+	recommendationService.RecordAdmission(string(req.UID), &pod, req.Namespace, result)
+	if len(result.Patches) > 0 {
+		outcome = "patched"
+	} else {
+		outcome = "skipped"
+	}
 
 	admissionResponse := &admissionv1.AdmissionResponse{
-		UID:     req.UID,
-		Allowed: true,
+		UID:              req.UID,
+		Allowed:          true,
+		Warnings:         result.Warnings,
+		AuditAnnotations: result.AuditAnnotations,
 	}
 
-	if len(patches) > 0 {
-		patchBytes, _ := json.Marshal(patches)
+	if len(result.Patches) > 0 {
+		patchBytes, _ := json.Marshal(result.Patches)
 		admissionResponse.Patch = patchBytes
 		patchType := admissionv1.PatchTypeJSONPatch
 		admissionResponse.PatchType = &patchType
@@ -58,3 +83,33 @@ func (r *RecommendationApi) ServeMutate(c *gin.Context) {
 
 	c.JSON(http.StatusOK, admissionReviewResp)
 }
+
+// mutatePodSafely turns a panic in MutatePod into an error so the pod is still admitted.
+func mutatePodSafely(clusterID string, pod *corev1.Pod, namespace string, dryRun bool) (result *modelWebhook.MutationResult, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return recommendationService.MutatePod(clusterID, pod, namespace, dryRun)
+}
+
+// Readyz reports ready once the informer caches have synced.
+func (r *RecommendationApi) Readyz(c *gin.Context) {
+	ready, reason := recommendationService.Ready()
+	if !ready {
+		c.String(http.StatusServiceUnavailable, reason)
+		return
+	}
+	c.String(http.StatusOK, reason)
+}
+
+// Livez reports whether the informer watches are healthy.
+func (r *RecommendationApi) Livez(c *gin.Context) {
+	live, reason := recommendationService.Live()
+	if !live {
+		c.String(http.StatusServiceUnavailable, reason)
+		return
+	}
+	c.String(http.StatusOK, reason)
+}
@@ -9,6 +9,10 @@ type Server struct {
 	Local Local `mapstructure:"local" json:"local" yaml:"local"`
 	// mq
 	MQ MQ `mapstructure:"mq" json:"mq" yaml:"mq"`
+	// 告警通知渠道
+	Notify Notify `mapstructure:"notify" json:"notify" yaml:"notify"`
 	// k8s
 	K8s K8s `mapstructure:"k8s" json:"k8s" yaml:"k8s"`
+	// webhook
+	Webhook Webhook `mapstructure:"webhook" json:"webhook" yaml:"webhook"`
 }
@@ -0,0 +1,52 @@
+package config
+
+import "time"
+
+type Notify struct {
+	Channels []string      `mapstructure:"channels" json:"channels" yaml:"channels"` // 启用的通知渠道: mq|webhook|smtp|wecom|dingtalk|feishu, 为空时仅 mq
+	Webhook  NotifyWebhook `mapstructure:"webhook" json:"webhook" yaml:"webhook"`    // 通用 HTTP webhook
+	Smtp     NotifySmtp    `mapstructure:"smtp" json:"smtp" yaml:"smtp"`             // SMTP 邮件
+	Wecom    NotifyBot     `mapstructure:"wecom" json:"wecom" yaml:"wecom"`          // 企业微信群机器人
+	Dingtalk NotifyBot     `mapstructure:"dingtalk" json:"dingtalk" yaml:"dingtalk"` // 钉钉群机器人
+	Feishu   NotifyBot     `mapstructure:"feishu" json:"feishu" yaml:"feishu"`       // 飞书群机器人
+	Outbox   NotifyOutbox  `mapstructure:"outbox" json:"outbox" yaml:"outbox"`       // 通知发件箱与重试
+}
+
+// NotifyOutbox 通知先写入发件箱, 由后台工作协程发送, 失败按指数退避重试
+type NotifyOutbox struct {
+	Workers      int           `mapstructure:"workers" json:"workers" yaml:"workers"`                  // 发送协程数, 默认 4
+	BatchSize    int           `mapstructure:"batch-size" json:"batchSize" yaml:"batch-size"`          // 每次领取的记录数, 默认 100
+	PollInterval time.Duration `mapstructure:"poll-interval" json:"pollInterval" yaml:"poll-interval"` // 轮询到期记录的间隔, 默认 5s
+	MaxAttempts  int           `mapstructure:"max-attempts" json:"maxAttempts" yaml:"max-attempts"`    // 最大尝试次数, 超过后进入 dead 状态, 默认 8
+	RetryBackoff time.Duration `mapstructure:"retry-backoff" json:"retryBackoff" yaml:"retry-backoff"` // 首次重试间隔, 之后每次翻倍, 默认 10s
+	MaxBackoff   time.Duration `mapstructure:"max-backoff" json:"maxBackoff" yaml:"max-backoff"`       // 重试间隔上限, 默认 10m
+	Lease        time.Duration `mapstructure:"lease" json:"lease" yaml:"lease"`                        // 领取租约, 发送中的记录超过租约未完成时重新发送, 默认 2m
+}
+
+// NotifyWebhook 通用 HTTP webhook, 以 JSON POST 告警, 配置 secret 时携带 HMAC-SHA256 签名
+type NotifyWebhook struct {
+	Url     string            `mapstructure:"url" json:"url" yaml:"url"`
+	Secret  string            `mapstructure:"secret" json:"secret" yaml:"secret"`    // 签名密钥, 为空时不签名
+	Headers map[string]string `mapstructure:"headers" json:"headers" yaml:"headers"` // 附加请求头
+	Timeout int               `mapstructure:"timeout" json:"timeout" yaml:"timeout"` // 超时秒数, 默认 10
+}
+
+// NotifySmtp SMTP 邮件
+type NotifySmtp struct {
+	Host     string   `mapstructure:"host" json:"host" yaml:"host"`
+	Port     int      `mapstructure:"port" json:"port" yaml:"port"`
+	Tls      string   `mapstructure:"tls" json:"tls" yaml:"tls"` // auto(默认, 服务端支持时 STARTTLS)|starttls|tls|none
+	Username string   `mapstructure:"username" json:"username" yaml:"username"`
+	Password string   `mapstructure:"password" json:"password" yaml:"password"`
+	From     string   `mapstructure:"from" json:"from" yaml:"from"`
+	To       []string `mapstructure:"to" json:"to" yaml:"to"`                // 默认收件人
+	Timeout  int      `mapstructure:"timeout" json:"timeout" yaml:"timeout"` // 超时秒数, 默认 10
+}
+
+// NotifyBot IM 群机器人 webhook
+type NotifyBot struct {
+	Url      string   `mapstructure:"url" json:"url" yaml:"url"`                // 机器人 webhook 地址
+	Secret   string   `mapstructure:"secret" json:"secret" yaml:"secret"`       // 加签密钥(钉钉/飞书), 为空时不加签
+	Mentions []string `mapstructure:"mentions" json:"mentions" yaml:"mentions"` // 默认 @ 的成员: 企业微信 userid, 钉钉手机号, 飞书 open_id
+	Timeout  int      `mapstructure:"timeout" json:"timeout" yaml:"timeout"`    // 超时秒数, 默认 10
+}
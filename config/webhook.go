@@ -0,0 +1,109 @@
+package config
+
+import "time"
+
+type Webhook struct {
+	MaxRecommendationAge time.Duration `mapstructure:"max-recommendation-age" json:"maxRecommendationAge" yaml:"max-recommendation-age"` // 推荐结果最大有效期(按 status.lastUpdateTime), 如 168h, 0 表示不限制
+	AdoptionTypes        []string      `mapstructure:"adoption-types" json:"adoptionTypes" yaml:"adoption-types"`                        // webhook 处理的 spec.adoptionType, 为空时默认 Status,StatusAndAnnotation
+	MutationMode         string        `mapstructure:"mutation-mode" json:"mutationMode" yaml:"mutation-mode"`                           // 默认变更模式: enabled(默认)|disabled|dry-run, 可被命名空间标签覆盖, 工作负载/Pod 注解只能收紧
+	NamespaceSelector    string        `mapstructure:"namespace-selector" json:"namespaceSelector" yaml:"namespace-selector"`            // 命名空间标签选择器, 不匹配的命名空间不做变更, 为空表示全部
+	LimitPolicy          string        `mapstructure:"limit-policy" json:"limitPolicy" yaml:"limit-policy"`                              // limits处理策略: cap(默认)|keep-ratio|multiplier|remove-cpu-limit
+	LimitMultiplier      float64       `mapstructure:"limit-multiplier" json:"limitMultiplier" yaml:"limit-multiplier"`                  // multiplier策略下 limits = 推荐值 * 倍数
+	QoSPolicy            string        `mapstructure:"qos-policy" json:"qosPolicy" yaml:"qos-policy"`                                    // Guaranteed Pod 的处理策略: preserve(默认, limits 跟随推荐值)|skip|ignore, 可被工作负载或 Pod 注解 finops.io/qos-policy 覆盖
+
+	Cert           WebhookCert              `mapstructure:"cert" json:"cert" yaml:"cert"`                                 // webhook TLS 证书管理
+	Startup        K8sStartup               `mapstructure:"startup" json:"startup" yaml:"startup"`                        // K8s 客户端与 informer 启动重试及健康检查
+	LeaderElection LeaderElection           `mapstructure:"leader-election" json:"leaderElection" yaml:"leader-election"` // 多副本选主, 证书轮换等写操作只由 leader 执行
+	Audit          AdmissionAudit           `mapstructure:"audit" json:"audit" yaml:"audit"`                              // 准入决策审计落库
+	Controller     RecommendationController `mapstructure:"controller" json:"controller" yaml:"controller"`               // 控制器模式: 把推荐值写回工作负载的 Pod 模板
+	InPlaceResize  InPlaceResize            `mapstructure:"in-place-resize" json:"inPlaceResize" yaml:"in-place-resize"`  // 原地调整运行中 Pod 的资源
+	Autoscalers    Autoscalers              `mapstructure:"autoscalers" json:"autoscalers" yaml:"autoscalers"`            // 与 HPA/VPA 共存的策略
+
+	Guardrail           Guardrail            `mapstructure:"guardrail" json:"guardrail" yaml:"guardrail"`                                 // 推荐值全局护栏
+	NamespaceGuardrails map[string]Guardrail `mapstructure:"namespace-guardrails" json:"namespaceGuardrails" yaml:"namespace-guardrails"` // 按命名空间覆盖的护栏, 未配置的字段沿用全局值
+
+	Clusters []WebhookCluster `mapstructure:"clusters" json:"clusters" yaml:"clusters"` // 额外服务的成员集群, system.cluster-id 对应的本集群始终服务
+}
+
+// WebhookCluster 成员集群. Recommendation CR 统一从 k8s 配置的集群读取, Pod 的属主与命名空间标签从成员集群读取,
+// 成员集群的 MutatingWebhookConfiguration 指向 /mutate/{id}
+type WebhookCluster struct {
+	ID                  string               `mapstructure:"id" json:"id" yaml:"id"`                                                      // 与 Recommendation spec.cluster 一致
+	Enabled             *bool                `mapstructure:"enabled" json:"enabled" yaml:"enabled"`                                       // false 时该集群的 Pod 一律放行不打补丁, 默认 true
+	K8s                 K8s                  `mapstructure:"k8s" json:"k8s" yaml:"k8s"`                                                   // 成员集群连接方式(kube-config 或 host+bearer-token), 为空表示就是 k8s 配置的集群
+	Guardrail           Guardrail            `mapstructure:"guardrail" json:"guardrail" yaml:"guardrail"`                                 // 覆盖全局护栏, 未配置的字段沿用全局值
+	NamespaceGuardrails map[string]Guardrail `mapstructure:"namespace-guardrails" json:"namespaceGuardrails" yaml:"namespace-guardrails"` // 该集群按命名空间覆盖的护栏, 优先于全局的命名空间护栏
+}
+
+// Guardrail 推荐值护栏: 绝对上下限与相对当前 request 的最大变化比例
+type Guardrail struct {
+	Min              map[string]string `mapstructure:"min" json:"min" yaml:"min"`                                            // 资源下限, 如 cpu: 50m, memory: 64Mi
+	Max              map[string]string `mapstructure:"max" json:"max" yaml:"max"`                                            // 资源上限
+	MaxDecreaseRatio float64           `mapstructure:"max-decrease-ratio" json:"maxDecreaseRatio" yaml:"max-decrease-ratio"` // 最大降幅, 0.5 表示最多降到当前值的 50%, 0 表示不限制
+	MaxIncreaseRatio float64           `mapstructure:"max-increase-ratio" json:"maxIncreaseRatio" yaml:"max-increase-ratio"` // 最大涨幅, 1 表示最多涨到当前值的 200%, 0 表示不限制
+}
+
+// WebhookCert webhook TLS 证书管理
+type WebhookCert struct {
+	Mode              string        `mapstructure:"mode" json:"mode" yaml:"mode"`                                            // file(默认, 使用 system.tls-cert/tls-key 并在文件变化时热加载) | secret(自签发并存入 Secret, 自动轮换)
+	SecretName        string        `mapstructure:"secret-name" json:"secretName" yaml:"secret-name"`                        // 存放 CA 与服务证书的 Secret
+	SecretNamespace   string        `mapstructure:"secret-namespace" json:"secretNamespace" yaml:"secret-namespace"`         // Secret 所在命名空间
+	ServiceName       string        `mapstructure:"service-name" json:"serviceName" yaml:"service-name"`                     // webhook Service 名称, 用于证书 SAN
+	ServiceNamespace  string        `mapstructure:"service-namespace" json:"serviceNamespace" yaml:"service-namespace"`      // webhook Service 命名空间, 为空时同 secret-namespace
+	WebhookConfigName string        `mapstructure:"webhook-config-name" json:"webhookConfigName" yaml:"webhook-config-name"` // 需要注入 caBundle 的 MutatingWebhookConfiguration, 为空不注入
+	CAValidity        time.Duration `mapstructure:"ca-validity" json:"caValidity" yaml:"ca-validity"`                        // CA 有效期, 默认 87600h
+	CertValidity      time.Duration `mapstructure:"cert-validity" json:"certValidity" yaml:"cert-validity"`                  // 服务证书有效期, 默认 8760h
+	RotateBefore      time.Duration `mapstructure:"rotate-before" json:"rotateBefore" yaml:"rotate-before"`                  // 到期前多久轮换, 默认 720h
+	CheckInterval     time.Duration `mapstructure:"check-interval" json:"checkInterval" yaml:"check-interval"`               // Secret 检查周期, 默认 1m
+}
+
+// K8sStartup K8s 客户端与 informer 的启动重试及健康检查, 启动失败不会退出进程
+type K8sStartup struct {
+	SyncTimeout    time.Duration `mapstructure:"sync-timeout" json:"syncTimeout" yaml:"sync-timeout"`          // 单次等待 informer 同步的超时, 默认 2m, 超时后按退避重试
+	RetryMaxDelay  time.Duration `mapstructure:"retry-max-delay" json:"retryMaxDelay" yaml:"retry-max-delay"`  // 重试退避上限, 默认 5m
+	UnhealthyAfter time.Duration `mapstructure:"unhealthy-after" json:"unhealthyAfter" yaml:"unhealthy-after"` // informer watch 持续失败超过该时长后 /livez 返回失败, 默认 5m
+}
+
+// LeaderElection 基于 Lease 的选主
+type LeaderElection struct {
+	Enabled        bool          `mapstructure:"enabled" json:"enabled" yaml:"enabled"`                        // 关闭时每个副本都视为 leader, 仅适用于单副本部署
+	LeaseName      string        `mapstructure:"lease-name" json:"leaseName" yaml:"lease-name"`                // Lease 名称
+	LeaseNamespace string        `mapstructure:"lease-namespace" json:"leaseNamespace" yaml:"lease-namespace"` // Lease 所在命名空间
+	LeaseDuration  time.Duration `mapstructure:"lease-duration" json:"leaseDuration" yaml:"lease-duration"`    // 默认 15s
+	RenewDeadline  time.Duration `mapstructure:"renew-deadline" json:"renewDeadline" yaml:"renew-deadline"`    // 默认 10s
+	RetryPeriod    time.Duration `mapstructure:"retry-period" json:"retryPeriod" yaml:"retry-period"`          // 默认 2s
+}
+
+// RecommendationController 控制器模式, 由 leader 按 status.recommendedInfo 修改 Deployment/StatefulSet 的 Pod 模板,
+// 与 webhook 共用推荐筛选、护栏、limits 策略和变更模式; 每次修改记录在 Recommendation CR 的 finops.io/applied-changes 注解
+type RecommendationController struct {
+	Enabled               bool          `mapstructure:"enabled" json:"enabled" yaml:"enabled"`                                               // 是否开启, 默认关闭
+	Interval              time.Duration `mapstructure:"interval" json:"interval" yaml:"interval"`                                            // 巡检周期, 默认 1m
+	MaxConcurrentRollouts int           `mapstructure:"max-concurrent-rollouts" json:"maxConcurrentRollouts" yaml:"max-concurrent-rollouts"` // 同时处于滚动更新中的工作负载上限, 默认 1
+	RolloutTimeout        time.Duration `mapstructure:"rollout-timeout" json:"rolloutTimeout" yaml:"rollout-timeout"`                        // 滚动更新超过该时长仍未完成则不再占用并发名额, 默认 30m
+	DefaultPeriod         time.Duration `mapstructure:"default-period" json:"defaultPeriod" yaml:"default-period"`                           // completionStrategy 为 Periodical 且未设置 periodSeconds 时的应用间隔, 默认 24h
+}
+
+// InPlaceResize 通过 Pod 的 resize 子资源原地调整运行中 Pod 的 requests, 需要集群开启 InPlacePodVerticalScaling,
+// 由 leader 执行; 与 webhook 共用推荐筛选、护栏、limits 策略和变更模式, 只调整普通容器且不改变 Pod 的 QoS
+type InPlaceResize struct {
+	Enabled                   bool          `mapstructure:"enabled" json:"enabled" yaml:"enabled"`                                                             // 是否开启, 默认关闭
+	Interval                  time.Duration `mapstructure:"interval" json:"interval" yaml:"interval"`                                                          // 巡检周期, 默认 1m
+	MaxConcurrentPerNamespace int           `mapstructure:"max-concurrent-per-namespace" json:"maxConcurrentPerNamespace" yaml:"max-concurrent-per-namespace"` // 每个命名空间同时处于调整中的 Pod 上限, 默认 1
+	AllowContainerRestart     bool          `mapstructure:"allow-container-restart" json:"allowContainerRestart" yaml:"allow-container-restart"`               // 是否调整 resizePolicy 为 RestartContainer 的资源, 默认否, 即只做无需重启的调整
+}
+
+// Autoscalers 工作负载有 HPA/VPA 时的处理策略, 决策会写入准入响应的 warnings
+type Autoscalers struct {
+	HPA string `mapstructure:"hpa" json:"hpa" yaml:"hpa"` // 存在按利用率(Utilization)扩缩的 HPA 时: skip-resource(默认, 不修改 HPA 依据的资源)|skip(整个 Pod 不修改)|scale-target(按新旧 request 等比调整 HPA 目标利用率, 保持绝对阈值不变, 滚动期间按存活 Pod 的平均 request 跟随)|ignore
+	VPA string `mapstructure:"vpa" json:"vpa" yaml:"vpa"` // 存在 updateMode 不为 Off 的 VPA 时: skip(默认, 整个 Pod 不修改)|ignore
+}
+
+// AdmissionAudit 准入决策审计, 异步批量写入 admission_audit 表, 缓冲满时丢弃并计数, 不阻塞准入
+type AdmissionAudit struct {
+	Enabled        bool          `mapstructure:"enabled" json:"enabled" yaml:"enabled"`                        // 是否记录
+	ExcludeReasons []string      `mapstructure:"exclude-reasons" json:"excludeReasons" yaml:"exclude-reasons"` // 不记录的 Pod 跳过原因, 如 no_workload,no_recommendation, 用于降低写入量
+	BufferSize     int           `mapstructure:"buffer-size" json:"bufferSize" yaml:"buffer-size"`             // 待写入记录缓冲, 默认 1000
+	BatchSize      int           `mapstructure:"batch-size" json:"batchSize" yaml:"batch-size"`                // 单次批量写入条数, 默认 100
+	FlushInterval  time.Duration `mapstructure:"flush-interval" json:"flushInterval" yaml:"flush-interval"`    // 最长写入间隔, 默认 5s
+}
@@ -6,6 +6,7 @@ import (
 
 	"main.go/global"
 	"main.go/initialize"
+	serviceObserve "main.go/service/observe"
 
 	"go.uber.org/zap"
 )
@@ -16,6 +17,8 @@ type server interface {
 
 func RunWindowsServer() {
 	Router := initialize.Routers()
+	// 通知发件箱: 恢复上次未完成的通知并启动发送协程
+	serviceObserve.StartNotifyOutbox()
 
 	address := fmt.Sprintf("%s:%d", global.GVA_CONFIG.System.Host, global.GVA_CONFIG.System.Port)
 	s := initServer(address, Router)
@@ -0,0 +1,300 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/spf13/viper"
+	jsonpatch "gopkg.in/evanphx/json-patch.v4"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/tools/record"
+
+	"main.go/global"
+	modelWebhook "main.go/model/webhook"
+	"main.go/service"
+	"main.go/utils"
+)
+
+// stringList collects a repeatable flag.
+type stringList []string
+
+func (l *stringList) String() string     { return strings.Join(*l, ",") }
+func (l *stringList) Set(v string) error { *l = append(*l, v); return nil }
+
+// RunSimulate runs MutatePod against YAML files instead of a cluster and prints what the webhook
+// would do: the decision per container, the warnings, the JSON patch and the resulting pod diff.
+// It needs neither a cluster nor a database. The webhook logs go to stderr, the report to stdout.
+//
+//	main simulate -pod pod.yaml -f doc/recommend_v2.yaml [-f replicaset.yaml ...] [-c config.yaml]
+func RunSimulate(args []string) int {
+	fs := flag.NewFlagSet("simulate", flag.ContinueOnError)
+	var (
+		configFile, podFile, clusterID, namespace string
+		objectFiles                               stringList
+	)
+	fs.StringVar(&configFile, "c", "", "config file; defaults to $"+utils.ConfigEnv+", then "+utils.ConfigFile+" when present")
+	fs.StringVar(&podFile, "pod", "", "Pod or AdmissionReview YAML/JSON to admit (required)")
+	fs.Var(&objectFiles, "f", "YAML with Recommendation CRs and the workloads, ReplicaSets, Jobs, Namespaces, LimitRanges, ResourceQuotas, HPAs and VPAs they rely on; repeatable")
+	fs.StringVar(&clusterID, "cluster", "", "member cluster the pod is admitted in; defaults to system.cluster-id")
+	fs.StringVar(&namespace, "namespace", "", "namespace of the pod; defaults to the AdmissionReview's, then the pod's")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if podFile == "" || len(objectFiles) == 0 {
+		fmt.Fprintln(os.Stderr, "simulate: -pod and at least one -f are required")
+		fs.Usage()
+		return 2
+	}
+
+	if err := runSimulate(os.Stdout, configFile, podFile, objectFiles, clusterID, namespace); err != nil {
+		fmt.Fprintf(os.Stderr, "simulate: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func runSimulate(out io.Writer, configFile, podFile string, objectFiles []string, clusterID, namespace string) error {
+	if err := loadSimulateConfig(configFile); err != nil {
+		return err
+	}
+	if clusterID == "" {
+		clusterID = global.GVA_CONFIG.System.ClusterId
+	}
+
+	pod, reviewNamespace, err := readAdmittedPod(podFile)
+	if err != nil {
+		return err
+	}
+	if namespace == "" {
+		namespace = reviewNamespace
+	}
+	if namespace == "" {
+		namespace = pod.Namespace
+	}
+
+	var objects []*unstructured.Unstructured
+	for _, file := range objectFiles {
+		objs, err := readObjects(file)
+		if err != nil {
+			return err
+		}
+		objects = append(objects, objs...)
+	}
+
+	recommendationService := &service.ServiceGroupApp.WebhookServiceGroup.RecommendationService
+	events := record.NewFakeRecorder(100)
+	if err := recommendationService.LoadOffline(clusterID, objects, events); err != nil {
+		return err
+	}
+	result, err := recommendationService.MutatePod(clusterID, pod.DeepCopy(), namespace, false)
+	if err != nil {
+		return fmt.Errorf("mutate pod: %w", err)
+	}
+	return printSimulation(out, pod, result, events)
+}
+
+// loadSimulateConfig reads the config like Viper does, without watching it. Without a config file
+// the defaults apply.
+func loadSimulateConfig(configFile string) error {
+	if configFile == "" {
+		configFile = os.Getenv(utils.ConfigEnv)
+	}
+	if configFile == "" {
+		if _, err := os.Stat(utils.ConfigFile); err != nil {
+			return nil
+		}
+		configFile = utils.ConfigFile
+	}
+	v := viper.New()
+	v.SetConfigFile(configFile)
+	v.SetConfigType("yaml")
+	if err := v.ReadInConfig(); err != nil {
+		return fmt.Errorf("read config %s: %w", configFile, err)
+	}
+	if err := v.Unmarshal(&global.GVA_CONFIG); err != nil {
+		return fmt.Errorf("parse config %s: %w", configFile, err)
+	}
+	return nil
+}
+
+// readAdmittedPod reads a Pod, or the Pod of an AdmissionReview together with the request namespace.
+func readAdmittedPod(file string) (*corev1.Pod, string, error) {
+	objs, err := readObjects(file)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(objs) != 1 {
+		return nil, "", fmt.Errorf("%s: expected one Pod or AdmissionReview, found %d objects", file, len(objs))
+	}
+	obj := objs[0]
+
+	pod := &corev1.Pod{}
+	switch obj.GetKind() {
+	case "Pod":
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, pod); err != nil {
+			return nil, "", fmt.Errorf("%s: %w", file, err)
+		}
+		return pod, "", nil
+	case "AdmissionReview":
+		var review admissionv1.AdmissionReview
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &review); err != nil {
+			return nil, "", fmt.Errorf("%s: %w", file, err)
+		}
+		if review.Request == nil {
+			return nil, "", fmt.Errorf("%s: AdmissionReview has no request", file)
+		}
+		if err := json.Unmarshal(review.Request.Object.Raw, pod); err != nil {
+			return nil, "", fmt.Errorf("%s: request.object: %w", file, err)
+		}
+		return pod, review.Request.Namespace, nil
+	default:
+		return nil, "", fmt.Errorf("%s: expected a Pod or AdmissionReview, found %s", file, obj.GetKind())
+	}
+}
+
+// readObjects decodes every YAML or JSON document of a file, expanding List kinds.
+func readObjects(file string) ([]*unstructured.Unstructured, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var objs []*unstructured.Unstructured
+	decoder := utilyaml.NewYAMLOrJSONDecoder(f, 4096)
+	for {
+		obj := &unstructured.Unstructured{}
+		if err := decoder.Decode(&obj.Object); err != nil {
+			if errors.Is(err, io.EOF) {
+				return objs, nil
+			}
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		if len(obj.Object) == 0 {
+			continue
+		}
+		if !obj.IsList() {
+			objs = append(objs, obj)
+			continue
+		}
+		err := obj.EachListItem(func(item runtime.Object) error {
+			objs = append(objs, item.(*unstructured.Unstructured))
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+	}
+}
+
+func printSimulation(out io.Writer, pod *corev1.Pod, result *modelWebhook.MutationResult, events *record.FakeRecorder) error {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "Cluster:\t%s\n", result.Cluster)
+	fmt.Fprintf(w, "Mode:\t%s\n", result.Mode)
+	if result.Workload.Name != "" {
+		fmt.Fprintf(w, "Workload:\t%s %s\n", result.Workload.Kind, result.Workload.Name)
+	}
+	if result.RecommendationName != "" {
+		fmt.Fprintf(w, "Recommendation:\t%s (resourceVersion %s)\n", result.RecommendationName, result.RecommendationResourceVersion)
+	}
+	if result.SkipReason != "" {
+		fmt.Fprintf(w, "Result:\tskipped (%s)\n", result.SkipReason)
+	} else {
+		fmt.Fprintf(w, "Result:\tpatched\n")
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if len(result.Containers) > 0 {
+		fmt.Fprintln(out, "\nContainers:")
+		w = tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "  NAME\tKIND\tOLD CPU\tNEW CPU\tOLD MEMORY\tNEW MEMORY\tREASON")
+		for _, c := range result.Containers {
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\t%s\t%s\n", c.Name, c.Kind, c.OldCPU, c.NewCPU, c.OldMemory, c.NewMemory, c.Reason)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	printList(out, "Warnings", result.Warnings)
+	var annotations []string
+	for k, v := range result.AuditAnnotations {
+		annotations = append(annotations, k+": "+v)
+	}
+	sort.Strings(annotations)
+	printList(out, "Audit annotations", annotations)
+	var recorded []string
+	for len(events.Events) > 0 {
+		recorded = append(recorded, <-events.Events)
+	}
+	printList(out, "Events", recorded)
+
+	if len(result.Patches) == 0 {
+		return nil
+	}
+	patchBytes, err := json.MarshalIndent(result.Patches, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "\nPatch:\n%s\n", patchBytes)
+
+	before, after, err := patchedPod(pod, result.Patches)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "\nPod diff (-before +after):\n%s", cmp.Diff(before, after))
+	return nil
+}
+
+func printList(out io.Writer, title string, items []string) {
+	if len(items) == 0 {
+		return
+	}
+	fmt.Fprintf(out, "\n%s:\n", title)
+	for _, item := range items {
+		fmt.Fprintf(out, "  - %s\n", item)
+	}
+}
+
+// patchedPod applies the patches to the pod the way the API server does and returns both versions
+// as plain maps, which diff readably.
+func patchedPod(pod *corev1.Pod, patches []modelWebhook.JSONPatch) (map[string]interface{}, map[string]interface{}, error) {
+	original, err := json.Marshal(pod)
+	if err != nil {
+		return nil, nil, err
+	}
+	patchBytes, err := json.Marshal(patches)
+	if err != nil {
+		return nil, nil, err
+	}
+	patch, err := jsonpatch.DecodePatch(patchBytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("decode patch: %w", err)
+	}
+	patched, err := patch.Apply(original)
+	if err != nil {
+		return nil, nil, fmt.Errorf("apply patch: %w", err)
+	}
+
+	var before, after map[string]interface{}
+	if err := json.Unmarshal(original, &before); err != nil {
+		return nil, nil, err
+	}
+	if err := json.Unmarshal(patched, &after); err != nil {
+		return nil, nil, err
+	}
+	return before, after, nil
+}
@@ -0,0 +1,81 @@
+package core
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"log"
+	"path/filepath"
+	"sync/atomic"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// certHolder serves the current webhook certificate through tls.Config.GetCertificate so a
+// rotated certificate is picked up by new TLS handshakes without restarting the server.
+type certHolder struct {
+	cert atomic.Pointer[tls.Certificate]
+}
+
+func (h *certHolder) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cert := h.cert.Load()
+	if cert == nil {
+		return nil, errors.New("webhook certificate not loaded yet")
+	}
+	return cert, nil
+}
+
+func (h *certHolder) set(cert tls.Certificate) {
+	h.cert.Store(&cert)
+}
+
+// watchCertFiles loads the key pair and reloads it whenever either file changes. The parent
+// directories are watched rather than the files because mounted Secrets are swapped through
+// a symlink, which never emits a write event on the file itself.
+func watchCertFiles(holder *certHolder, certFile, keyFile string) error {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return err
+	}
+	holder.set(cert)
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	dirs := map[string]struct{}{filepath.Dir(certFile): {}, filepath.Dir(keyFile): {}}
+	for dir := range dirs {
+		if err := watcher.Add(dir); err != nil {
+			watcher.Close()
+			return err
+		}
+	}
+
+	go func() {
+		defer watcher.Close()
+		for {
+			select {
+			case _, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+				if err != nil {
+					// Files are often written one at a time; keep serving the old pair until both match.
+					continue
+				}
+				if current := holder.cert.Load(); current != nil && bytes.Equal(current.Certificate[0], cert.Certificate[0]) {
+					continue
+				}
+				holder.set(cert)
+				log.Printf("Reloaded webhook certificate from %s", certFile)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Printf("Webhook certificate watcher error: %v", err)
+			}
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,488 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"time"
+
+	"main.go/config"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+var (
+	secretGVR                 = schema.GroupVersionResource{Version: "v1", Resource: "secrets"}
+	mutatingWebhookConfigGVR  = schema.GroupVersionResource{Group: "admissionregistration.k8s.io", Version: "v1", Resource: "mutatingwebhookconfigurations"}
+	defaultCAValidity         = 10 * 365 * 24 * time.Hour
+	defaultCertValidity       = 365 * 24 * time.Hour
+	defaultCertRotateBefore   = 30 * 24 * time.Hour
+	defaultCertCheckInterval  = time.Minute
+	certSecretKeys            = []string{"ca.crt", "ca.key", "tls.crt", "tls.key"}
+	previousCAKey             = "ca-previous.crt"
+	certManagerRequestTimeout = 10 * time.Second
+	// certBackdate is subtracted from NotBefore to tolerate clock skew; NotBefore + certBackdate is the issue time.
+	certBackdate = time.Hour
+)
+
+// secretCertManager keeps a self-signed CA and serving certificate in a Secret, rotates the serving
+// certificate before it expires and injects the CA into a MutatingWebhookConfiguration. A CA close to
+// expiry is rotated in steps so the API server never sees a serving certificate it cannot verify: the
+// new CA is added next to the old one in caBundle, the serving certificate is reissued from the new CA
+// once that bundle is injected, and the old CA is dropped when every replica has had time to load the
+// new serving certificate or when the old CA expires. The Secret is
+// the source of truth, so several replicas converge on the same certificate; concurrent writers are
+// resolved through resourceVersion conflicts. With leader election enabled only the leader issues,
+// rotates and injects; the other replicas load whatever the Secret holds.
+type secretCertManager struct {
+	cfg      config.WebhookCert
+	client   dynamic.Interface
+	holder   *certHolder
+	isLeader func() bool
+
+	loadedCert []byte
+}
+
+func newSecretCertManager(cfg config.WebhookCert, client dynamic.Interface, holder *certHolder, isLeader func() bool) *secretCertManager {
+	if cfg.ServiceNamespace == "" {
+		cfg.ServiceNamespace = cfg.SecretNamespace
+	}
+	if cfg.CAValidity <= 0 {
+		cfg.CAValidity = defaultCAValidity
+	}
+	if cfg.CertValidity <= 0 {
+		cfg.CertValidity = defaultCertValidity
+	}
+	if cfg.RotateBefore <= 0 {
+		cfg.RotateBefore = defaultCertRotateBefore
+	}
+	if cfg.CheckInterval <= 0 {
+		cfg.CheckInterval = defaultCertCheckInterval
+	}
+	if cfg.RotateBefore >= cfg.CertValidity {
+		// Otherwise every freshly issued certificate would already be due for rotation.
+		cfg.RotateBefore = cfg.CertValidity / 2
+	}
+	return &secretCertManager{cfg: cfg, client: client, holder: holder, isLeader: isLeader}
+}
+
+// serveTemporary serves a certificate from a throwaway CA until the Secret has been read.
+func (m *secretCertManager) serveTemporary() error {
+	bundle, err := m.generate(time.Now())
+	if err != nil {
+		return err
+	}
+	cert, err := tls.X509KeyPair(bundle.certPEM, bundle.keyPEM)
+	if err != nil {
+		return err
+	}
+	m.holder.set(cert)
+	return nil
+}
+
+// run reconciles right away and then on the check interval until stopCh is closed.
+func (m *secretCertManager) run(stopCh <-chan struct{}) {
+	if err := m.reconcile(); err != nil {
+		log.Printf("Webhook certificate reconcile failed: %v", err)
+	}
+	ticker := time.NewTicker(m.cfg.CheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			if err := m.reconcile(); err != nil {
+				log.Printf("Webhook certificate reconcile failed: %v", err)
+			}
+		}
+	}
+}
+
+// reconcile makes sure the Secret holds a valid certificate, serves it and injects its CA.
+func (m *secretCertManager) reconcile() error {
+	if m.cfg.SecretName == "" || m.cfg.SecretNamespace == "" || m.cfg.ServiceName == "" {
+		return errors.New("webhook.cert secret-name, secret-namespace and service-name are required in secret mode")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), certManagerRequestTimeout)
+	defer cancel()
+
+	secrets := m.client.Resource(secretGVR).Namespace(m.cfg.SecretNamespace)
+	leader := m.isLeader == nil || m.isLeader()
+	secret, err := secrets.Get(ctx, m.cfg.SecretName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) && !leader {
+		return fmt.Errorf("waiting for the leader to create Secret %s/%s", m.cfg.SecretNamespace, m.cfg.SecretName)
+	}
+	if apierrors.IsNotFound(err) {
+		bundle, genErr := m.generate(time.Now())
+		if genErr != nil {
+			return genErr
+		}
+		secret, err = secrets.Create(ctx, m.newSecret(bundle), metav1.CreateOptions{})
+		if apierrors.IsAlreadyExists(err) {
+			// Another replica won the race; use its certificate.
+			secret, err = secrets.Get(ctx, m.cfg.SecretName, metav1.GetOptions{})
+		} else if err == nil {
+			log.Printf("Created webhook certificate Secret %s/%s", m.cfg.SecretNamespace, m.cfg.SecretName)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	bundle, err := readCertBundle(secret)
+	if err != nil && !leader {
+		return fmt.Errorf("secret %s/%s is invalid, waiting for the leader to regenerate it: %w", m.cfg.SecretNamespace, m.cfg.SecretName, err)
+	}
+	if leader {
+		var next *certBundle
+		var step string
+		if err != nil {
+			log.Printf("Webhook certificate Secret %s/%s is invalid, regenerating: %v", m.cfg.SecretNamespace, m.cfg.SecretName, err)
+			next, err = m.generate(time.Now())
+			step = "regenerated"
+		} else {
+			// The caBundle must trust the CA before a serving certificate issued by it is served.
+			if err := m.injectCABundle(ctx, bundle.caBundle()); err != nil {
+				return err
+			}
+			next, step, err = m.rotate(bundle, time.Now())
+		}
+		if err != nil {
+			return err
+		}
+		if next != nil {
+			setCertBundle(secret, next)
+			secret, err = secrets.Update(ctx, secret, metav1.UpdateOptions{})
+			if apierrors.IsConflict(err) {
+				// Another replica rotated first; pick up its certificate on the next tick.
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			log.Printf("Webhook certificate Secret %s/%s: %s", m.cfg.SecretNamespace, m.cfg.SecretName, step)
+			if bundle, err = readCertBundle(secret); err != nil {
+				return err
+			}
+		}
+	}
+
+	if !bytes.Equal(bundle.certPEM, m.loadedCert) {
+		cert, err := tls.X509KeyPair(bundle.certPEM, bundle.keyPEM)
+		if err != nil {
+			return err
+		}
+		m.holder.set(cert)
+		m.loadedCert = bundle.certPEM
+		log.Printf("Loaded webhook certificate from Secret %s/%s, valid until %s", m.cfg.SecretNamespace, m.cfg.SecretName, bundle.cert.NotAfter.Format(time.RFC3339))
+	}
+
+	if !leader {
+		return nil
+	}
+	return m.injectCABundle(ctx, bundle.caBundle())
+}
+
+// certBundle is the decoded content of the certificate Secret. previousCA is the CA being rotated
+// out; it stays in caBundle until the serving certificate issued by the new CA has been rolled out.
+type certBundle struct {
+	caPEM, caKeyPEM, certPEM, keyPEM []byte
+	ca, cert                         *x509.Certificate
+	caKey                            *ecdsa.PrivateKey
+	previousCAPEM                    []byte
+	previousCA                       *x509.Certificate
+}
+
+// caBundle is the PEM injected into the webhook configuration: the CA followed by the previous CA
+// while it is still being rotated out.
+func (b *certBundle) caBundle() []byte {
+	if b.previousCA == nil {
+		return b.caPEM
+	}
+	return append(append([]byte{}, b.caPEM...), b.previousCAPEM...)
+}
+
+func readCertBundle(secret *unstructured.Unstructured) (*certBundle, error) {
+	data, _, err := unstructured.NestedStringMap(secret.Object, "data")
+	if err != nil {
+		return nil, err
+	}
+	raw := make(map[string][]byte, len(certSecretKeys))
+	for _, key := range certSecretKeys {
+		decoded, err := base64.StdEncoding.DecodeString(data[key])
+		if err != nil || len(decoded) == 0 {
+			return nil, fmt.Errorf("missing or invalid %s", key)
+		}
+		raw[key] = decoded
+	}
+
+	b := &certBundle{caPEM: raw["ca.crt"], caKeyPEM: raw["ca.key"], certPEM: raw["tls.crt"], keyPEM: raw["tls.key"]}
+	if b.ca, err = parseCertPEM(b.caPEM); err != nil {
+		return nil, fmt.Errorf("ca.crt: %w", err)
+	}
+	if b.cert, err = parseCertPEM(b.certPEM); err != nil {
+		return nil, fmt.Errorf("tls.crt: %w", err)
+	}
+	block, _ := pem.Decode(b.caKeyPEM)
+	if block == nil {
+		return nil, errors.New("ca.key: no PEM block")
+	}
+	if b.caKey, err = x509.ParseECPrivateKey(block.Bytes); err != nil {
+		return nil, fmt.Errorf("ca.key: %w", err)
+	}
+	// The previous CA is optional; an unreadable one is dropped rather than failing the bundle.
+	if previous, err := base64.StdEncoding.DecodeString(data[previousCAKey]); err == nil && len(previous) > 0 {
+		if b.previousCA, err = parseCertPEM(previous); err == nil {
+			b.previousCAPEM = previous
+		} else {
+			log.Printf("Ignoring invalid %s in webhook certificate Secret: %v", previousCAKey, err)
+		}
+	}
+	return b, nil
+}
+
+func parseCertPEM(data []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// rotate returns the next state of the bundle and what changed, or nil when nothing is due. Each call
+// takes at most one step, so the caBundle holding both CAs is injected before the serving
+// certificate is reissued from the new CA.
+func (m *secretCertManager) rotate(b *certBundle, now time.Time) (*certBundle, string, error) {
+	deadline := now.Add(m.cfg.RotateBefore)
+	next := *b
+
+	if b.ca.NotAfter.Before(deadline) {
+		// Keep serving the current certificate, signed by the old CA, until the new CA is trusted.
+		if err := m.newCA(&next, now); err != nil {
+			return nil, "", err
+		}
+		next.previousCA, next.previousCAPEM = b.ca, b.caPEM
+		return &next, "added a new CA next to the expiring one", nil
+	}
+
+	if m.certDue(b, deadline) {
+		if err := m.issueCert(&next, now); err != nil {
+			return nil, "", err
+		}
+		return &next, "reissued the serving certificate", nil
+	}
+
+	if b.previousCA != nil {
+		issued := b.cert.NotBefore.Add(certBackdate)
+		// Followers reload the Secret on the check interval; two intervals leave room for one missed tick.
+		rolledOut := b.cert.CheckSignatureFrom(b.ca) == nil && now.Sub(issued) >= 2*m.cfg.CheckInterval
+		if rolledOut || now.After(b.previousCA.NotAfter) {
+			next.previousCA, next.previousCAPEM = nil, nil
+			return &next, "dropped the previous CA", nil
+		}
+	}
+	return nil, "", nil
+}
+
+// certDue reports whether the serving certificate expires before deadline, is no longer signed by the
+// stored CA or is missing one of the Service DNS names.
+func (m *secretCertManager) certDue(b *certBundle, deadline time.Time) bool {
+	if b.cert.NotAfter.Before(deadline) {
+		return true
+	}
+	if err := b.cert.CheckSignatureFrom(b.ca); err != nil {
+		return true
+	}
+	for _, name := range m.dnsNames() {
+		if b.cert.VerifyHostname(name) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *secretCertManager) dnsNames() []string {
+	svc, ns := m.cfg.ServiceName, m.cfg.ServiceNamespace
+	return []string{svc, svc + "." + ns, svc + "." + ns + ".svc", svc + "." + ns + ".svc.cluster.local"}
+}
+
+// generate creates a new CA and a serving certificate issued by it.
+func (m *secretCertManager) generate(now time.Time) (*certBundle, error) {
+	b := &certBundle{}
+	if err := m.newCA(b, now); err != nil {
+		return nil, err
+	}
+	if err := m.issueCert(b, now); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// newCA replaces the CA of b with a newly created one.
+func (m *secretCertManager) newCA(b *certBundle, now time.Time) error {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          newSerialNumber(),
+		Subject:               pkix.Name{CommonName: m.cfg.ServiceName + "-ca"},
+		NotBefore:             now.Add(-certBackdate),
+		NotAfter:              now.Add(m.cfg.CAValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		return err
+	}
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		return err
+	}
+	caKeyDER, err := x509.MarshalECPrivateKey(caKey)
+	if err != nil {
+		return err
+	}
+	b.ca, b.caKey = ca, caKey
+	b.caPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER})
+	b.caKeyPEM = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: caKeyDER})
+	return nil
+}
+
+// issueCert replaces the serving certificate of b with a new one issued by its CA.
+func (m *secretCertManager) issueCert(b *certBundle, now time.Time) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	notAfter := now.Add(m.cfg.CertValidity)
+	if notAfter.After(b.ca.NotAfter) {
+		notAfter = b.ca.NotAfter
+	}
+	dnsNames := m.dnsNames()
+	template := &x509.Certificate{
+		SerialNumber: newSerialNumber(),
+		Subject:      pkix.Name{CommonName: dnsNames[2]},
+		DNSNames:     dnsNames,
+		NotBefore:    now.Add(-certBackdate),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, b.ca, &key.PublicKey, b.caKey)
+	if err != nil {
+		return err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+	b.cert = cert
+	b.certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	b.keyPEM = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return nil
+}
+
+func newSerialNumber() *big.Int {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return big.NewInt(time.Now().UnixNano())
+	}
+	return serial
+}
+
+func (m *secretCertManager) newSecret(b *certBundle) *unstructured.Unstructured {
+	secret := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata": map[string]interface{}{
+			"name":      m.cfg.SecretName,
+			"namespace": m.cfg.SecretNamespace,
+		},
+		"type": "kubernetes.io/tls",
+	}}
+	setCertBundle(secret, b)
+	return secret
+}
+
+func setCertBundle(secret *unstructured.Unstructured, b *certBundle) {
+	data := map[string]interface{}{
+		"ca.crt":  base64.StdEncoding.EncodeToString(b.caPEM),
+		"ca.key":  base64.StdEncoding.EncodeToString(b.caKeyPEM),
+		"tls.crt": base64.StdEncoding.EncodeToString(b.certPEM),
+		"tls.key": base64.StdEncoding.EncodeToString(b.keyPEM),
+	}
+	if b.previousCA != nil {
+		data[previousCAKey] = base64.StdEncoding.EncodeToString(b.previousCAPEM)
+	}
+	_ = unstructured.SetNestedMap(secret.Object, data, "data")
+}
+
+// injectCABundle writes the CA bundle into every webhook of the configured MutatingWebhookConfiguration.
+// It only updates when a caBundle differs, so a steady state costs one GET per check interval.
+func (m *secretCertManager) injectCABundle(ctx context.Context, caPEM []byte) error {
+	if m.cfg.WebhookConfigName == "" {
+		return nil
+	}
+	configs := m.client.Resource(mutatingWebhookConfigGVR)
+	obj, err := configs.Get(ctx, m.cfg.WebhookConfigName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("get MutatingWebhookConfiguration %s: %w", m.cfg.WebhookConfigName, err)
+	}
+
+	webhooks, _, err := unstructured.NestedSlice(obj.Object, "webhooks")
+	if err != nil {
+		return err
+	}
+	encoded := base64.StdEncoding.EncodeToString(caPEM)
+	changed := false
+	for i := range webhooks {
+		wh, ok := webhooks[i].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		current, _, _ := unstructured.NestedString(wh, "clientConfig", "caBundle")
+		if currentPEM, err := base64.StdEncoding.DecodeString(current); err == nil && bytes.Equal(currentPEM, caPEM) {
+			continue
+		}
+		if err := unstructured.SetNestedField(wh, encoded, "clientConfig", "caBundle"); err != nil {
+			return err
+		}
+		webhooks[i] = wh
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+
+	if err := unstructured.SetNestedSlice(obj.Object, webhooks, "webhooks"); err != nil {
+		return err
+	}
+	if _, err := configs.Update(ctx, obj, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("update caBundle of MutatingWebhookConfiguration %s: %w", m.cfg.WebhookConfigName, err)
+	}
+	log.Printf("Injected caBundle into MutatingWebhookConfiguration %s", m.cfg.WebhookConfigName)
+	return nil
+}
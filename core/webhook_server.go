@@ -1,17 +1,22 @@
 package core
 
 import (
+	"crypto/tls"
 	"fmt"
 	"log"
+	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"main.go/global"
 	"main.go/initialize"
 	"main.go/router"
+	"main.go/service"
 )
 
 func RunWebhookServer() {
-	// Initialize K8s client and informer
+	// Initialize K8s client and informer in the background; until they are ready pods are admitted unchanged
 	initialize.K8s()
 
 	r := gin.New()
@@ -19,13 +24,58 @@ func RunWebhookServer() {
 
 	// Initialize webhook router
 	router.RouterGroupApp.Webhook.InitWebhookRouter(r.Group("/"))
+	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
 
 	host := global.GVA_CONFIG.System.Host
 	port := global.GVA_CONFIG.System.WebhookPort
 	address := fmt.Sprintf("%s:%d", host, port)
 
+	holder := &certHolder{}
+	if err := startCertSource(holder); err != nil {
+		// Only the webhook stops; the alert API keeps running.
+		log.Printf("Webhook certificate setup failed, webhook server not started: %v", err)
+		return
+	}
+
+	server := &http.Server{
+		Addr:              address,
+		Handler:           r,
+		ReadHeaderTimeout: 10 * time.Second,
+		TLSConfig: &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: holder.GetCertificate,
+		},
+	}
+
 	log.Printf("Starting Webhook server on %s...", address)
 
+	// Certificates come from the TLS config, so no files are passed here
+	if err := server.ListenAndServeTLS("", ""); err != nil {
+		log.Printf("Webhook server failed: %v", err)
+	}
+}
+
+// startCertSource loads the serving certificate into holder and keeps it up to date, either from
+// the configured files or, in secret mode, from a self-managed Secret.
+func startCertSource(holder *certHolder) error {
+	certCfg := global.GVA_CONFIG.Webhook.Cert
+	if certCfg.Mode == "secret" {
+		webhookService := &service.ServiceGroupApp.WebhookServiceGroup.RecommendationService
+		manager := newSecretCertManager(certCfg, nil, holder, webhookService.IsLeader)
+		// The Secret needs a Kubernetes client, which may take a while; a throwaway certificate keeps
+		// the probes answering meanwhile. The API server rejects it, so admission falls back to the
+		// webhook's failurePolicy until the real certificate is loaded.
+		if err := manager.serveTemporary(); err != nil {
+			return err
+		}
+		go func() {
+			stopCh := make(chan struct{})
+			manager.client = webhookService.WaitForK8sClient(stopCh)
+			manager.run(stopCh)
+		}()
+		return nil
+	}
+
 	certFile := global.GVA_CONFIG.System.TlsCert
 	keyFile := global.GVA_CONFIG.System.TlsKey
 
@@ -36,9 +86,5 @@ func RunWebhookServer() {
 	if keyFile == "" {
 		keyFile = "/etc/webhook/certs/tls.key"
 	}
-
-	// Certificates paths from config
-	if err := r.RunTLS(address, certFile, keyFile); err != nil {
-		log.Fatalf("Webhook server failed: %v", err)
-	}
+	return watchCertFiles(holder, certFile, keyFile)
 }
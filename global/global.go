@@ -5,6 +5,7 @@ import (
 	"go.uber.org/zap"
 	"gorm.io/gorm"
 	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
 	"main.go/config"
 )
@@ -15,5 +16,6 @@ var (
 	GVA_LOG         *zap.Logger
 	GVA_CONFIG      config.Server
 	GVA_K8S_DYNAMIC dynamic.Interface
+	GVA_K8S_CLIENT  kubernetes.Interface
 	GVA_K8S_INDEXER cache.Indexer
 )
@@ -5,5 +5,5 @@ import (
 )
 
 func K8s() {
-	service.ServiceGroupApp.WebhookServiceGroup.RecommendationService.InitK8s()
+	service.ServiceGroupApp.WebhookServiceGroup.RecommendationService.StartK8s()
 }
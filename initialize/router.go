@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.uber.org/zap"
 	"main.go/global"
 	"main.go/middleware"
@@ -25,6 +26,8 @@ func Routers() *gin.Engine {
 		PublicGroup.GET("/health", func(c *gin.Context) {
 			c.JSON(200, "ok")
 		})
+		// Prometheus 指标
+		PublicGroup.GET("/metrics", gin.WrapH(promhttp.Handler()))
 		// 测试端点 - 打印完整HTTP请求
 		PublicGroup.POST("/api/test", func(c *gin.Context) {
 			global.GVA_LOG.Info("=== HTTP Request ===",
@@ -63,6 +66,19 @@ func Routers() *gin.Engine {
 	{
 		// 告警路由初始化
 		observeRouter.InitObserveAlertRouter(AlertGroup)
+		// 准入审计路由初始化
+		observeRouter.InitAdmissionAuditRouter(AlertGroup)
+		// 告警路由树初始化
+		observeRouter.InitAlertRouteRouter(AlertGroup)
+		// 通知发件箱路由初始化
+		observeRouter.InitNotifyOutboxRouter(AlertGroup)
+		// 告警静默路由初始化
+		observeRouter.InitAlertSilenceRouter(AlertGroup)
+	}
+	// Alertmanager API 兼容路由: POST /api/v2/alerts
+	AlertmanagerGroup := Router.Group("api/v2")
+	{
+		observeRouter.InitAlertmanagerRouter(AlertmanagerGroup)
 	}
 
 	global.GVA_LOG.Info("router register success")
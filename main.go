@@ -1,12 +1,18 @@
 package main
 
 import (
+	"os"
+
 	"main.go/core"
 	"main.go/global"
 	"main.go/initialize"
 )
 
 func main() {
+	// simulate 离线计算 webhook 的 patch, 不连接集群和数据库
+	if len(os.Args) > 1 && os.Args[1] == "simulate" {
+		os.Exit(core.RunSimulate(os.Args[2:]))
+	}
 
 	global.GVA_VP = core.Viper()      // 初始化Viper
 	global.GVA_LOG = core.Zap()       // 初始化zap日志库
@@ -0,0 +1,50 @@
+package observe
+
+import (
+	"main.go/model/common"
+	"main.go/model/common/request"
+)
+
+// AdmissionAudit webhook 准入决策审计记录, 每个容器一条; Pod 在匹配容器前被跳过时只记一条, container 为空
+type AdmissionAudit struct {
+	AuditId                       int64           `json:"auditId" form:"auditId" gorm:"primarykey;AUTO_INCREMENT"`
+	AdmissionUid                  string          `json:"admissionUid" form:"admissionUid" gorm:"column:admission_uid;comment:AdmissionReview UID;type:varchar(64);"`
+	Cluster                       string          `json:"cluster" form:"cluster" gorm:"column:cluster;comment:集群ID;type:varchar(128);"`
+	Namespace                     string          `json:"namespace" form:"namespace" gorm:"column:namespace;comment:命名空间;type:varchar(128);"`
+	WorkloadKind                  string          `json:"workloadKind" form:"workloadKind" gorm:"column:workload_kind;comment:工作负载类型;type:varchar(64);"`
+	WorkloadName                  string          `json:"workloadName" form:"workloadName" gorm:"column:workload_name;comment:工作负载名称;type:varchar(255);"`
+	PodGenerateName               string          `json:"podGenerateName" form:"podGenerateName" gorm:"column:pod_generate_name;comment:Pod generateName;type:varchar(255);"`
+	Container                     string          `json:"container" form:"container" gorm:"column:container;comment:容器名称;type:varchar(255);"`
+	ContainerKind                 string          `json:"containerKind" form:"containerKind" gorm:"column:container_kind;comment:容器类型(container/init/sidecar);type:varchar(32);"`
+	MutationMode                  string          `json:"mutationMode" form:"mutationMode" gorm:"column:mutation_mode;comment:变更模式;type:varchar(32);"`
+	Decision                      string          `json:"decision" form:"decision" gorm:"column:decision;comment:决策(patched/skipped);type:varchar(32);"`
+	SkipReason                    string          `json:"skipReason" form:"skipReason" gorm:"column:skip_reason;comment:跳过原因;type:varchar(64);"`
+	OldCpu                        string          `json:"oldCpu" form:"oldCpu" gorm:"column:old_cpu;comment:原CPU request;type:varchar(32);"`
+	NewCpu                        string          `json:"newCpu" form:"newCpu" gorm:"column:new_cpu;comment:新CPU request;type:varchar(32);"`
+	OldMemory                     string          `json:"oldMemory" form:"oldMemory" gorm:"column:old_memory;comment:原内存 request;type:varchar(32);"`
+	NewMemory                     string          `json:"newMemory" form:"newMemory" gorm:"column:new_memory;comment:新内存 request;type:varchar(32);"`
+	RecommendationName            string          `json:"recommendationName" form:"recommendationName" gorm:"column:recommendation_name;comment:Recommendation CR 名称;type:varchar(255);"`
+	RecommendationResourceVersion string          `json:"recommendationResourceVersion" form:"recommendationResourceVersion" gorm:"column:recommendation_resource_version;comment:Recommendation CR resourceVersion;type:varchar(64);"`
+	CreateTime                    common.JSONTime `json:"createTime" form:"createTime" gorm:"column:create_time;comment:准入时间;type:datetime;"`
+}
+
+// TableName AdmissionAudit 表名
+func (AdmissionAudit) TableName() string {
+	return "admission_audit"
+}
+
+// AdmissionAuditSearch 准入审计分页查询条件, 时间格式 2006-01-02 15:04:05
+type AdmissionAuditSearch struct {
+	request.PageInfo
+	Cluster            string `json:"cluster" form:"cluster"`
+	Namespace          string `json:"namespace" form:"namespace"`
+	WorkloadKind       string `json:"workloadKind" form:"workloadKind"`
+	WorkloadName       string `json:"workloadName" form:"workloadName"`
+	PodGenerateName    string `json:"podGenerateName" form:"podGenerateName"`
+	Container          string `json:"container" form:"container"`
+	Decision           string `json:"decision" form:"decision"`
+	SkipReason         string `json:"skipReason" form:"skipReason"`
+	RecommendationName string `json:"recommendationName" form:"recommendationName"`
+	StartTime          string `json:"startTime" form:"startTime"`
+	EndTime            string `json:"endTime" form:"endTime"`
+}
@@ -7,4 +7,6 @@ type AlertRequest struct {
 	EndsAt      string           `json:"endsAt"`
 	Annotations AlertAnnotations `json:"annotations"`
 	Labels      AlertLabels      `json:"labels"`
+	// Fingerprint 预先计算的指纹, 为空时由 Labels 生成; 不从请求体读取
+	Fingerprint string `json:"-"`
 }
@@ -0,0 +1,56 @@
+package observe
+
+import "main.go/model/common"
+
+// AlertRoute 告警路由, 按 parent_id 组成路由树, 与 Alertmanager 的 route 语义一致:
+// 同级路由按 sort_order 依次匹配, 命中后进入其子路由, 子路由都不匹配时由该路由发送;
+// continue 为 false 时命中即停止匹配后续同级路由. channels/receivers 为空时继承父路由,
+// 顶层路由继承 notify.channels 与各渠道配置的默认接收人
+type AlertRoute struct {
+	RouteId    int             `json:"routeId" form:"routeId" gorm:"primarykey;AUTO_INCREMENT"`
+	ParentId   int             `json:"parentId" form:"parentId" gorm:"column:parent_id;comment:父路由ID, 0为顶层路由;type:int;default:0"`
+	Name       string          `json:"name" form:"name" gorm:"column:name;comment:路由名称;type:varchar(128);"`
+	Matchers   LabelMatchers   `json:"matchers" form:"-" gorm:"column:matchers;comment:标签匹配器;type:json;"`
+	Channels   StringList      `json:"channels" form:"-" gorm:"column:channels;comment:通知渠道, 为空继承父路由;type:json;"`
+	Receivers  StringList      `json:"receivers" form:"-" gorm:"column:receivers;comment:接收人, 为空继承父路由;type:json;"`
+	Continue   bool            `json:"continue" form:"continue" gorm:"column:continue_matching;comment:命中后是否继续匹配同级路由;type:tinyint(1);default:0"`
+	SortOrder  int             `json:"sortOrder" form:"sortOrder" gorm:"column:sort_order;comment:同级路由匹配顺序(升序);type:int;default:0"`
+	IsDeleted  int             `json:"isDeleted" form:"isDeleted" gorm:"column:is_deleted;comment:删除标识字段(0-未删除 1-已删除);type:tinyint;default:0"`
+	CreateTime common.JSONTime `json:"createTime" form:"createTime" gorm:"column:create_time;comment:创建时间;type:datetime;"`
+	UpdateTime common.JSONTime `json:"updateTime" form:"updateTime" gorm:"column:update_time;comment:最新修改时间;type:datetime;"`
+}
+
+// TableName AlertRoute 表名
+func (AlertRoute) TableName() string {
+	return "alert_route"
+}
+
+// AlertRouteRequest 创建/更新告警路由请求结构
+type AlertRouteRequest struct {
+	ParentId  int           `json:"parentId"`
+	Name      string        `json:"name" binding:"required"`
+	Matchers  LabelMatchers `json:"matchers"`
+	Channels  []string      `json:"channels"`
+	Receivers []string      `json:"receivers"`
+	Continue  bool          `json:"continue"`
+	SortOrder int           `json:"sortOrder"`
+}
+
+// AlertRouteNode 路由树节点
+type AlertRouteNode struct {
+	AlertRoute
+	Routes []*AlertRouteNode `json:"routes"`
+}
+
+// AlertRouteTestRequest 路由测试请求, 返回给定标签的告警会发往的路由
+type AlertRouteTestRequest struct {
+	Labels AlertLabels `json:"labels"`
+}
+
+// AlertRouteTarget 告警命中的路由及其生效的渠道与接收人, RouteId 为 0 表示未命中任何路由的默认路由
+type AlertRouteTarget struct {
+	RouteId   int      `json:"routeId"`
+	Name      string   `json:"name"`
+	Channels  []string `json:"channels"`  // 为空表示 notify.channels
+	Receivers []string `json:"receivers"` // 为空表示各渠道配置的默认接收人
+}
@@ -0,0 +1,48 @@
+package observe
+
+import (
+	"main.go/model/common"
+	"main.go/model/common/request"
+)
+
+// 静默状态, 由当前时间与 starts_at/ends_at 计算, 不落库
+const (
+	SilencePending = "pending" // 未到开始时间
+	SilenceActive  = "active"  // 生效中
+	SilenceExpired = "expired" // 已过结束时间
+)
+
+// AlertSilence 告警静默, 生效期间匹配的告警照常入库和计数, 但不发送通知
+type AlertSilence struct {
+	SilenceId  int             `json:"silenceId" form:"silenceId" gorm:"primarykey;AUTO_INCREMENT"`
+	Matchers   LabelMatchers   `json:"matchers" form:"-" gorm:"column:matchers;comment:标签匹配器;type:json;"`
+	StartsAt   common.JSONTime `json:"startsAt" form:"startsAt" gorm:"column:starts_at;comment:开始时间;type:datetime;"`
+	EndsAt     common.JSONTime `json:"endsAt" form:"endsAt" gorm:"column:ends_at;comment:结束时间;type:datetime;"`
+	CreatedBy  string          `json:"createdBy" form:"createdBy" gorm:"column:created_by;comment:创建人;type:varchar(64);"`
+	Comment    string          `json:"comment" form:"comment" gorm:"column:comment;comment:静默原因;type:varchar(512);"`
+	Status     string          `json:"status" form:"-" gorm:"-"`
+	IsDeleted  int             `json:"isDeleted" form:"isDeleted" gorm:"column:is_deleted;comment:删除标识字段(0-未删除 1-已删除);type:tinyint;default:0"`
+	CreateTime common.JSONTime `json:"createTime" form:"createTime" gorm:"column:create_time;comment:创建时间;type:datetime;"`
+	UpdateTime common.JSONTime `json:"updateTime" form:"updateTime" gorm:"column:update_time;comment:最新修改时间;type:datetime;"`
+}
+
+// TableName AlertSilence 表名
+func (AlertSilence) TableName() string {
+	return "alert_silence"
+}
+
+// AlertSilenceRequest 创建/更新静默请求结构, 时间为 RFC3339 格式, startsAt 为空时立即生效
+type AlertSilenceRequest struct {
+	Matchers  LabelMatchers `json:"matchers" binding:"required"`
+	StartsAt  string        `json:"startsAt"`
+	EndsAt    string        `json:"endsAt" binding:"required"`
+	CreatedBy string        `json:"createdBy" binding:"required"`
+	Comment   string        `json:"comment" binding:"required"`
+}
+
+// AlertSilenceSearch 静默列表查询条件
+type AlertSilenceSearch struct {
+	request.PageInfo
+	Status    string `json:"status" form:"status"` // pending|active|expired
+	CreatedBy string `json:"createdBy" form:"createdBy"`
+}
@@ -0,0 +1,44 @@
+package observe
+
+// AlertmanagerWebhook Alertmanager webhook(version 4) 推送的分组告警
+type AlertmanagerWebhook struct {
+	Version           string              `json:"version"`
+	GroupKey          string              `json:"groupKey"`
+	TruncatedAlerts   int                 `json:"truncatedAlerts"` // 超过 max_alerts 被截断的告警数
+	Status            string              `json:"status"`          // 分组状态 firing|resolved
+	Receiver          string              `json:"receiver"`
+	GroupLabels       map[string]string   `json:"groupLabels"`
+	CommonLabels      map[string]string   `json:"commonLabels"`
+	CommonAnnotations map[string]string   `json:"commonAnnotations"`
+	ExternalURL       string              `json:"externalURL"`
+	Alerts            []AlertmanagerAlert `json:"alerts" binding:"required"`
+}
+
+// AlertmanagerAlert 单条 Alertmanager 告警, 同时兼容 /api/v2/alerts 的 postableAlert(无 status)
+type AlertmanagerAlert struct {
+	Status       string            `json:"status"`
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations"`
+	StartsAt     string            `json:"startsAt"`
+	EndsAt       string            `json:"endsAt"`
+	GeneratorURL string            `json:"generatorURL"`
+	Fingerprint  string            `json:"fingerprint"`
+}
+
+// 批量接收中单条告警的处理结果
+const (
+	AlertBatchCreated      = "created"      // 新建告警记录
+	AlertBatchDeduplicated = "deduplicated" // 合并到相同指纹的已有记录
+	AlertBatchInvalid      = "invalid"      // 告警内容无效, 未写入
+	AlertBatchFailed       = "failed"       // 写入失败, 已回滚该条
+)
+
+// AlertBatchResult 批量接收中单条告警的处理结果, Index 为告警在请求中的下标
+type AlertBatchResult struct {
+	Index       int    `json:"index"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+	AlertId     int    `json:"alertId,omitempty"`
+	Result      string `json:"result"`
+	Notify      string `json:"notify,omitempty"` // queued|rate_limited|silenced|error, 仅写入成功的告警有
+	Error       string `json:"error,omitempty"`
+}
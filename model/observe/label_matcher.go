@@ -0,0 +1,70 @@
+package observe
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+)
+
+// 标签匹配运算符, 与 Alertmanager 一致
+const (
+	MatchEqual     = "="
+	MatchNotEqual  = "!="
+	MatchRegexp    = "=~"
+	MatchNotRegexp = "!~"
+)
+
+// LabelMatcher 告警标签匹配器, Label 为 AlertLabels 的 json 字段名(如 alert_cluster), 正则需完整匹配
+type LabelMatcher struct {
+	Label string `json:"label" binding:"required"`
+	Op    string `json:"op" binding:"required"` // =|!=|=~|!~
+	Value string `json:"value"`
+}
+
+// LabelMatchers 标签匹配器列表, 全部匹配才算匹配; 以 JSON 存储
+type LabelMatchers []LabelMatcher
+
+// Value 实现 driver.Valuer 接口
+func (m LabelMatchers) Value() (driver.Value, error) {
+	if m == nil {
+		return "[]", nil
+	}
+	return json.Marshal(m)
+}
+
+// Scan 实现 sql.Scanner 接口
+func (m *LabelMatchers) Scan(value interface{}) error {
+	if value == nil {
+		*m = nil
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return json.Unmarshal(bytes, m)
+}
+
+// StringList 字符串列表, 以 JSON 存储
+type StringList []string
+
+// Value 实现 driver.Valuer 接口
+func (l StringList) Value() (driver.Value, error) {
+	if l == nil {
+		return "[]", nil
+	}
+	return json.Marshal(l)
+}
+
+// Scan 实现 sql.Scanner 接口
+func (l *StringList) Scan(value interface{}) error {
+	if value == nil {
+		*l = nil
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return json.Unmarshal(bytes, l)
+}
@@ -0,0 +1,105 @@
+package observe
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// 单个通知渠道的发送结果
+const (
+	NotifySent     = "sent"
+	NotifyFailed   = "failed"   // 达到最大尝试次数仍失败
+	NotifyRetrying = "retrying" // 发送失败, 等待重试
+)
+
+// NotifyChannelResult 单个通知渠道最近一次的发送结果
+type NotifyChannelResult struct {
+	Channel   string    `json:"channel"`
+	RouteId   int       `json:"routeId,omitempty"`   // 命中的告警路由, 0 为默认路由
+	Receivers []string  `json:"receivers,omitempty"` // 为空表示渠道配置的默认接收人
+	Result    string    `json:"result"`              // sent|failed|retrying
+	Attempts  int       `json:"attempts,omitempty"`  // 已尝试次数
+	Error     string    `json:"error,omitempty"`
+	Time      time.Time `json:"time"`
+}
+
+// NotifyResults 各通知渠道的发送结果, 以 JSON 存入 prometheus_alert.notify_result
+type NotifyResults []NotifyChannelResult
+
+// Value 实现 driver.Valuer 接口
+func (r NotifyResults) Value() (driver.Value, error) {
+	if r == nil {
+		return nil, nil
+	}
+	return json.Marshal(r)
+}
+
+// Scan 实现 sql.Scanner 接口, 未发送过通知的记录为 NULL
+func (r *NotifyResults) Scan(value interface{}) error {
+	if value == nil {
+		*r = nil
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return json.Unmarshal(bytes, r)
+}
+
+// WebhookNotifyMessage 通用 webhook 渠道的消息体
+type WebhookNotifyMessage struct {
+	Title     string          `json:"title"`
+	Receivers []string        `json:"receivers,omitempty"`
+	Detail    MQAlertDetail   `json:"detail"`
+	Alert     PrometheusAlert `json:"alert"`
+}
+
+// WecomMessage 企业微信群机器人 markdown 消息
+type WecomMessage struct {
+	MsgType  string        `json:"msgtype"`
+	Markdown WecomMarkdown `json:"markdown"`
+}
+
+type WecomMarkdown struct {
+	Content string `json:"content"`
+}
+
+// DingtalkMessage 钉钉群机器人 markdown 消息
+type DingtalkMessage struct {
+	MsgType  string           `json:"msgtype"`
+	Markdown DingtalkMarkdown `json:"markdown"`
+	At       DingtalkAt       `json:"at"`
+}
+
+type DingtalkMarkdown struct {
+	Title string `json:"title"`
+	Text  string `json:"text"`
+}
+
+type DingtalkAt struct {
+	AtMobiles []string `json:"atMobiles,omitempty"`
+	IsAtAll   bool     `json:"isAtAll"`
+}
+
+// FeishuMessage 飞书群机器人文本消息, 配置加签时携带 timestamp 与 sign
+type FeishuMessage struct {
+	Timestamp string        `json:"timestamp,omitempty"`
+	Sign      string        `json:"sign,omitempty"`
+	MsgType   string        `json:"msg_type"`
+	Content   FeishuContent `json:"content"`
+}
+
+type FeishuContent struct {
+	Text string `json:"text"`
+}
+
+// BotResponse IM 机器人的响应, 企业微信/钉钉使用 errcode, 飞书使用 code
+type BotResponse struct {
+	ErrCode int    `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+	Code    int    `json:"code"`
+	Msg     string `json:"msg"`
+}
@@ -0,0 +1,52 @@
+package observe
+
+import (
+	"time"
+
+	"main.go/model/common"
+	"main.go/model/common/request"
+)
+
+// 通知发件箱记录状态
+const (
+	OutboxPending = "pending" // 等待发送或等待重试
+	OutboxSending = "sending" // 已被工作协程领取, 租约到期未完成时重新变为 pending
+	OutboxSent    = "sent"    // 发送成功
+	OutboxDead    = "dead"    // 达到最大尝试次数, 需人工重新投递
+)
+
+// NotifyOutbox 告警通知发件箱, 每条记录是一次告警经由一个渠道发给一组接收人的通知
+type NotifyOutbox struct {
+	OutboxId      int64           `json:"outboxId" form:"outboxId" gorm:"primarykey;AUTO_INCREMENT"`
+	AlertId       int             `json:"alertId" form:"alertId" gorm:"column:alert_id;comment:告警ID;type:int;"`
+	RouteId       int             `json:"routeId" form:"routeId" gorm:"column:route_id;comment:命中的告警路由, 0为默认路由;type:int;default:0"`
+	Channel       string          `json:"channel" form:"channel" gorm:"column:channel;comment:通知渠道;type:varchar(32);"`
+	Receivers     StringList      `json:"receivers" form:"-" gorm:"column:receivers;comment:接收人, 为空表示渠道默认接收人;type:json;"`
+	Status        string          `json:"status" form:"status" gorm:"column:status;comment:状态(pending/sending/sent/dead);type:varchar(16);"`
+	Attempts      int             `json:"attempts" form:"attempts" gorm:"column:attempts;comment:已尝试次数;type:int;default:0"`
+	NextAttemptAt time.Time       `json:"nextAttemptAt" form:"-" gorm:"column:next_attempt_at;comment:下次尝试时间;type:datetime;"`
+	LockedBy      string          `json:"lockedBy" form:"-" gorm:"column:locked_by;comment:领取该记录的实例;type:varchar(128);"`
+	LockedUntil   *time.Time      `json:"lockedUntil" form:"-" gorm:"column:locked_until;comment:领取租约到期时间;type:datetime;"`
+	LastError     string          `json:"lastError" form:"-" gorm:"column:last_error;comment:最近一次失败原因;type:varchar(1024);"`
+	SentTime      *time.Time      `json:"sentTime" form:"-" gorm:"column:sent_time;comment:发送成功时间;type:datetime;"`
+	CreateTime    common.JSONTime `json:"createTime" form:"createTime" gorm:"column:create_time;comment:创建时间;type:datetime;"`
+	UpdateTime    common.JSONTime `json:"updateTime" form:"updateTime" gorm:"column:update_time;comment:最新修改时间;type:datetime;"`
+}
+
+// TableName NotifyOutbox 表名
+func (NotifyOutbox) TableName() string {
+	return "alert_notify_outbox"
+}
+
+// NotifyOutboxSearch 发件箱列表查询条件
+type NotifyOutboxSearch struct {
+	request.PageInfo
+	AlertId int    `json:"alertId" form:"alertId"`
+	Channel string `json:"channel" form:"channel"`
+	Status  string `json:"status" form:"status"`
+}
+
+// NotifyOutboxIdsReq 批量重新投递的发件箱记录ID
+type NotifyOutboxIdsReq struct {
+	Ids []int64 `json:"ids" form:"ids"`
+}
@@ -89,6 +89,8 @@ type PrometheusAlert struct {
 	DailyNotifyCount int              `json:"dailyNotifyCount" form:"dailyNotifyCount" gorm:"column:daily_notify_count;comment:当日通知次数;type:int;default:0"`
 	LastNotifyDate   *time.Time       `json:"lastNotifyDate" form:"lastNotifyDate" gorm:"column:last_notify_date;comment:最后通知日期;type:date;"`
 	NotifyPending    bool             `json:"notifyPending" form:"notifyPending" gorm:"column:notify_pending;comment:是否有待发送的通知;type:tinyint(1);default:0"`
+	NotifyResult     NotifyResults    `json:"notifyResult" form:"-" gorm:"column:notify_result;comment:各通知渠道最近一次发送结果;type:json;"`
+	SilenceId        int              `json:"silenceId" form:"silenceId" gorm:"column:silence_id;comment:最近一次抑制通知的静默ID, 0为未被静默;type:int;default:0"`
 	IsDeleted        int              `json:"isDeleted" form:"isDeleted" gorm:"column:is_deleted;comment:删除标识字段(0-未删除 1-已删除);type:tinyint;default:0;uniqueIndex:uq_fingerprint_not_deleted"`
 	CreateTime       common.JSONTime  `json:"createTime" form:"createTime" gorm:"column:create_time;comment:创建时间;type:datetime;"`
 	UpdateTime       common.JSONTime  `json:"updateTime" form:"updateTime" gorm:"column:update_time;comment:最新修改时间;type:datetime;"`
@@ -1,19 +1,111 @@
 package webhook
 
+import (
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
 type RecommendedValue struct {
 	ResourceRequest struct {
-		Containers []struct {
-			ContainerName string `yaml:"containerName"`
-			Target        struct {
-				CPU    string `yaml:"cpu"`
-				Memory string `yaml:"memory"`
-			} `yaml:"target"`
-		} `yaml:"containers"`
+		Containers []ContainerRecommendation `yaml:"containers"`
+		// InitContainers covers spec.initContainers, including native sidecars (restartPolicy: Always).
+		// Container names are unique across a pod, so entries listed under containers also match init containers.
+		InitContainers []ContainerRecommendation `yaml:"initContainers"`
 	} `yaml:"resourceRequest"`
 }
 
+// ContainerRecommendation is the recommended target for a single container.
+type ContainerRecommendation struct {
+	ContainerName string `yaml:"containerName"`
+	// Target holds the recommended request by resource name, e.g. cpu, memory, ephemeral-storage,
+	// hugepages-2Mi or nvidia.com/gpu. Values are Kubernetes quantities.
+	Target map[corev1.ResourceName]string `yaml:"target"`
+}
+
 type JSONPatch struct {
 	Op    string      `json:"op"`
 	Path  string      `json:"path"`
-	Value interface{} `json:"value"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// WorkloadRef identifies the top-level workload that owns a pod.
+type WorkloadRef struct {
+	APIVersion string
+	Kind       string
+	Name       string
+	// UID is taken from the ownerReference and is empty when the workload was not found that way.
+	UID types.UID
+}
+
+// AppliedRecommendation is the value of the finops.io/recommendation annotation on a mutated pod.
+type AppliedRecommendation struct {
+	Namespace       string `json:"namespace"`
+	Name            string `json:"name"`
+	ResourceVersion string `json:"resourceVersion"`
+}
+
+// MutationResult is what MutatePod decided for one pod admission.
+type MutationResult struct {
+	Patches []JSONPatch
+	// Warnings are returned to the client in AdmissionResponse.warnings.
+	Warnings []string
+	// AuditAnnotations are recorded in the API server audit log under the webhook's name.
+	AuditAnnotations map[string]string
+	// SkipReason explains why no patch was applied; empty when the pod was patched.
+	SkipReason string
+	// DryRun is set for a server-side dry-run admission, whose patch is returned but never persisted.
+	DryRun bool
+
+	// The fields below describe the decision for the admission audit log and are filled in as far
+	// as MutatePod got before it decided.
+	Cluster                       string
+	Mode                          string
+	Workload                      WorkloadRef
+	RecommendationName            string
+	RecommendationResourceVersion string
+	Containers                    []ContainerDecision
+}
+
+// ContainerDecision is the outcome for one container. New values are the requests after the patch
+// and stay empty when the container had no recommendation.
+type ContainerDecision struct {
+	Name      string
+	Kind      string
+	OldCPU    string
+	NewCPU    string
+	OldMemory string
+	NewMemory string
+	// Reason is DecisionReasonPatched or the reason the container was left unchanged.
+	Reason string
+}
+
+// AppliedChange is one write of a recommendation to the pod template of its target workload by the
+// controller. The latest changes are kept, newest first, in an annotation on the Recommendation CR.
+type AppliedChange struct {
+	Time                          time.Time          `json:"time"`
+	Cluster                       string             `json:"cluster,omitempty"`
+	Kind                          string             `json:"kind"`
+	Namespace                     string             `json:"namespace"`
+	Name                          string             `json:"name"`
+	RecommendationResourceVersion string             `json:"recommendationResourceVersion"`
+	Containers                    []AppliedContainer `json:"containers"`
+}
+
+// AppliedContainer is the change made to one container of the pod template.
+type AppliedContainer struct {
+	Name         string            `json:"name"`
+	OldRequests  map[string]string `json:"oldRequests,omitempty"`
+	Requests     map[string]string `json:"requests,omitempty"`
+	Limits       map[string]string `json:"limits,omitempty"`
+	RemoveLimits []string          `json:"removeLimits,omitempty"`
+}
+
+// AddAuditAnnotation sets an audit annotation, creating the map on first use.
+func (r *MutationResult) AddAuditAnnotation(key, value string) {
+	if r.AuditAnnotations == nil {
+		r.AuditAnnotations = map[string]string{}
+	}
+	r.AuditAnnotations[key] = value
 }
@@ -0,0 +1,18 @@
+package observe
+
+import (
+	"github.com/gin-gonic/gin"
+	v1 "main.go/api/v1"
+)
+
+type AdmissionAuditRouter struct {
+}
+
+func (r *AdmissionAuditRouter) InitAdmissionAuditRouter(Router *gin.RouterGroup) {
+	auditRouter := Router
+	var auditApi = v1.ApiGroupApp.ObserveApiGroup.AdmissionAuditApi
+	{
+		auditRouter.GET("admission-audits/:auditId", auditApi.GetAdmissionAudit)
+		auditRouter.GET("admission-audits", auditApi.GetAdmissionAuditList)
+	}
+}
@@ -0,0 +1,22 @@
+package observe
+
+import (
+	"github.com/gin-gonic/gin"
+	v1 "main.go/api/v1"
+)
+
+type AlertRouteRouter struct {
+}
+
+func (r *AlertRouteRouter) InitAlertRouteRouter(Router *gin.RouterGroup) {
+	routeRouter := Router
+	var routeApi = v1.ApiGroupApp.ObserveApiGroup.AlertRouteApi
+	{
+		routeRouter.POST("alert-routes", routeApi.CreateRoute)
+		routeRouter.POST("alert-routes/test", routeApi.TestRoute)
+		routeRouter.PUT("alert-routes/:routeId", routeApi.UpdateRoute)
+		routeRouter.DELETE("alert-routes/:routeId", routeApi.DeleteRoute)
+		routeRouter.GET("alert-routes/:routeId", routeApi.GetRoute)
+		routeRouter.GET("alert-routes", routeApi.GetRouteTree)
+	}
+}
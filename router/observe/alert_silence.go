@@ -0,0 +1,21 @@
+package observe
+
+import (
+	"github.com/gin-gonic/gin"
+	v1 "main.go/api/v1"
+)
+
+type AlertSilenceRouter struct {
+}
+
+func (r *AlertSilenceRouter) InitAlertSilenceRouter(Router *gin.RouterGroup) {
+	silenceRouter := Router
+	var silenceApi = v1.ApiGroupApp.ObserveApiGroup.AlertSilenceApi
+	{
+		silenceRouter.POST("alert-silences", silenceApi.CreateSilence)
+		silenceRouter.PUT("alert-silences/:silenceId", silenceApi.UpdateSilence)
+		silenceRouter.DELETE("alert-silences/:silenceId", silenceApi.DeleteSilence)
+		silenceRouter.GET("alert-silences/:silenceId", silenceApi.GetSilence)
+		silenceRouter.GET("alert-silences", silenceApi.GetSilenceList)
+	}
+}
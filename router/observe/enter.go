@@ -2,4 +2,8 @@ package observe
 
 type ObserveRouterGroup struct {
 	ObserveAlertRouter
+	AdmissionAuditRouter
+	AlertRouteRouter
+	NotifyOutboxRouter
+	AlertSilenceRouter
 }
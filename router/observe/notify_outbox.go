@@ -0,0 +1,20 @@
+package observe
+
+import (
+	"github.com/gin-gonic/gin"
+	v1 "main.go/api/v1"
+)
+
+type NotifyOutboxRouter struct {
+}
+
+func (r *NotifyOutboxRouter) InitNotifyOutboxRouter(Router *gin.RouterGroup) {
+	outboxRouter := Router
+	var outboxApi = v1.ApiGroupApp.ObserveApiGroup.NotifyOutboxApi
+	{
+		outboxRouter.POST("alert-notifications/retry", outboxApi.RetryNotificationBatch)
+		outboxRouter.POST("alert-notifications/:outboxId/retry", outboxApi.RetryNotification)
+		outboxRouter.GET("alert-notifications/:outboxId", outboxApi.GetNotification)
+		outboxRouter.GET("alert-notifications", outboxApi.GetNotificationList)
+	}
+}
@@ -13,6 +13,7 @@ func (r *ObserveAlertRouter) InitObserveAlertRouter(Router *gin.RouterGroup) {
 	var alertApi = v1.ApiGroupApp.ObserveApiGroup.ObserveAlertApi
 	{
 		alertRouter.POST("alerts", alertApi.CreateAlert)
+		alertRouter.POST("alerts/alertmanager", alertApi.ReceiveAlertmanagerWebhook)
 		alertRouter.DELETE("alerts/:alertId", alertApi.DeleteAlert)
 		alertRouter.DELETE("alerts", alertApi.DeleteAlertBatch)
 		alertRouter.PUT("alerts/:alertId", alertApi.UpdateAlert)
@@ -20,3 +21,9 @@ func (r *ObserveAlertRouter) InitObserveAlertRouter(Router *gin.RouterGroup) {
 		alertRouter.GET("alerts", alertApi.GetAlertList)
 	}
 }
+
+// InitAlertmanagerRouter 注册 Alertmanager API 兼容路由, Router 应挂载在 api/v2 下
+func (r *ObserveAlertRouter) InitAlertmanagerRouter(Router *gin.RouterGroup) {
+	var alertApi = v1.ApiGroupApp.ObserveApiGroup.ObserveAlertApi
+	Router.POST("alerts", alertApi.PostAlertsV2)
+}
@@ -10,4 +10,7 @@ type WebhookRouter struct{}
 func (s *WebhookRouter) InitWebhookRouter(Router *gin.RouterGroup) {
 	webhookApi := v1.ApiGroupApp.WebhookApiGroup.RecommendationApi
 	Router.POST("mutate", webhookApi.ServeMutate)
+	Router.POST("mutate/:clusterId", webhookApi.ServeMutate)
+	Router.GET("readyz", webhookApi.Readyz)
+	Router.GET("livez", webhookApi.Livez)
 }
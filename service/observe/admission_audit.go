@@ -0,0 +1,80 @@
+package observe
+
+import (
+	"fmt"
+	"time"
+
+	"main.go/global"
+	"main.go/model/observe"
+)
+
+// admissionAuditTimeLayout 查询条件中的时间格式, 与 common.JSONTime 输出一致
+const admissionAuditTimeLayout = "2006-01-02 15:04:05"
+
+type AdmissionAuditService struct {
+}
+
+// GetAdmissionAudit 根据ID获取准入审计记录
+func (m *AdmissionAuditService) GetAdmissionAudit(id int) (err error, audit observe.AdmissionAudit) {
+	err = global.GVA_DB.Where("audit_id = ?", id).First(&audit).Error
+	return err, audit
+}
+
+// GetAdmissionAuditList 分页获取准入审计记录
+func (m *AdmissionAuditService) GetAdmissionAuditList(search observe.AdmissionAuditSearch) (err error, list []observe.AdmissionAudit, total int64) {
+	limit := search.PageSize
+	if limit == 0 {
+		limit = 10
+	}
+	offset := limit * (search.PageNumber - 1)
+	if search.PageNumber == 0 {
+		offset = 0
+	}
+
+	db := global.GVA_DB.Model(&observe.AdmissionAudit{})
+
+	// 精确匹配条件
+	for _, cond := range [][2]string{
+		{"cluster", search.Cluster},
+		{"namespace", search.Namespace},
+		{"workload_kind", search.WorkloadKind},
+		{"workload_name", search.WorkloadName},
+		{"container", search.Container},
+		{"decision", search.Decision},
+		{"skip_reason", search.SkipReason},
+	} {
+		if cond[1] != "" {
+			db = db.Where(cond[0]+" = ?", cond[1])
+		}
+	}
+	// 模糊匹配条件
+	if search.PodGenerateName != "" {
+		db = db.Where("pod_generate_name LIKE ?", "%"+search.PodGenerateName+"%")
+	}
+	if search.RecommendationName != "" {
+		db = db.Where("recommendation_name LIKE ?", "%"+search.RecommendationName+"%")
+	}
+
+	if search.StartTime != "" {
+		startTime, parseErr := time.ParseInLocation(admissionAuditTimeLayout, search.StartTime, time.Local)
+		if parseErr != nil {
+			return fmt.Errorf("startTime 格式错误: %w", parseErr), list, total
+		}
+		db = db.Where("create_time >= ?", startTime)
+	}
+	if search.EndTime != "" {
+		endTime, parseErr := time.ParseInLocation(admissionAuditTimeLayout, search.EndTime, time.Local)
+		if parseErr != nil {
+			return fmt.Errorf("endTime 格式错误: %w", parseErr), list, total
+		}
+		db = db.Where("create_time <= ?", endTime)
+	}
+
+	err = db.Count(&total).Error
+	if err != nil {
+		return
+	}
+
+	err = db.Limit(limit).Offset(offset).Order("create_time desc, audit_id desc").Find(&list).Error
+	return err, list, total
+}
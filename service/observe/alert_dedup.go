@@ -88,7 +88,8 @@ func (s *AlertDedupService) ConfirmNotifySent(alertId int) error {
 // TryReserveNotification 尝试原子预占通知配额
 // 返回 true 表示成功预占，可以发送通知
 // 使用乐观锁：UPDATE ... WHERE daily_notify_count < limit
-func (s *AlertDedupService) TryReserveNotification(alertId int) (bool, error) {
+// db 为写入告警的事务, 预占与通知入箱一同提交或回滚
+func (s *AlertDedupService) TryReserveNotification(db *gorm.DB, alertId int) (bool, error) {
 	dailyLimit := global.GVA_CONFIG.MQ.DailyNotifyLimit
 	if dailyLimit <= 0 {
 		// 不限制，直接返回成功
@@ -100,7 +101,7 @@ func (s *AlertDedupService) TryReserveNotification(alertId int) (bool, error) {
 
 	// 原子操作：检查并预占配额
 	// 条件：同一天且未达上限，或者跨天（重置计数）
-	result := global.GVA_DB.Model(&observe.PrometheusAlert{}).
+	result := db.Model(&observe.PrometheusAlert{}).
 		Where("alert_id = ?", alertId).
 		Where("(DATE(last_notify_date) = ? AND daily_notify_count < ?) OR last_notify_date IS NULL OR DATE(last_notify_date) != ?",
 			today, dailyLimit, today).
@@ -125,6 +126,13 @@ func (s *AlertDedupService) RollbackNotification(alertId int) error {
 		UpdateColumn("daily_notify_count", gorm.Expr("GREATEST(daily_notify_count - 1, 0)")).Error
 }
 
+// RecordSilence 记录抑制告警通知的静默ID, 0 表示未被静默
+func (s *AlertDedupService) RecordSilence(db *gorm.DB, alertId int, silenceId int) error {
+	return db.Model(&observe.PrometheusAlert{}).
+		Where("alert_id = ?", alertId).
+		UpdateColumn("silence_id", silenceId).Error
+}
+
 // ResetDailyNotifyCount 重置每日通知计数(跨天时调用)
 func (s *AlertDedupService) ResetDailyNotifyCount(alert *observe.PrometheusAlert) {
 	now := time.Now()
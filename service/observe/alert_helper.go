@@ -22,6 +22,22 @@ func MapStatus(status string) string {
 	}
 }
 
+// MapSeverity 等级映射 (Critical → 紧急, High → 严重, Warning → 警告, Low → 轻微)
+func MapSeverity(severity string) string {
+	switch severity {
+	case "Critical":
+		return "紧急"
+	case "High":
+		return "严重"
+	case "Warning":
+		return "警告"
+	case "Low":
+		return "轻微"
+	default:
+		return "一般"
+	}
+}
+
 // MapObjectKind 对象类型映射(K8s常见资源)
 func MapObjectKind(kind string) string {
 	switch kind {
@@ -0,0 +1,260 @@
+package observe
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"main.go/global"
+	"main.go/model/common"
+	"main.go/model/observe"
+)
+
+type AlertRouteService struct {
+}
+
+// routeTreeTTL 路由树缓存有效期; 本实例修改路由时立即失效, 其他副本的修改在有效期后生效
+const routeTreeTTL = 30 * time.Second
+
+// compiledRoute 编译后的路由树节点, 根节点为未命中任何路由时使用的默认路由
+type compiledRoute struct {
+	route    observe.AlertRoute
+	matchers []labelMatcher
+	children []*compiledRoute
+}
+
+var routeTreeCache struct {
+	sync.Mutex
+	root     *compiledRoute
+	loadedAt time.Time
+}
+
+// CreateRoute 创建告警路由
+func (s *AlertRouteService) CreateRoute(req observe.AlertRouteRequest) (err error, route observe.AlertRoute) {
+	if err = validateRoute(0, req); err != nil {
+		return err, route
+	}
+	now := common.JSONTime{Time: time.Now()}
+	route = newAlertRoute(req)
+	route.CreateTime = now
+	route.UpdateTime = now
+	err = global.GVA_DB.Create(&route).Error
+	invalidateRouteTree()
+	return err, route
+}
+
+// UpdateRoute 更新告警路由
+func (s *AlertRouteService) UpdateRoute(id int, req observe.AlertRouteRequest) (err error) {
+	if err = validateRoute(id, req); err != nil {
+		return err
+	}
+	route := newAlertRoute(req)
+	err = global.GVA_DB.Model(&observe.AlertRoute{}).Where("route_id = ? AND is_deleted = 0", id).Updates(map[string]interface{}{
+		"parent_id":         route.ParentId,
+		"name":              route.Name,
+		"matchers":          route.Matchers,
+		"channels":          route.Channels,
+		"receivers":         route.Receivers,
+		"continue_matching": route.Continue,
+		"sort_order":        route.SortOrder,
+		"update_time":       common.JSONTime{Time: time.Now()},
+	}).Error
+	invalidateRouteTree()
+	return err
+}
+
+// DeleteRoute 删除告警路由（软删除）, 有子路由时需先删除子路由
+func (s *AlertRouteService) DeleteRoute(id int) (err error) {
+	var children int64
+	if err = global.GVA_DB.Model(&observe.AlertRoute{}).Where("parent_id = ? AND is_deleted = 0", id).Count(&children).Error; err != nil {
+		return err
+	}
+	if children > 0 {
+		return errors.New("存在子路由, 请先删除子路由")
+	}
+	err = global.GVA_DB.Model(&observe.AlertRoute{}).Where("route_id = ?", id).Updates(map[string]interface{}{
+		"is_deleted":  1,
+		"update_time": common.JSONTime{Time: time.Now()},
+	}).Error
+	invalidateRouteTree()
+	return err
+}
+
+// GetRoute 根据ID获取告警路由
+func (s *AlertRouteService) GetRoute(id int) (err error, route observe.AlertRoute) {
+	err = global.GVA_DB.Where("route_id = ? AND is_deleted = 0", id).First(&route).Error
+	return err, route
+}
+
+// GetRouteTree 获取完整路由树, 返回顶层路由
+func (s *AlertRouteService) GetRouteTree() (err error, tree []*observe.AlertRouteNode) {
+	routes, err := listRoutes()
+	if err != nil {
+		return err, nil
+	}
+	nodes := make(map[int]*observe.AlertRouteNode, len(routes))
+	for _, r := range routes {
+		nodes[r.RouteId] = &observe.AlertRouteNode{AlertRoute: r, Routes: []*observe.AlertRouteNode{}}
+	}
+	tree = []*observe.AlertRouteNode{}
+	for _, r := range routes {
+		if r.ParentId == 0 {
+			tree = append(tree, nodes[r.RouteId])
+		} else if parent, ok := nodes[r.ParentId]; ok {
+			parent.Routes = append(parent.Routes, nodes[r.RouteId])
+		}
+	}
+	return nil, tree
+}
+
+// TestRoute 返回给定标签的告警会发往的路由, 不发送通知
+func (s *AlertRouteService) TestRoute(labels observe.AlertLabels) (err error, targets []observe.AlertRouteTarget) {
+	root, err := cachedRouteTree()
+	if err != nil {
+		return err, nil
+	}
+	return nil, root.match(labels, observe.AlertRouteTarget{})
+}
+
+// routeAlert 按路由树计算告警的通知目标; 路由树加载失败时使用默认路由, 保证通知不丢失
+func routeAlert(labels observe.AlertLabels) []observe.AlertRouteTarget {
+	root, err := cachedRouteTree()
+	if err != nil {
+		global.GVA_LOG.Error("加载告警路由失败, 使用默认路由", zap.Error(err))
+		return []observe.AlertRouteTarget{defaultRouteTarget()}
+	}
+	return root.match(labels, observe.AlertRouteTarget{})
+}
+
+func defaultRouteTarget() observe.AlertRouteTarget {
+	return observe.AlertRouteTarget{Name: "default"}
+}
+
+// match 返回告警在该路由下命中的最深路由, 子路由都不匹配时返回该路由自身
+func (r *compiledRoute) match(labels observe.AlertLabels, parent observe.AlertRouteTarget) []observe.AlertRouteTarget {
+	target := parent
+	if r.route.RouteId == 0 {
+		target = defaultRouteTarget()
+	} else {
+		target.RouteId = r.route.RouteId
+		target.Name = r.route.Name
+		if len(r.route.Channels) > 0 {
+			target.Channels = r.route.Channels
+		}
+		if len(r.route.Receivers) > 0 {
+			target.Receivers = r.route.Receivers
+		}
+	}
+
+	var targets []observe.AlertRouteTarget
+	for _, child := range r.children {
+		if !matchAll(child.matchers, labels) {
+			continue
+		}
+		targets = append(targets, child.match(labels, target)...)
+		if !child.route.Continue {
+			break
+		}
+	}
+	if len(targets) == 0 {
+		targets = []observe.AlertRouteTarget{target}
+	}
+	return targets
+}
+
+// cachedRouteTree 返回缓存的路由树, 过期时重新从数据库加载
+func cachedRouteTree() (*compiledRoute, error) {
+	routeTreeCache.Lock()
+	defer routeTreeCache.Unlock()
+	if routeTreeCache.root != nil && time.Since(routeTreeCache.loadedAt) < routeTreeTTL {
+		return routeTreeCache.root, nil
+	}
+	root, err := loadRouteTree()
+	if err != nil {
+		return nil, err
+	}
+	routeTreeCache.root = root
+	routeTreeCache.loadedAt = time.Now()
+	return root, nil
+}
+
+// invalidateRouteTree 使路由树缓存失效, 下次通知时重新加载
+func invalidateRouteTree() {
+	routeTreeCache.Lock()
+	defer routeTreeCache.Unlock()
+	routeTreeCache.root = nil
+}
+
+// loadRouteTree 从数据库加载并编译路由树; 匹配器无效或父路由已删除的路由记录日志后跳过
+func loadRouteTree() (*compiledRoute, error) {
+	routes, err := listRoutes()
+	if err != nil {
+		return nil, err
+	}
+	root := &compiledRoute{}
+	nodes := map[int]*compiledRoute{0: root}
+	for _, r := range routes {
+		matchers, err := compileMatchers(r.Matchers)
+		if err != nil {
+			global.GVA_LOG.Warn("告警路由匹配器无效, 已跳过", zap.Int("routeId", r.RouteId), zap.Error(err))
+			continue
+		}
+		nodes[r.RouteId] = &compiledRoute{route: r, matchers: matchers}
+	}
+	for _, r := range routes {
+		node, ok := nodes[r.RouteId]
+		if !ok {
+			continue
+		}
+		parent, ok := nodes[r.ParentId]
+		if !ok {
+			global.GVA_LOG.Warn("告警路由的父路由不存在, 已跳过", zap.Int("routeId", r.RouteId), zap.Int("parentId", r.ParentId))
+			continue
+		}
+		parent.children = append(parent.children, node)
+	}
+	return root, nil
+}
+
+// listRoutes 按匹配顺序列出全部路由
+func listRoutes() (routes []observe.AlertRoute, err error) {
+	err = global.GVA_DB.Where("is_deleted = 0").Order("sort_order, route_id").Find(&routes).Error
+	return routes, err
+}
+
+func newAlertRoute(req observe.AlertRouteRequest) observe.AlertRoute {
+	return observe.AlertRoute{
+		ParentId:  req.ParentId,
+		Name:      req.Name,
+		Matchers:  req.Matchers,
+		Channels:  req.Channels,
+		Receivers: req.Receivers,
+		Continue:  req.Continue,
+		SortOrder: req.SortOrder,
+	}
+}
+
+// validateRoute 校验匹配器、渠道与父路由; 更新时父路由不能是自身或其子孙路由
+func validateRoute(id int, req observe.AlertRouteRequest) error {
+	if _, err := compileMatchers(req.Matchers); err != nil {
+		return err
+	}
+	for _, channel := range req.Channels {
+		if !notifierRegistered(channel) {
+			return fmt.Errorf("未知的通知渠道: %s", channel)
+		}
+	}
+	for parentId := req.ParentId; parentId != 0; {
+		if parentId == id {
+			return errors.New("父路由不能是自身或其子路由")
+		}
+		var parent observe.AlertRoute
+		if err := global.GVA_DB.Where("route_id = ? AND is_deleted = 0", parentId).First(&parent).Error; err != nil {
+			return fmt.Errorf("父路由 %d 不存在: %w", parentId, err)
+		}
+		parentId = parent.ParentId
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+package observe
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"main.go/global"
+	"main.go/model/common"
+	"main.go/model/observe"
+)
+
+type AlertSilenceService struct {
+}
+
+// silenceTTL 静默缓存有效期; 本实例修改静默时立即失效, 其他副本的修改在有效期后生效
+const silenceTTL = 30 * time.Second
+
+// compiledSilence 编译后的静默
+type compiledSilence struct {
+	silence  observe.AlertSilence
+	matchers []labelMatcher
+}
+
+// silenceCache 加载时未过期的静默(含未开始的), 按创建顺序排列; 是否生效在匹配时按当前时间判断
+var silenceCache struct {
+	sync.Mutex
+	silences []compiledSilence
+	loaded   bool
+	loadedAt time.Time
+}
+
+// CreateSilence 创建静默
+func (s *AlertSilenceService) CreateSilence(req observe.AlertSilenceRequest) (err error, silence observe.AlertSilence) {
+	now := time.Now()
+	silence, err = newAlertSilence(req, now)
+	if err != nil {
+		return err, silence
+	}
+	silence.CreateTime = common.JSONTime{Time: now}
+	silence.UpdateTime = common.JSONTime{Time: now}
+	err = global.GVA_DB.Create(&silence).Error
+	invalidateSilences()
+	if err != nil {
+		return err, silence
+	}
+	silence.Status = silenceStatus(silence, now)
+	return nil, silence
+}
+
+// UpdateSilence 更新静默, 修改 endsAt 为当前时间即可提前结束
+func (s *AlertSilenceService) UpdateSilence(id int, req observe.AlertSilenceRequest) (err error) {
+	now := time.Now()
+	silence, err := newAlertSilence(req, now)
+	if err != nil {
+		return err
+	}
+	err = global.GVA_DB.Model(&observe.AlertSilence{}).Where("silence_id = ? AND is_deleted = 0", id).Updates(map[string]interface{}{
+		"matchers":    silence.Matchers,
+		"starts_at":   silence.StartsAt,
+		"ends_at":     silence.EndsAt,
+		"created_by":  silence.CreatedBy,
+		"comment":     silence.Comment,
+		"update_time": common.JSONTime{Time: now},
+	}).Error
+	invalidateSilences()
+	return err
+}
+
+// DeleteSilence 删除静默（软删除）
+func (s *AlertSilenceService) DeleteSilence(id int) (err error) {
+	err = global.GVA_DB.Model(&observe.AlertSilence{}).Where("silence_id = ?", id).Updates(map[string]interface{}{
+		"is_deleted":  1,
+		"update_time": common.JSONTime{Time: time.Now()},
+	}).Error
+	invalidateSilences()
+	return err
+}
+
+// GetSilence 根据ID获取静默
+func (s *AlertSilenceService) GetSilence(id int) (err error, silence observe.AlertSilence) {
+	err = global.GVA_DB.Where("silence_id = ? AND is_deleted = 0", id).First(&silence).Error
+	silence.Status = silenceStatus(silence, time.Now())
+	return err, silence
+}
+
+// GetSilenceList 分页获取静默列表, 可按状态和创建人过滤
+func (s *AlertSilenceService) GetSilenceList(info observe.AlertSilenceSearch) (err error, list []observe.AlertSilence, total int64) {
+	limit := info.PageSize
+	if limit == 0 {
+		limit = 10
+	}
+	offset := limit * (info.PageNumber - 1)
+	if info.PageNumber == 0 {
+		offset = 0
+	}
+
+	now := time.Now()
+	db := global.GVA_DB.Model(&observe.AlertSilence{}).Where("is_deleted = 0")
+
+	switch info.Status {
+	case observe.SilencePending:
+		db = db.Where("starts_at > ?", now)
+	case observe.SilenceActive:
+		db = db.Where("starts_at <= ? AND ends_at > ?", now, now)
+	case observe.SilenceExpired:
+		db = db.Where("ends_at <= ?", now)
+	}
+
+	if info.CreatedBy != "" {
+		db = db.Where("created_by = ?", info.CreatedBy)
+	}
+
+	err = db.Count(&total).Error
+	if err != nil {
+		return
+	}
+
+	err = db.Limit(limit).Offset(offset).Order("create_time desc").Find(&list).Error
+	for i := range list {
+		list[i].Status = silenceStatus(list[i], now)
+	}
+	return err, list, total
+}
+
+// matchSilence 返回当前生效且匹配告警标签的静默, 多个匹配时取最早创建的; 没有匹配时返回 nil
+func matchSilence(labels observe.AlertLabels, now time.Time) (*observe.AlertSilence, error) {
+	silences, err := cachedSilences()
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range silences {
+		if now.Before(s.silence.StartsAt.Time) || !now.Before(s.silence.EndsAt.Time) {
+			continue
+		}
+		if matchAll(s.matchers, labels) {
+			silence := s.silence
+			return &silence, nil
+		}
+	}
+	return nil, nil
+}
+
+// cachedSilences 返回缓存的静默, 过期时重新从数据库加载
+func cachedSilences() ([]compiledSilence, error) {
+	silenceCache.Lock()
+	defer silenceCache.Unlock()
+	if silenceCache.loaded && time.Since(silenceCache.loadedAt) < silenceTTL {
+		return silenceCache.silences, nil
+	}
+	silences, err := loadSilences(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	silenceCache.silences = silences
+	silenceCache.loaded = true
+	silenceCache.loadedAt = time.Now()
+	return silences, nil
+}
+
+// invalidateSilences 使静默缓存失效, 下次通知时重新加载
+func invalidateSilences() {
+	silenceCache.Lock()
+	defer silenceCache.Unlock()
+	silenceCache.silences = nil
+	silenceCache.loaded = false
+}
+
+// loadSilences 从数据库加载并编译未过期的静默; 匹配器无效的静默记录日志后跳过
+func loadSilences(now time.Time) ([]compiledSilence, error) {
+	var rows []observe.AlertSilence
+	err := global.GVA_DB.Where("is_deleted = 0 AND ends_at > ?", now).Order("silence_id").Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	silences := make([]compiledSilence, 0, len(rows))
+	for _, row := range rows {
+		matchers, err := compileMatchers(row.Matchers)
+		if err != nil {
+			global.GVA_LOG.Warn("静默匹配器无效, 已跳过", zap.Int("silenceId", row.SilenceId), zap.Error(err))
+			continue
+		}
+		silences = append(silences, compiledSilence{silence: row, matchers: matchers})
+	}
+	return silences, nil
+}
+
+// newAlertSilence 校验请求并构建静默; 与 Alertmanager 一样, 至少一个匹配器不能匹配空值,
+// 否则如 foo!=bar 的静默会匹配几乎所有告警
+func newAlertSilence(req observe.AlertSilenceRequest, now time.Time) (silence observe.AlertSilence, err error) {
+	if len(req.Matchers) == 0 {
+		return silence, errors.New("至少需要一个匹配器")
+	}
+	matchers, err := compileMatchers(req.Matchers)
+	if err != nil {
+		return silence, err
+	}
+	// 所有标签都为空的告警仍被匹配, 说明每个匹配器都匹配空值
+	if matchAll(matchers, observe.AlertLabels{}) {
+		return silence, errors.New("匹配器全部匹配空值, 至少需要一个不匹配空值的匹配器")
+	}
+
+	startsAt := now
+	if req.StartsAt != "" {
+		startsAt, err = time.Parse(time.RFC3339, req.StartsAt)
+		if err != nil {
+			return silence, err
+		}
+	}
+	endsAt, err := time.Parse(time.RFC3339, req.EndsAt)
+	if err != nil {
+		return silence, err
+	}
+	if !endsAt.After(startsAt) {
+		return silence, errors.New("结束时间必须晚于开始时间")
+	}
+
+	return observe.AlertSilence{
+		Matchers:  req.Matchers,
+		StartsAt:  common.JSONTime{Time: startsAt},
+		EndsAt:    common.JSONTime{Time: endsAt},
+		CreatedBy: req.CreatedBy,
+		Comment:   req.Comment,
+	}, nil
+}
+
+// silenceStatus 按当前时间计算静默状态
+func silenceStatus(silence observe.AlertSilence, now time.Time) string {
+	switch {
+	case now.Before(silence.StartsAt.Time):
+		return observe.SilencePending
+	case now.Before(silence.EndsAt.Time):
+		return observe.SilenceActive
+	default:
+		return observe.SilenceExpired
+	}
+}
@@ -0,0 +1,41 @@
+package observe
+
+import (
+	"testing"
+	"time"
+
+	"main.go/model/observe"
+)
+
+func TestNewAlertSilenceMatchers(t *testing.T) {
+	now := time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		name     string
+		matchers observe.LabelMatchers
+		wantErr  bool
+	}{
+		{"没有匹配器", nil, true},
+		{"相等", observe.LabelMatchers{{Label: "alert_cluster", Op: observe.MatchEqual, Value: "cluster-a"}}, false},
+		{"单个不等", observe.LabelMatchers{{Label: "alert_cluster", Op: observe.MatchNotEqual, Value: "cluster-a"}}, true},
+		{"单个正则不匹配", observe.LabelMatchers{{Label: "severity", Op: observe.MatchNotRegexp, Value: "Critical|High"}}, true},
+		{"正则可匹配空值", observe.LabelMatchers{{Label: "severity", Op: observe.MatchRegexp, Value: ".*"}}, true},
+		{"相等空值", observe.LabelMatchers{{Label: "node_name", Op: observe.MatchEqual, Value: ""}}, true},
+		{"组合中有不匹配空值的", observe.LabelMatchers{
+			{Label: "alert_cluster", Op: observe.MatchNotEqual, Value: "cluster-a"},
+			{Label: "severity", Op: observe.MatchRegexp, Value: "Critical|High"},
+		}, false},
+		{"未知标签", observe.LabelMatchers{{Label: "team", Op: observe.MatchEqual, Value: "paas"}}, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := newAlertSilence(observe.AlertSilenceRequest{
+				Matchers:  tc.matchers,
+				EndsAt:    now.Add(time.Hour).Format(time.RFC3339),
+				CreatedBy: "ops",
+				Comment:   "变更窗口",
+			}, now)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("err = %v, 期望出错 %v", err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package observe
+
+import (
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"main.go/global"
+	"main.go/model/observe"
+)
+
+// AlertRequestsFromWebhook 把 Alertmanager webhook 分组告警转换为 AlertRequest
+// 告警自身的 status 优先, 缺失时使用分组的 status; commonLabels/commonAnnotations 作为默认值
+func AlertRequestsFromWebhook(msg observe.AlertmanagerWebhook, now time.Time) []observe.AlertRequest {
+	if msg.TruncatedAlerts > 0 {
+		global.GVA_LOG.Warn("Alertmanager 告警被截断, 请调大 max_alerts",
+			zap.String("groupKey", msg.GroupKey),
+			zap.Int("truncatedAlerts", msg.TruncatedAlerts),
+		)
+	}
+	reqs := make([]observe.AlertRequest, 0, len(msg.Alerts))
+	for _, a := range msg.Alerts {
+		if a.Status == "" {
+			a.Status = msg.Status
+		}
+		a.Labels = mergeLabels(msg.CommonLabels, a.Labels)
+		a.Annotations = mergeLabels(msg.CommonAnnotations, a.Annotations)
+		reqs = append(reqs, alertRequestFrom(a, now))
+	}
+	return reqs
+}
+
+// AlertRequestsFromPostable 把 /api/v2/alerts 的 postableAlert 列表转换为 AlertRequest
+// postableAlert 没有 status: endsAt 已过去为 resolved, 否则为 firing
+func AlertRequestsFromPostable(alerts []observe.AlertmanagerAlert, now time.Time) []observe.AlertRequest {
+	reqs := make([]observe.AlertRequest, 0, len(alerts))
+	for _, a := range alerts {
+		a.Status = "firing"
+		if endsAt, err := time.Parse(time.RFC3339, a.EndsAt); err == nil && !endsAt.IsZero() && !endsAt.After(now) {
+			a.Status = "resolved"
+		}
+		reqs = append(reqs, alertRequestFrom(a, now))
+	}
+	return reqs
+}
+
+// alertRequestFrom 转换单条告警, 标签与注解按 json tag 映射到 AlertLabels/AlertAnnotations, 其余字段忽略
+// startsAt 缺失时使用接收时间(与 Alertmanager 一致)
+func alertRequestFrom(a observe.AlertmanagerAlert, now time.Time) observe.AlertRequest {
+	req := observe.AlertRequest{
+		Status:   a.Status,
+		StartsAt: a.StartsAt,
+		EndsAt:   a.EndsAt,
+	}
+	if req.StartsAt == "" {
+		req.StartsAt = now.Format(time.RFC3339)
+	}
+	if b, err := json.Marshal(a.Labels); err == nil {
+		_ = json.Unmarshal(b, &req.Labels)
+	}
+	if b, err := json.Marshal(a.Annotations); err == nil {
+		_ = json.Unmarshal(b, &req.Annotations)
+	}
+	if !hasAlertDescLabels(req.Labels) {
+		req.Fingerprint = labelSetFingerprint(a.Labels)
+	}
+	return req
+}
+
+// hasAlertDescLabels 判断标签是否包含平台告警描述所用的字段
+// 通用 Prometheus 告警没有这些字段, 按告警描述生成的指纹会全部相同
+func hasAlertDescLabels(labels observe.AlertLabels) bool {
+	return labels.AlertInvolvedObjectName != "" || labels.DisplayName != "" ||
+		labels.AlertIndicatorAlias != "" || labels.AlertIndicator != ""
+}
+
+// labelSetFingerprint 基于完整标签集生成指纹(与 Alertmanager 一致, 标签集相同即为同一告警)
+func labelSetFingerprint(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString(k)
+		b.WriteByte(0xff)
+		b.WriteString(labels[k])
+		b.WriteByte(0xff)
+	}
+	return fmt.Sprintf("%x", md5.Sum([]byte(b.String())))
+}
+
+// mergeLabels 返回 base 与 override 合并后的新 map, override 优先
+func mergeLabels(base, override map[string]string) map[string]string {
+	merged := make(map[string]string, len(base)+len(override))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range override {
+		merged[k] = v
+	}
+	return merged
+}
@@ -3,4 +3,8 @@ package observe
 type ObserveServiceGroup struct {
 	ObserveAlertService
 	AlertDedupService
+	AdmissionAuditService
+	AlertRouteService
+	NotifyOutboxService
+	AlertSilenceService
 }
@@ -0,0 +1,87 @@
+package observe
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"main.go/model/observe"
+)
+
+// alertLabelNames AlertLabels 的 json 字段名到字段下标, 匹配器只能引用这些标签
+var alertLabelNames = func() map[string]int {
+	names := map[string]int{}
+	t := reflect.TypeOf(observe.AlertLabels{})
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name != "" && name != "-" {
+			names[name] = i
+		}
+	}
+	return names
+}()
+
+// labelValue 按 json 字段名取告警标签的值
+func labelValue(labels observe.AlertLabels, name string) string {
+	i, ok := alertLabelNames[name]
+	if !ok {
+		return ""
+	}
+	return reflect.ValueOf(labels).Field(i).String()
+}
+
+// labelMatcher 校验并编译后的匹配器
+type labelMatcher struct {
+	observe.LabelMatcher
+	re *regexp.Regexp
+}
+
+func (m labelMatcher) matches(labels observe.AlertLabels) bool {
+	v := labelValue(labels, m.Label)
+	switch m.Op {
+	case observe.MatchEqual:
+		return v == m.Value
+	case observe.MatchNotEqual:
+		return v != m.Value
+	case observe.MatchRegexp:
+		return m.re.MatchString(v)
+	case observe.MatchNotRegexp:
+		return !m.re.MatchString(v)
+	}
+	return false
+}
+
+// compileMatchers 校验标签名与运算符并编译正则, 正则与 Alertmanager 一样需完整匹配
+func compileMatchers(matchers observe.LabelMatchers) ([]labelMatcher, error) {
+	compiled := make([]labelMatcher, 0, len(matchers))
+	for _, m := range matchers {
+		if _, ok := alertLabelNames[m.Label]; !ok {
+			return nil, fmt.Errorf("未知的标签: %s", m.Label)
+		}
+		cm := labelMatcher{LabelMatcher: m}
+		switch m.Op {
+		case observe.MatchEqual, observe.MatchNotEqual:
+		case observe.MatchRegexp, observe.MatchNotRegexp:
+			re, err := regexp.Compile("^(?:" + m.Value + ")$")
+			if err != nil {
+				return nil, fmt.Errorf("标签 %s 的正则无效: %w", m.Label, err)
+			}
+			cm.re = re
+		default:
+			return nil, fmt.Errorf("标签 %s 的运算符无效: %s", m.Label, m.Op)
+		}
+		compiled = append(compiled, cm)
+	}
+	return compiled, nil
+}
+
+// matchAll 全部匹配器匹配时返回 true, 没有匹配器时匹配所有告警
+func matchAll(matchers []labelMatcher, labels observe.AlertLabels) bool {
+	for _, m := range matchers {
+		if !m.matches(labels) {
+			return false
+		}
+	}
+	return true
+}
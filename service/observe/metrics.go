@@ -0,0 +1,56 @@
+package observe
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	// alertsIngested 接收到的告警数(按状态)
+	alertsIngested = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "finops_alert_ingested_total",
+		Help: "Alerts received by CreateAlert, by status.",
+	}, []string{"status"})
+
+	// alertsDeduplicated 命中已有指纹、合并到已有记录的告警数
+	alertsDeduplicated = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "finops_alert_deduplicated_total",
+		Help: "Alerts merged into an existing record with the same fingerprint.",
+	})
+
+	// alertsNotified 通知发送成功数
+	alertsNotified = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "finops_alert_notified_total",
+		Help: "Alert notifications delivered successfully.",
+	})
+
+	// alertsRateLimited 因每日通知上限被跳过的通知数
+	alertsRateLimited = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "finops_alert_rate_limited_total",
+		Help: "Alert notifications skipped because the daily notify limit was reached.",
+	})
+
+	// alertsSilenced 被静默抑制的通知数
+	alertsSilenced = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "finops_alert_silenced_total",
+		Help: "Alert notifications suppressed by a silence.",
+	})
+
+	// alertsMQFailed MQ 发送失败数
+	alertsMQFailed = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "finops_alert_mq_failed_total",
+		Help: "Alert notifications that failed to be sent to the MQ relay.",
+	})
+
+	// notificationsDead 达到最大尝试次数进入 dead 状态的通知数
+	notificationsDead = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "finops_alert_notifications_dead_total",
+		Help: "Alert notifications that exhausted their attempts in the outbox, by channel.",
+	}, []string{"channel"})
+
+	// alertNotifications 各通知渠道的发送尝试数(按结果)
+	alertNotifications = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "finops_alert_notifications_total",
+		Help: "Alert notification attempts per channel, by result.",
+	}, []string{"channel", "result"})
+)
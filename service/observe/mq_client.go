@@ -2,9 +2,11 @@ package observe
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"go.uber.org/zap"
@@ -31,12 +33,21 @@ func initMQClient() {
 
 type MQClientService struct{}
 
-// SendAlertNotification 发送告警通知到MQ
-func (s *MQClientService) SendAlertNotification(alert observe.PrometheusAlert) error {
+// Name 实现 Notifier
+func (s *MQClientService) Name() string {
+	return ChannelMQ
+}
+
+// Notify 实现 Notifier, receivers 为空时发给 mq.receiver
+func (s *MQClientService) Notify(ctx context.Context, alert observe.PrometheusAlert, receivers []string) error {
 	// 确保 HTTP 客户端已初始化
 	initMQClient()
 
-	mqMsg := s.buildMQMessage(alert)
+	receiver := global.GVA_CONFIG.MQ.Receiver
+	if len(receivers) > 0 {
+		receiver = strings.Join(receivers, ",")
+	}
+	mqMsg := s.buildMQMessage(alert, receiver)
 
 	jsonData, err := json.Marshal(mqMsg)
 	if err != nil {
@@ -45,7 +56,12 @@ func (s *MQClientService) SendAlertNotification(alert observe.PrometheusAlert) e
 
 	global.GVA_LOG.Info("MQ消息序列化完成", zap.String("jsonData", string(jsonData)))
 
-	resp, err := mqHTTPClient.Post(global.GVA_CONFIG.MQ.Url, "application/json", bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, global.GVA_CONFIG.MQ.Url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("构建MQ请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := mqHTTPClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("发送MQ请求失败: %w", err)
 	}
@@ -57,49 +73,36 @@ func (s *MQClientService) SendAlertNotification(alert observe.PrometheusAlert) e
 	return nil
 }
 
-// buildMQMessage 构建MQ消息体
-func (s *MQClientService) buildMQMessage(alert observe.PrometheusAlert) observe.MQMessageRequest {
-	statusZh := MapStatus(alert.Status)
-	levelZh := s.mapSeverity(alert.Labels.Severity)
-
-	// 使用共享函数构建各字段
-	emailSubject := BuildEmailSubject(alert.Status, alert.Labels)
-	alertObject := BuildAlertObject(alert.Labels)
-	alertDesc := BuildAlertDesc(alert.Labels)
+// SendAlertNotification 发送告警通知到MQ(接收人为 mq.receiver)
+func (s *MQClientService) SendAlertNotification(alert observe.PrometheusAlert) error {
+	return s.Notify(context.Background(), alert, nil)
+}
 
+// buildMQMessage 构建MQ消息体
+func (s *MQClientService) buildMQMessage(alert observe.PrometheusAlert, receiver string) observe.MQMessageRequest {
 	return observe.MQMessageRequest{
 		Topic: global.GVA_CONFIG.MQ.Topic,
 		Tag:   global.GVA_CONFIG.MQ.Tag,
 		Data: observe.MQAlertData{
-			Title:    emailSubject,
-			Receiver: global.GVA_CONFIG.MQ.Receiver,
-			AlertDetail: observe.MQAlertDetail{
-				Status:       statusZh,
-				Severity:     levelZh,
-				Cluster:      alert.Labels.AlertCluster,
-				Object:       alertObject,
-				Indicator:    alert.Labels.AlertResource,
-				Summary:      alertDesc,
-				TriggerValue: alert.Annotations.AlertCurrentValue,
-				AlertTime:    alert.StartsAt.Format("2006-01-02 15:04:05"),
-				Remark:       fmt.Sprintf("%d", time.Now().Unix()),
-			},
+			Title:       BuildEmailSubject(alert.Status, alert.Labels),
+			Receiver:    receiver,
+			AlertDetail: buildAlertDetail(alert),
 		},
 	}
 }
 
-// mapSeverity 等级映射
-func (s *MQClientService) mapSeverity(severity string) string {
-	switch severity {
-	case "Critical":
-		return "紧急"
-	case "High":
-		return "严重"
-	case "Warning":
-		return "警告"
-	case "Low":
-		return "轻微"
-	default:
-		return "一般"
+// buildAlertDetail 构建告警详情, MQ 消息与其他通知渠道共用
+func buildAlertDetail(alert observe.PrometheusAlert) observe.MQAlertDetail {
+	// 使用共享函数构建各字段
+	return observe.MQAlertDetail{
+		Status:       MapStatus(alert.Status),
+		Severity:     MapSeverity(alert.Labels.Severity),
+		Cluster:      alert.Labels.AlertCluster,
+		Object:       BuildAlertObject(alert.Labels),
+		Indicator:    alert.Labels.AlertResource,
+		Summary:      BuildAlertDesc(alert.Labels),
+		TriggerValue: alert.Annotations.AlertCurrentValue,
+		AlertTime:    alert.StartsAt.Format("2006-01-02 15:04:05"),
+		Remark:       fmt.Sprintf("%d", time.Now().Unix()),
 	}
 }
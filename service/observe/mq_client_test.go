@@ -0,0 +1,53 @@
+package observe
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"main.go/config"
+	"main.go/model/observe"
+)
+
+func TestMQClientReceivers(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		receivers []string
+		want      string
+	}{
+		{"路由接收人", []string{"zhangsan", "lisi"}, "zhangsan,lisi"},
+		{"回退到 mq.receiver", nil, "paas-ops"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			srv, requests := captureServer(t, http.StatusOK, "")
+			withServerConfig(t, func(cfg *config.Server) {
+				cfg.MQ = config.MQ{Url: srv.URL, Topic: "alert", Tag: "finops", Receiver: "paas-ops"}
+			})
+
+			if err := (&MQClientService{}).Notify(context.Background(), testAlert(), tc.receivers); err != nil {
+				t.Fatal(err)
+			}
+			var msg observe.MQMessageRequest
+			decodeBody(t, requests()[0], &msg)
+			if msg.Data.Receiver != tc.want {
+				t.Errorf("receiver = %q, 期望 %q", msg.Data.Receiver, tc.want)
+			}
+			if msg.Topic != "alert" || msg.Tag != "finops" {
+				t.Errorf("topic/tag = %s/%s, 期望 alert/finops", msg.Topic, msg.Tag)
+			}
+			if msg.Data.AlertDetail.Severity != "紧急" || msg.Data.AlertDetail.AlertTime != "2026-03-01 08:30:00" {
+				t.Errorf("detail = %+v", msg.Data.AlertDetail)
+			}
+		})
+	}
+}
+
+func TestMQClientErrorStatus(t *testing.T) {
+	srv, _ := captureServer(t, http.StatusAccepted, "")
+	withServerConfig(t, func(cfg *config.Server) {
+		cfg.MQ = config.MQ{Url: srv.URL}
+	})
+	if err := (&MQClientService{}).Notify(context.Background(), testAlert(), nil); err == nil {
+		t.Fatal("MQ 返回非 200 时应返回错误")
+	}
+}
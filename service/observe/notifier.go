@@ -0,0 +1,207 @@
+package observe
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"main.go/global"
+	"main.go/model/observe"
+)
+
+// 内置通知渠道名, 与 notify.channels 配置一致
+const (
+	ChannelMQ       = "mq"
+	ChannelWebhook  = "webhook"
+	ChannelSmtp     = "smtp"
+	ChannelWecom    = "wecom"
+	ChannelDingtalk = "dingtalk"
+	ChannelFeishu   = "feishu"
+)
+
+// defaultNotifyTimeout 渠道未配置 timeout 时的发送超时
+const defaultNotifyTimeout = 10 * time.Second
+
+// Notifier 告警通知渠道
+type Notifier interface {
+	// Name 渠道名, 用于 notify.channels 配置和发送结果
+	Name() string
+	// Notify 发送一条告警通知, receivers 为空时发给渠道配置的默认接收人
+	Notify(ctx context.Context, alert observe.PrometheusAlert, receivers []string) error
+}
+
+// notifierRegistry 已注册的通知渠道, 渠道在发送时读取全局配置, 因此配置热更新后无需重新注册
+var notifierRegistry = struct {
+	sync.RWMutex
+	notifiers map[string]Notifier
+}{notifiers: map[string]Notifier{}}
+
+func init() {
+	RegisterNotifier(&MQClientService{})
+	RegisterNotifier(&WebhookNotifier{})
+	RegisterNotifier(&SmtpNotifier{})
+	RegisterNotifier(&WecomNotifier{})
+	RegisterNotifier(&DingtalkNotifier{})
+	RegisterNotifier(&FeishuNotifier{})
+}
+
+// RegisterNotifier 注册通知渠道, 同名渠道被替换
+func RegisterNotifier(n Notifier) {
+	notifierRegistry.Lock()
+	defer notifierRegistry.Unlock()
+	notifierRegistry.notifiers[n.Name()] = n
+}
+
+// notifierRegistered 渠道是否已注册
+func notifierRegistered(name string) bool {
+	notifierRegistry.RLock()
+	defer notifierRegistry.RUnlock()
+	_, ok := notifierRegistry.notifiers[name]
+	return ok
+}
+
+// lookupNotifiers 按渠道名查找已注册的渠道, channels 为空时使用 notify.channels, 未配置时仅 mq;
+// 未注册的渠道名记录告警日志后忽略
+func lookupNotifiers(channels []string) []Notifier {
+	if len(channels) == 0 {
+		channels = global.GVA_CONFIG.Notify.Channels
+	}
+	if len(channels) == 0 {
+		channels = []string{ChannelMQ}
+	}
+
+	notifierRegistry.RLock()
+	defer notifierRegistry.RUnlock()
+	notifiers := make([]Notifier, 0, len(channels))
+	seen := make(map[string]bool, len(channels))
+	for _, name := range channels {
+		name = strings.TrimSpace(name)
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		n, ok := notifierRegistry.notifiers[name]
+		if !ok {
+			global.GVA_LOG.Warn("未知的通知渠道, 已忽略", zap.String("channel", name))
+			continue
+		}
+		notifiers = append(notifiers, n)
+	}
+	return notifiers
+}
+
+// notifyTask 一次渠道发送: 告警命中的路由经由其中一个渠道发给该路由的接收人, 入箱后由发件箱发送
+type notifyTask struct {
+	notifier  Notifier
+	routeId   int
+	receivers []string
+}
+
+// notifyTasks 把命中的路由展开为渠道发送任务, 渠道与接收人都相同的任务只保留一个
+func notifyTasks(targets []observe.AlertRouteTarget) []notifyTask {
+	var tasks []notifyTask
+	seen := map[string]bool{}
+	for _, target := range targets {
+		for _, n := range lookupNotifiers(target.Channels) {
+			key := n.Name() + "\x00" + strings.Join(target.Receivers, ",")
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			tasks = append(tasks, notifyTask{notifier: n, routeId: target.RouteId, receivers: target.Receivers})
+		}
+	}
+	return tasks
+}
+
+// notifyTimeout 渠道配置的超时秒数, 未配置时为 defaultNotifyTimeout
+func notifyTimeout(seconds int) time.Duration {
+	if seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return defaultNotifyTimeout
+}
+
+// notifyContent 各渠道共用的告警内容, 字段与 MQ 消息的告警详情一致
+type notifyContent struct {
+	Title  string
+	Fields [][2]string // 按展示顺序的 名称-值
+}
+
+func buildNotifyContent(alert observe.PrometheusAlert) notifyContent {
+	detail := buildAlertDetail(alert)
+	return notifyContent{
+		Title: BuildEmailSubject(alert.Status, alert.Labels),
+		Fields: [][2]string{
+			{"告警状态", detail.Status},
+			{"告警等级", detail.Severity},
+			{"告警集群", detail.Cluster},
+			{"告警对象", detail.Object},
+			{"策略名称", detail.Indicator},
+			{"告警描述", detail.Summary},
+			{"触发数值", detail.TriggerValue},
+			{"告警时间", detail.AlertTime},
+		},
+	}
+}
+
+// text 纯文本格式, 用于邮件和飞书
+func (c notifyContent) text() string {
+	var b strings.Builder
+	b.WriteString(c.Title)
+	for _, f := range c.Fields {
+		fmt.Fprintf(&b, "\n%s: %s", f[0], f[1])
+	}
+	return b.String()
+}
+
+// markdown markdown 格式, 用于企业微信和钉钉
+func (c notifyContent) markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "### %s", c.Title)
+	for _, f := range c.Fields {
+		fmt.Fprintf(&b, "\n> **%s**: %s", f[0], f[1])
+	}
+	return b.String()
+}
+
+// notifyHTTPClient HTTP 类通知渠道共用的客户端, 超时由各渠道的 context 控制
+var notifyHTTPClient = &http.Client{
+	Transport: &http.Transport{
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 10,
+		IdleConnTimeout:     90 * time.Second,
+	},
+}
+
+// postJSON 以 JSON POST 消息, 返回 2xx 响应的响应体, 非 2xx 时返回错误
+func postJSON(ctx context.Context, url string, body []byte, headers map[string]string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("构建请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := notifyHTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("发送请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("返回非2xx状态码: %d, %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	return respBody, nil
+}
@@ -0,0 +1,117 @@
+package observe
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"main.go/config"
+	"main.go/global"
+	"main.go/model/observe"
+)
+
+func TestMain(m *testing.M) {
+	global.GVA_LOG = zap.NewNop()
+	os.Exit(m.Run())
+}
+
+// withServerConfig 在测试期间修改全局配置, 测试结束后恢复
+func withServerConfig(t *testing.T, change func(*config.Server)) {
+	t.Helper()
+	saved := global.GVA_CONFIG
+	t.Cleanup(func() { global.GVA_CONFIG = saved })
+	change(&global.GVA_CONFIG)
+}
+
+// testAlert 一条 Pod CPU 使用率告警
+func testAlert() observe.PrometheusAlert {
+	startsAt := time.Date(2026, 3, 1, 8, 30, 0, 0, time.Local)
+	return observe.PrometheusAlert{
+		Status:   "firing",
+		StartsAt: &observe.NullTime{Time: &startsAt},
+		Labels: observe.AlertLabels{
+			AlertCluster:             "cluster-a",
+			AlertIndicator:           "CPU使用率",
+			AlertIndicatorComparison: ">",
+			AlertIndicatorThreshold:  "80%",
+			AlertInvolvedObjectKind:  "Pod",
+			AlertInvolvedObjectName:  "web-0",
+			Severity:                 "Critical",
+		},
+		Annotations: observe.AlertAnnotations{AlertCurrentValue: "93%"},
+		Fingerprint: "f1",
+	}
+}
+
+// capturedRequest 测试服务端收到的请求
+type capturedRequest struct {
+	Header http.Header
+	Query  map[string]string
+	Body   []byte
+}
+
+// captureServer 记录收到的请求并以 response 应答
+func captureServer(t *testing.T, status int, response string) (*httptest.Server, func() []capturedRequest) {
+	t.Helper()
+	var mu sync.Mutex
+	var requests []capturedRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		query := map[string]string{}
+		for k := range r.URL.Query() {
+			query[k] = r.URL.Query().Get(k)
+		}
+		mu.Lock()
+		requests = append(requests, capturedRequest{Header: r.Header.Clone(), Query: query, Body: body})
+		mu.Unlock()
+		w.WriteHeader(status)
+		_, _ = io.WriteString(w, response)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []capturedRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]capturedRequest(nil), requests...)
+	}
+}
+
+// decodeBody 把请求体解析到 v
+func decodeBody(t *testing.T, req capturedRequest, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(req.Body, v); err != nil {
+		t.Fatalf("请求体不是合法 JSON: %v, %s", err, req.Body)
+	}
+}
+
+func TestLookupNotifiers(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		configured []string
+		channels   []string
+		want       []string
+	}{
+		{"未配置时仅 mq", nil, nil, []string{ChannelMQ}},
+		{"使用 notify.channels", []string{ChannelWebhook, ChannelSmtp}, nil, []string{ChannelWebhook, ChannelSmtp}},
+		{"路由渠道优先", []string{ChannelWebhook}, []string{ChannelFeishu}, []string{ChannelFeishu}},
+		{"忽略未知与重复渠道", nil, []string{ChannelWecom, "sms", " wecom"}, []string{ChannelWecom}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			withServerConfig(t, func(cfg *config.Server) { cfg.Notify.Channels = tc.configured })
+			got := lookupNotifiers(tc.channels)
+			if len(got) != len(tc.want) {
+				t.Fatalf("渠道数 = %d, 期望 %v", len(got), tc.want)
+			}
+			for i, n := range got {
+				if n.Name() != tc.want[i] {
+					t.Errorf("第 %d 个渠道 = %s, 期望 %s", i, n.Name(), tc.want[i])
+				}
+			}
+		})
+	}
+}
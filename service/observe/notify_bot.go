@@ -0,0 +1,179 @@
+package observe
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"main.go/config"
+	"main.go/global"
+	"main.go/model/observe"
+)
+
+// WecomNotifier 企业微信群机器人渠道, receivers 为要 @ 的成员 userid
+type WecomNotifier struct{}
+
+// Name 实现 Notifier
+func (n *WecomNotifier) Name() string {
+	return ChannelWecom
+}
+
+// Notify 实现 Notifier
+func (n *WecomNotifier) Notify(ctx context.Context, alert observe.PrometheusAlert, receivers []string) error {
+	cfg := global.GVA_CONFIG.Notify.Wecom
+	return sendBotMessage(ctx, ChannelWecom, cfg, cfg.Url, n.buildMessage(alert, botMentions(cfg, receivers)))
+}
+
+// buildMessage 构建企业微信 markdown 消息, markdown 消息通过 <@userid> 提醒成员
+func (n *WecomNotifier) buildMessage(alert observe.PrometheusAlert, mentions []string) observe.WecomMessage {
+	content := buildNotifyContent(alert).markdown()
+	if len(mentions) > 0 {
+		tags := make([]string, 0, len(mentions))
+		for _, m := range mentions {
+			tags = append(tags, "<@"+m+">")
+		}
+		content += "\n" + strings.Join(tags, " ")
+	}
+	return observe.WecomMessage{
+		MsgType:  "markdown",
+		Markdown: observe.WecomMarkdown{Content: content},
+	}
+}
+
+// DingtalkNotifier 钉钉群机器人渠道, receivers 为要 @ 的成员手机号
+type DingtalkNotifier struct{}
+
+// Name 实现 Notifier
+func (n *DingtalkNotifier) Name() string {
+	return ChannelDingtalk
+}
+
+// Notify 实现 Notifier, 配置 secret 时按钉钉加签规则在地址上附加 timestamp 与 sign
+func (n *DingtalkNotifier) Notify(ctx context.Context, alert observe.PrometheusAlert, receivers []string) error {
+	cfg := global.GVA_CONFIG.Notify.Dingtalk
+	target := cfg.Url
+	if cfg.Secret != "" && target != "" {
+		u, err := url.Parse(target)
+		if err != nil {
+			return fmt.Errorf("解析 notify.dingtalk.url 失败: %w", err)
+		}
+		timestamp := strconv.FormatInt(time.Now().UnixMilli(), 10)
+		query := u.Query()
+		query.Set("timestamp", timestamp)
+		query.Set("sign", signDingtalk(cfg.Secret, timestamp))
+		u.RawQuery = query.Encode()
+		target = u.String()
+	}
+	return sendBotMessage(ctx, ChannelDingtalk, cfg, target, n.buildMessage(alert, botMentions(cfg, receivers)))
+}
+
+// buildMessage 构建钉钉 markdown 消息, 被 @ 的手机号需同时出现在正文中
+func (n *DingtalkNotifier) buildMessage(alert observe.PrometheusAlert, mentions []string) observe.DingtalkMessage {
+	content := buildNotifyContent(alert)
+	text := content.markdown()
+	if len(mentions) > 0 {
+		tags := make([]string, 0, len(mentions))
+		for _, m := range mentions {
+			tags = append(tags, "@"+m)
+		}
+		text += "\n\n" + strings.Join(tags, " ")
+	}
+	return observe.DingtalkMessage{
+		MsgType:  "markdown",
+		Markdown: observe.DingtalkMarkdown{Title: content.Title, Text: text},
+		At:       observe.DingtalkAt{AtMobiles: mentions},
+	}
+}
+
+// signDingtalk 钉钉加签: base64(HMAC-SHA256(secret, timestamp + "\n" + secret))
+func signDingtalk(secret, timestamp string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "\n" + secret))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// FeishuNotifier 飞书群机器人渠道, receivers 为要 @ 的成员 open_id
+type FeishuNotifier struct{}
+
+// Name 实现 Notifier
+func (n *FeishuNotifier) Name() string {
+	return ChannelFeishu
+}
+
+// Notify 实现 Notifier
+func (n *FeishuNotifier) Notify(ctx context.Context, alert observe.PrometheusAlert, receivers []string) error {
+	cfg := global.GVA_CONFIG.Notify.Feishu
+	return sendBotMessage(ctx, ChannelFeishu, cfg, cfg.Url, n.buildMessage(alert, botMentions(cfg, receivers), cfg.Secret, time.Now()))
+}
+
+// buildMessage 构建飞书文本消息, 配置 secret 时在消息体中携带 timestamp 与 sign
+func (n *FeishuNotifier) buildMessage(alert observe.PrometheusAlert, mentions []string, secret string, now time.Time) observe.FeishuMessage {
+	text := buildNotifyContent(alert).text()
+	if len(mentions) > 0 {
+		tags := make([]string, 0, len(mentions))
+		for _, m := range mentions {
+			tags = append(tags, `<at user_id="`+m+`"></at>`)
+		}
+		text += "\n" + strings.Join(tags, " ")
+	}
+	msg := observe.FeishuMessage{
+		MsgType: "text",
+		Content: observe.FeishuContent{Text: text},
+	}
+	if secret != "" {
+		msg.Timestamp = strconv.FormatInt(now.Unix(), 10)
+		msg.Sign = signFeishu(secret, msg.Timestamp)
+	}
+	return msg
+}
+
+// signFeishu 飞书加签: base64(HMAC-SHA256(key = timestamp + "\n" + secret, 空消息))
+func signFeishu(secret, timestamp string) string {
+	mac := hmac.New(sha256.New, []byte(timestamp+"\n"+secret))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// botMentions receivers 为空时使用渠道配置的默认 @ 成员
+func botMentions(cfg config.NotifyBot, receivers []string) []string {
+	if len(receivers) > 0 {
+		return receivers
+	}
+	return cfg.Mentions
+}
+
+// sendBotMessage 发送 IM 机器人消息; 机器人在 HTTP 200 的响应体中返回错误码, 非 0 时视为失败
+func sendBotMessage(ctx context.Context, channel string, cfg config.NotifyBot, target string, msg interface{}) error {
+	if target == "" {
+		return fmt.Errorf("未配置 notify.%s.url", channel)
+	}
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("序列化%s消息失败: %w", channel, err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, notifyTimeout(cfg.Timeout))
+	defer cancel()
+	respBody, err := postJSON(ctx, target, body, nil)
+	if err != nil {
+		return err
+	}
+
+	var resp observe.BotResponse
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		return fmt.Errorf("解析%s响应失败: %w", channel, err)
+	}
+	if resp.ErrCode != 0 {
+		return fmt.Errorf("%s返回错误: %d %s", channel, resp.ErrCode, resp.ErrMsg)
+	}
+	if resp.Code != 0 {
+		return fmt.Errorf("%s返回错误: %d %s", channel, resp.Code, resp.Msg)
+	}
+	return nil
+}
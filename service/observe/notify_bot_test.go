@@ -0,0 +1,149 @@
+package observe
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"main.go/config"
+	"main.go/model/observe"
+)
+
+func TestWecomNotifier(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		receivers []string
+		want      string
+	}{
+		{"路由接收人", []string{"zhangsan"}, "<@zhangsan>"},
+		{"默认 @ 成员", nil, "<@oncall>"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			srv, requests := captureServer(t, http.StatusOK, `{"errcode":0,"errmsg":"ok"}`)
+			withServerConfig(t, func(cfg *config.Server) {
+				cfg.Notify.Wecom = config.NotifyBot{Url: srv.URL, Mentions: []string{"oncall"}}
+			})
+
+			if err := (&WecomNotifier{}).Notify(context.Background(), testAlert(), tc.receivers); err != nil {
+				t.Fatal(err)
+			}
+			var msg observe.WecomMessage
+			decodeBody(t, requests()[0], &msg)
+			if msg.MsgType != "markdown" {
+				t.Errorf("msgtype = %q, 期望 markdown", msg.MsgType)
+			}
+			if !strings.HasPrefix(msg.Markdown.Content, "### "+BuildEmailSubject("firing", testAlert().Labels)) {
+				t.Errorf("content 缺少标题: %q", msg.Markdown.Content)
+			}
+			if !strings.HasSuffix(msg.Markdown.Content, "\n"+tc.want) {
+				t.Errorf("content 未 @ %s: %q", tc.want, msg.Markdown.Content)
+			}
+		})
+	}
+}
+
+func TestDingtalkNotifierSignature(t *testing.T) {
+	srv, requests := captureServer(t, http.StatusOK, `{"errcode":0,"errmsg":"ok"}`)
+	withServerConfig(t, func(cfg *config.Server) {
+		cfg.Notify.Dingtalk = config.NotifyBot{Url: srv.URL + "/robot/send?access_token=abc", Secret: "SECxyz"}
+	})
+
+	before := time.Now().UnixMilli()
+	if err := (&DingtalkNotifier{}).Notify(context.Background(), testAlert(), []string{"13800000000"}); err != nil {
+		t.Fatal(err)
+	}
+	req := requests()[0]
+
+	if req.Query["access_token"] != "abc" {
+		t.Errorf("access_token = %q, 原有参数应保留", req.Query["access_token"])
+	}
+	timestamp, err := strconv.ParseInt(req.Query["timestamp"], 10, 64)
+	if err != nil || timestamp < before {
+		t.Fatalf("timestamp = %q, 期望发送时的毫秒时间戳", req.Query["timestamp"])
+	}
+	// 钉钉加签: base64(HMAC-SHA256(secret, timestamp + "\n" + secret))
+	mac := hmac.New(sha256.New, []byte("SECxyz"))
+	mac.Write([]byte(req.Query["timestamp"] + "\nSECxyz"))
+	if want := base64.StdEncoding.EncodeToString(mac.Sum(nil)); req.Query["sign"] != want {
+		t.Errorf("sign = %q, 期望 %q", req.Query["sign"], want)
+	}
+
+	var msg observe.DingtalkMessage
+	decodeBody(t, req, &msg)
+	if msg.MsgType != "markdown" || msg.Markdown.Title != BuildEmailSubject("firing", testAlert().Labels) {
+		t.Errorf("消息头不符: %+v", msg)
+	}
+	if !strings.HasSuffix(msg.Markdown.Text, "\n\n@13800000000") {
+		t.Errorf("正文未包含被 @ 的手机号: %q", msg.Markdown.Text)
+	}
+	if strings.Join(msg.At.AtMobiles, ",") != "13800000000" || msg.At.IsAtAll {
+		t.Errorf("at = %+v, 期望仅 @ 13800000000", msg.At)
+	}
+}
+
+func TestFeishuNotifierSignature(t *testing.T) {
+	srv, requests := captureServer(t, http.StatusOK, `{"code":0,"msg":"success"}`)
+	withServerConfig(t, func(cfg *config.Server) {
+		cfg.Notify.Feishu = config.NotifyBot{Url: srv.URL, Secret: "fs-secret"}
+	})
+
+	if err := (&FeishuNotifier{}).Notify(context.Background(), testAlert(), []string{"ou_123"}); err != nil {
+		t.Fatal(err)
+	}
+	var msg observe.FeishuMessage
+	decodeBody(t, requests()[0], &msg)
+
+	if _, err := strconv.ParseInt(msg.Timestamp, 10, 64); err != nil {
+		t.Fatalf("timestamp = %q, 期望秒级时间戳", msg.Timestamp)
+	}
+	// 飞书加签: base64(HMAC-SHA256(key = timestamp + "\n" + secret, 空消息))
+	mac := hmac.New(sha256.New, []byte(msg.Timestamp+"\nfs-secret"))
+	if want := base64.StdEncoding.EncodeToString(mac.Sum(nil)); msg.Sign != want {
+		t.Errorf("sign = %q, 期望 %q", msg.Sign, want)
+	}
+	if msg.MsgType != "text" {
+		t.Errorf("msg_type = %q, 期望 text", msg.MsgType)
+	}
+	if !strings.HasPrefix(msg.Content.Text, BuildEmailSubject("firing", testAlert().Labels)) ||
+		!strings.HasSuffix(msg.Content.Text, `<at user_id="ou_123"></at>`) {
+		t.Errorf("text = %q", msg.Content.Text)
+	}
+}
+
+func TestFeishuNotifierUnsigned(t *testing.T) {
+	msg := (&FeishuNotifier{}).buildMessage(testAlert(), nil, "", time.Now())
+	if msg.Timestamp != "" || msg.Sign != "" {
+		t.Errorf("未配置 secret 时不应加签: %+v", msg)
+	}
+}
+
+func TestBotErrorResponse(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		notifier Notifier
+		response string
+		want     string
+	}{
+		{"企业微信 errcode", &WecomNotifier{}, `{"errcode":93000,"errmsg":"invalid webhook url"}`, "93000"},
+		{"钉钉 errcode", &DingtalkNotifier{}, `{"errcode":310000,"errmsg":"sign not match"}`, "310000"},
+		{"飞书 code", &FeishuNotifier{}, `{"code":19021,"msg":"sign match fail"}`, "19021"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			srv, _ := captureServer(t, http.StatusOK, tc.response)
+			withServerConfig(t, func(cfg *config.Server) {
+				bot := config.NotifyBot{Url: srv.URL, Secret: "s"}
+				cfg.Notify.Wecom, cfg.Notify.Dingtalk, cfg.Notify.Feishu = bot, bot, bot
+			})
+			err := tc.notifier.Notify(context.Background(), testAlert(), nil)
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("err = %v, 期望包含 %s", err, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,405 @@
+package observe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"main.go/config"
+	"main.go/global"
+	"main.go/model/common"
+	"main.go/model/observe"
+)
+
+type NotifyOutboxService struct {
+}
+
+const (
+	defaultOutboxWorkers      = 4
+	defaultOutboxBatchSize    = 100
+	defaultOutboxPollInterval = 5 * time.Second
+	defaultOutboxMaxAttempts  = 8
+	defaultOutboxRetryBackoff = 10 * time.Second
+	defaultOutboxMaxBackoff   = 10 * time.Minute
+	defaultOutboxLease        = 2 * time.Minute
+
+	// maxOutboxError last_error 列长度
+	maxOutboxError = 1024
+)
+
+// errAlertDeleted 告警已删除, 记录直接进入 dead 不再重试
+var errAlertDeleted = errors.New("告警不存在或已删除")
+
+var (
+	outboxStart sync.Once
+	// outboxKick 有新记录入箱或被重新投递时唤醒轮询, 不必等到下一个轮询周期
+	outboxKick = make(chan struct{}, 1)
+	// outboxOwner 本实例标识, 记录在领取的记录上, 重启后据此恢复本实例未完成的记录
+	outboxOwner = func() string {
+		if hostname, err := os.Hostname(); err == nil && hostname != "" {
+			return hostname
+		}
+		return "finops-extend"
+	}()
+)
+
+// StartNotifyOutbox 启动发件箱工作协程; 启动前先把本实例上次退出时发送中的记录和租约已过期的记录恢复为 pending
+func StartNotifyOutbox() {
+	outboxStart.Do(startOutboxWorkers)
+}
+
+// enqueueNotifications 在 db 中把一次告警通知的各渠道发送任务写入发件箱
+// db 通常是写入告警的事务, 由调用方在提交后唤醒发件箱
+func enqueueNotifications(db *gorm.DB, alertId int, tasks []notifyTask) error {
+	now := time.Now()
+	rows := make([]observe.NotifyOutbox, 0, len(tasks))
+	for _, task := range tasks {
+		rows = append(rows, observe.NotifyOutbox{
+			AlertId:       alertId,
+			RouteId:       task.routeId,
+			Channel:       task.notifier.Name(),
+			Receivers:     task.receivers,
+			Status:        observe.OutboxPending,
+			NextAttemptAt: now,
+			CreateTime:    common.JSONTime{Time: now},
+			UpdateTime:    common.JSONTime{Time: now},
+		})
+	}
+	return db.Create(&rows).Error
+}
+
+func kickOutbox() {
+	select {
+	case outboxKick <- struct{}{}:
+	default:
+	}
+}
+
+// outboxConfig 发件箱配置, 未配置的项使用默认值
+func outboxConfig() config.NotifyOutbox {
+	cfg := global.GVA_CONFIG.Notify.Outbox
+	if cfg.Workers <= 0 {
+		cfg.Workers = defaultOutboxWorkers
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultOutboxBatchSize
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = defaultOutboxPollInterval
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = defaultOutboxMaxAttempts
+	}
+	if cfg.RetryBackoff <= 0 {
+		cfg.RetryBackoff = defaultOutboxRetryBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = defaultOutboxMaxBackoff
+	}
+	if cfg.Lease <= 0 {
+		cfg.Lease = defaultOutboxLease
+	}
+	return cfg
+}
+
+func startOutboxWorkers() {
+	cfg := outboxConfig()
+	recovered := global.GVA_DB.Model(&observe.NotifyOutbox{}).
+		Where("status = ? AND (locked_by = ? OR locked_until < ?)", observe.OutboxSending, outboxOwner, time.Now()).
+		Updates(map[string]interface{}{
+			"status":      observe.OutboxPending,
+			"locked_by":   "",
+			"update_time": common.JSONTime{Time: time.Now()},
+		})
+	if recovered.Error != nil {
+		global.GVA_LOG.Error("恢复发件箱记录失败", zap.Error(recovered.Error))
+	} else if recovered.RowsAffected > 0 {
+		global.GVA_LOG.Info("已恢复上次未完成的通知", zap.Int64("count", recovered.RowsAffected))
+	}
+
+	// 工作协程数即并发发送上限, 协程都忙时轮询阻塞在投递上, 不会领取更多记录
+	jobs := make(chan observe.NotifyOutbox)
+	for i := 0; i < cfg.Workers; i++ {
+		go func() {
+			for row := range jobs {
+				deliverOutbox(row)
+			}
+		}()
+	}
+	go func() {
+		ticker := time.NewTicker(cfg.PollInterval)
+		defer ticker.Stop()
+		for {
+			pollOutbox(jobs)
+			select {
+			case <-ticker.C:
+			case <-outboxKick:
+			}
+		}
+	}()
+	global.GVA_LOG.Info("通知发件箱已启动", zap.Int("workers", cfg.Workers), zap.String("owner", outboxOwner))
+}
+
+// pollOutbox 释放租约过期的记录, 领取到期的 pending 记录交给工作协程
+// 领取使用带状态条件的 UPDATE, 多个副本同时轮询时每条记录只会被一个副本领取
+func pollOutbox(jobs chan<- observe.NotifyOutbox) {
+	cfg := outboxConfig()
+	now := time.Now()
+	err := global.GVA_DB.Model(&observe.NotifyOutbox{}).
+		Where("status = ? AND locked_until < ?", observe.OutboxSending, now).
+		Updates(map[string]interface{}{
+			"status":      observe.OutboxPending,
+			"locked_by":   "",
+			"update_time": common.JSONTime{Time: now},
+		}).Error
+	if err != nil {
+		global.GVA_LOG.Error("释放过期的发件箱租约失败", zap.Error(err))
+	}
+
+	var due []observe.NotifyOutbox
+	err = global.GVA_DB.Where("status = ? AND next_attempt_at <= ?", observe.OutboxPending, now).
+		Order("next_attempt_at, outbox_id").Limit(cfg.BatchSize).Find(&due).Error
+	if err != nil {
+		global.GVA_LOG.Error("查询待发送的通知失败", zap.Error(err))
+		return
+	}
+	for _, row := range due {
+		lockedUntil := time.Now().Add(cfg.Lease)
+		claimed := global.GVA_DB.Model(&observe.NotifyOutbox{}).
+			Where("outbox_id = ? AND status = ?", row.OutboxId, observe.OutboxPending).
+			Updates(map[string]interface{}{
+				"status":       observe.OutboxSending,
+				"attempts":     gorm.Expr("attempts + 1"),
+				"locked_by":    outboxOwner,
+				"locked_until": lockedUntil,
+				"update_time":  common.JSONTime{Time: time.Now()},
+			})
+		if claimed.Error != nil {
+			global.GVA_LOG.Error("领取发件箱记录失败", zap.Error(claimed.Error), zap.Int64("outboxId", row.OutboxId))
+			continue
+		}
+		if claimed.RowsAffected == 0 {
+			continue
+		}
+		row.Status = observe.OutboxSending
+		row.Attempts++
+		row.LockedBy = outboxOwner
+		row.LockedUntil = &lockedUntil
+		jobs <- row
+	}
+}
+
+// deliverOutbox 发送一条已领取的记录并更新其状态; 失败时按指数退避重新排期, 达到最大尝试次数或告警已删除时进入 dead
+// 通知配额在入箱时已预占, 重试不再占用配额
+func deliverOutbox(row observe.NotifyOutbox) {
+	err := sendOutbox(row)
+	now := time.Now()
+	updates := map[string]interface{}{
+		"locked_by":   "",
+		"update_time": common.JSONTime{Time: now},
+	}
+	result := observe.NotifySent
+	if err == nil {
+		updates["status"] = observe.OutboxSent
+		updates["sent_time"] = now
+		updates["last_error"] = ""
+	} else {
+		result = observe.NotifyFailed
+		updates["last_error"] = truncateError(err.Error(), maxOutboxError)
+		cfg := outboxConfig()
+		if row.Attempts >= cfg.MaxAttempts || errors.Is(err, errAlertDeleted) {
+			updates["status"] = observe.OutboxDead
+			notificationsDead.WithLabelValues(row.Channel).Inc()
+		} else {
+			updates["status"] = observe.OutboxPending
+			updates["next_attempt_at"] = now.Add(outboxBackoff(cfg, row.Attempts))
+		}
+		global.GVA_LOG.Error("通知发送失败",
+			zap.Int64("outboxId", row.OutboxId),
+			zap.String("channel", row.Channel),
+			zap.Int("alertId", row.AlertId),
+			zap.Int("attempts", row.Attempts),
+			zap.Any("status", updates["status"]),
+			zap.Error(err),
+		)
+	}
+	alertNotifications.WithLabelValues(row.Channel, result).Inc()
+	if row.Channel == ChannelMQ && err != nil {
+		alertsMQFailed.Inc()
+	}
+
+	// 只更新本次领取的记录, 租约过期后被重新领取的记录以新一次的结果为准; 每次领取 attempts 加一, 可区分不同的领取
+	updated := global.GVA_DB.Model(&observe.NotifyOutbox{}).
+		Where("outbox_id = ? AND status = ? AND locked_by = ? AND attempts = ?", row.OutboxId, observe.OutboxSending, outboxOwner, row.Attempts).
+		Updates(updates)
+	if updated.Error != nil {
+		global.GVA_LOG.Error("更新发件箱记录失败", zap.Error(updated.Error), zap.Int64("outboxId", row.OutboxId))
+		return
+	}
+	if updated.RowsAffected == 0 {
+		global.GVA_LOG.Warn("发件箱记录的租约已过期, 忽略本次结果", zap.Int64("outboxId", row.OutboxId))
+		return
+	}
+
+	if err == nil {
+		alertsNotified.Inc()
+		dedupService := AlertDedupService{}
+		// 发送成功，确认通知已发送(清除NotifyPending)
+		if confirmErr := dedupService.ConfirmNotifySent(row.AlertId); confirmErr != nil {
+			global.GVA_LOG.Error("确认通知发送状态失败", zap.Error(confirmErr), zap.Int("alertId", row.AlertId))
+		}
+	}
+	if refreshErr := refreshNotifyResult(row.AlertId); refreshErr != nil {
+		global.GVA_LOG.Error("记录通知结果失败", zap.Error(refreshErr), zap.Int("alertId", row.AlertId))
+	}
+}
+
+// sendOutbox 以告警的最新内容发送一条记录
+func sendOutbox(row observe.NotifyOutbox) error {
+	var alert observe.PrometheusAlert
+	if err := global.GVA_DB.Where("alert_id = ? AND is_deleted = 0", row.AlertId).First(&alert).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errAlertDeleted
+		}
+		return fmt.Errorf("查询告警失败: %w", err)
+	}
+	notifierRegistry.RLock()
+	n, ok := notifierRegistry.notifiers[row.Channel]
+	notifierRegistry.RUnlock()
+	if !ok {
+		return fmt.Errorf("未知的通知渠道: %s", row.Channel)
+	}
+	return n.Notify(context.Background(), alert, row.Receivers)
+}
+
+// outboxBackoff 第 attempts 次失败后的重试间隔: RetryBackoff * 2^(attempts-1), 不超过 MaxBackoff
+func outboxBackoff(cfg config.NotifyOutbox, attempts int) time.Duration {
+	backoff := cfg.RetryBackoff
+	for i := 1; i < attempts && backoff < cfg.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > cfg.MaxBackoff {
+		backoff = cfg.MaxBackoff
+	}
+	return backoff
+}
+
+// refreshNotifyResult 根据发件箱重新计算告警的 notify_result, 每个路由+渠道取最近一次通知的记录
+// 锁住告警行后再读发件箱, 多个工作协程同时完成同一告警的记录时结果不会互相覆盖
+func refreshNotifyResult(alertId int) error {
+	return global.GVA_DB.Transaction(func(tx *gorm.DB) error {
+		var alert observe.PrometheusAlert
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("alert_id").
+			Where("alert_id = ?", alertId).First(&alert).Error; err != nil {
+			return err
+		}
+		var rows []observe.NotifyOutbox
+		if err := tx.Where("alert_id = ?", alertId).Order("outbox_id desc").Limit(200).Find(&rows).Error; err != nil {
+			return err
+		}
+
+		var results observe.NotifyResults
+		seen := map[string]bool{}
+		for _, row := range rows {
+			key := fmt.Sprintf("%d/%s", row.RouteId, row.Channel)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			result := observe.NotifyChannelResult{
+				Channel:   row.Channel,
+				RouteId:   row.RouteId,
+				Receivers: row.Receivers,
+				Attempts:  row.Attempts,
+				Error:     row.LastError,
+				Time:      row.UpdateTime.Time,
+			}
+			switch row.Status {
+			case observe.OutboxSent:
+				result.Result = observe.NotifySent
+			case observe.OutboxDead:
+				result.Result = observe.NotifyFailed
+			default:
+				if row.Attempts == 0 {
+					continue
+				}
+				result.Result = observe.NotifyRetrying
+			}
+			results = append(results, result)
+		}
+		return tx.Model(&observe.PrometheusAlert{}).Where("alert_id = ?", alertId).
+			UpdateColumn("notify_result", results).Error
+	})
+}
+
+func truncateError(msg string, max int) string {
+	if len(msg) <= max {
+		return msg
+	}
+	return msg[:max-3] + "..."
+}
+
+// GetNotification 根据ID获取发件箱记录
+func (s *NotifyOutboxService) GetNotification(id int64) (err error, row observe.NotifyOutbox) {
+	err = global.GVA_DB.Where("outbox_id = ?", id).First(&row).Error
+	return err, row
+}
+
+// GetNotificationList 分页获取发件箱记录
+func (s *NotifyOutboxService) GetNotificationList(info observe.NotifyOutboxSearch) (err error, list []observe.NotifyOutbox, total int64) {
+	limit := info.PageSize
+	if limit == 0 {
+		limit = 10
+	}
+	offset := limit * (info.PageNumber - 1)
+	if info.PageNumber == 0 {
+		offset = 0
+	}
+
+	db := global.GVA_DB.Model(&observe.NotifyOutbox{})
+	if info.AlertId != 0 {
+		db = db.Where("alert_id = ?", info.AlertId)
+	}
+	if info.Channel != "" {
+		db = db.Where("channel = ?", info.Channel)
+	}
+	if info.Status != "" {
+		db = db.Where("status = ?", info.Status)
+	}
+
+	err = db.Count(&total).Error
+	if err != nil {
+		return
+	}
+
+	err = db.Limit(limit).Offset(offset).Order("outbox_id desc").Find(&list).Error
+	return err, list, total
+}
+
+// RetryNotifications 重新投递 dead 状态的记录: 尝试次数清零并立即发送, 返回重新投递的记录数
+// 等待重试的记录仍由发件箱按退避重试, 发送中的记录可能正被工作协程发送, 都不重新投递
+func (s *NotifyOutboxService) RetryNotifications(ids []int64) (err error, retried int64) {
+	if len(ids) == 0 {
+		return errors.New("未指定要重新投递的记录"), 0
+	}
+	now := time.Now()
+	result := global.GVA_DB.Model(&observe.NotifyOutbox{}).
+		Where("outbox_id in ? AND status = ?", ids, observe.OutboxDead).
+		Updates(map[string]interface{}{
+			"status":          observe.OutboxPending,
+			"attempts":        0,
+			"next_attempt_at": now,
+			"update_time":     common.JSONTime{Time: now},
+		})
+	if result.Error != nil {
+		return result.Error, 0
+	}
+	kickOutbox()
+	return nil, result.RowsAffected
+}
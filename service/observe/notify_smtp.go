@@ -0,0 +1,145 @@
+package observe
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"main.go/config"
+	"main.go/global"
+	"main.go/model/observe"
+)
+
+// SMTP 连接的加密方式
+const (
+	smtpTlsAuto     = "auto"     // 服务端支持时 STARTTLS
+	smtpTlsStartTLS = "starttls" // 必须 STARTTLS
+	smtpTlsImplicit = "tls"      // 直接 TLS 连接(通常为 465 端口)
+	smtpTlsNone     = "none"     // 明文
+)
+
+// smtpRootCAs 校验 SMTP 服务端证书的根证书, 为 nil 时使用系统根证书
+var smtpRootCAs *x509.CertPool
+
+// SmtpNotifier SMTP 邮件渠道, receivers 为收件人地址
+type SmtpNotifier struct{}
+
+// Name 实现 Notifier
+func (n *SmtpNotifier) Name() string {
+	return ChannelSmtp
+}
+
+// Notify 实现 Notifier, receivers 为空时发给 notify.smtp.to
+func (n *SmtpNotifier) Notify(ctx context.Context, alert observe.PrometheusAlert, receivers []string) error {
+	cfg := global.GVA_CONFIG.Notify.Smtp
+	if cfg.Host == "" || cfg.From == "" {
+		return errors.New("未配置 notify.smtp.host 或 notify.smtp.from")
+	}
+	to := receivers
+	if len(to) == 0 {
+		to = cfg.To
+	}
+	if len(to) == 0 {
+		return errors.New("没有收件人")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, notifyTimeout(cfg.Timeout))
+	defer cancel()
+	return sendMail(ctx, cfg, to, n.buildMessage(alert, cfg.From, to, time.Now()))
+}
+
+// buildMessage 构建纯文本邮件, 主题与 MQ 消息标题一致
+func (n *SmtpNotifier) buildMessage(alert observe.PrometheusAlert, from string, to []string, now time.Time) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", BuildEmailSubject(alert.Status, alert.Labels)))
+	fmt.Fprintf(&b, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(buildNotifyContent(alert).text(), "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return b.Bytes()
+}
+
+// sendMail 按 cfg.Tls 建立连接并投递邮件, 配置 username 时使用 PLAIN 认证
+func sendMail(ctx context.Context, cfg config.NotifySmtp, to []string, msg []byte) error {
+	port := cfg.Port
+	if port == 0 {
+		port = 25
+	}
+	addr := net.JoinHostPort(cfg.Host, strconv.Itoa(port))
+	tlsConfig := &tls.Config{ServerName: cfg.Host, RootCAs: smtpRootCAs}
+
+	dialer := &net.Dialer{}
+	var conn net.Conn
+	var err error
+	if cfg.Tls == smtpTlsImplicit {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("连接SMTP服务器失败: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	c, err := smtp.NewClient(conn, cfg.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("SMTP握手失败: %w", err)
+	}
+	defer c.Close()
+
+	switch cfg.Tls {
+	case smtpTlsImplicit, smtpTlsNone:
+	case smtpTlsStartTLS, smtpTlsAuto, "":
+		if ok, _ := c.Extension("STARTTLS"); ok {
+			if err := c.StartTLS(tlsConfig); err != nil {
+				return fmt.Errorf("SMTP STARTTLS失败: %w", err)
+			}
+		} else if cfg.Tls == smtpTlsStartTLS {
+			return errors.New("SMTP服务器不支持STARTTLS")
+		}
+	default:
+		return fmt.Errorf("未知的 notify.smtp.tls: %s", cfg.Tls)
+	}
+
+	if cfg.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)); err != nil {
+			return fmt.Errorf("SMTP认证失败: %w", err)
+		}
+	}
+	if err := c.Mail(cfg.From); err != nil {
+		return fmt.Errorf("SMTP MAIL FROM失败: %w", err)
+	}
+	for _, rcpt := range to {
+		if err := c.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("SMTP RCPT TO %s失败: %w", rcpt, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("SMTP DATA失败: %w", err)
+	}
+	if _, err := w.Write(msg); err != nil {
+		return fmt.Errorf("写入邮件失败: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("投递邮件失败: %w", err)
+	}
+	return c.Quit()
+}
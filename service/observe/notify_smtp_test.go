@@ -0,0 +1,248 @@
+package observe
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"main.go/config"
+)
+
+// fakeMail SMTP 测试服务端收到的一封邮件
+type fakeMail struct {
+	TLS  bool
+	From string
+	To   []string
+	Data string
+}
+
+// fakeSMTP 只实现投递所需命令的 SMTP 服务端, tlsConfig 为 nil 时不支持 STARTTLS
+type fakeSMTP struct {
+	ln        net.Listener
+	tlsConfig *tls.Config
+
+	mu    sync.Mutex
+	mails []fakeMail
+}
+
+func startFakeSMTP(t *testing.T, tlsConfig *tls.Config) *fakeSMTP {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeSMTP{ln: ln, tlsConfig: tlsConfig}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+// config 指向测试服务端的 notify.smtp 配置
+func (s *fakeSMTP) config(mode string) config.NotifySmtp {
+	addr := s.ln.Addr().(*net.TCPAddr)
+	return config.NotifySmtp{Host: addr.IP.String(), Port: addr.Port, Tls: mode, From: "finops@example.com", Timeout: 5}
+}
+
+func (s *fakeSMTP) received() []fakeMail {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]fakeMail(nil), s.mails...)
+}
+
+func (s *fakeSMTP) serve(conn net.Conn) {
+	defer func() { conn.Close() }()
+	tc := textproto.NewConn(conn)
+	secured := false
+	var mail fakeMail
+	_ = tc.PrintfLine("220 fake ESMTP")
+	for {
+		line, err := tc.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			if s.tlsConfig != nil && !secured {
+				_ = tc.PrintfLine("250-fake")
+				_ = tc.PrintfLine("250 STARTTLS")
+			} else {
+				_ = tc.PrintfLine("250 fake")
+			}
+		case "STARTTLS":
+			_ = tc.PrintfLine("220 ready to start TLS")
+			tlsConn := tls.Server(conn, s.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn, tc, secured = tlsConn, textproto.NewConn(tlsConn), true
+		case "MAIL":
+			mail = fakeMail{TLS: secured, From: mailbox(arg)}
+			_ = tc.PrintfLine("250 ok")
+		case "RCPT":
+			mail.To = append(mail.To, mailbox(arg))
+			_ = tc.PrintfLine("250 ok")
+		case "DATA":
+			_ = tc.PrintfLine("354 go ahead")
+			data, err := tc.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			mail.Data = string(data)
+			s.mu.Lock()
+			s.mails = append(s.mails, mail)
+			s.mu.Unlock()
+			_ = tc.PrintfLine("250 queued")
+		case "QUIT":
+			_ = tc.PrintfLine("221 bye")
+			return
+		default:
+			_ = tc.PrintfLine("502 not implemented")
+		}
+	}
+}
+
+// mailbox 取出 "FROM:<a@b>" 中的地址
+func mailbox(arg string) string {
+	start, end := strings.Index(arg, "<"), strings.Index(arg, ">")
+	if start < 0 || end < start {
+		return arg
+	}
+	return arg[start+1 : end]
+}
+
+// selfSignedTLS 为 127.0.0.1 签发自签名证书, 返回服务端配置和信任它的根证书池
+func selfSignedTLS(t *testing.T) (*tls.Config, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "fake-smtp"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}, pool
+}
+
+func TestSendMail(t *testing.T) {
+	serverTLS, roots := selfSignedTLS(t)
+	saved := smtpRootCAs
+	smtpRootCAs = roots
+	t.Cleanup(func() { smtpRootCAs = saved })
+
+	for _, tc := range []struct {
+		name     string
+		mode     string
+		starttls bool // 服务端是否支持 STARTTLS
+		wantTLS  bool
+		wantErr  string
+	}{
+		{name: "none 不使用 STARTTLS", mode: smtpTlsNone, starttls: true},
+		{name: "starttls", mode: smtpTlsStartTLS, starttls: true, wantTLS: true},
+		{name: "starttls 服务端不支持", mode: smtpTlsStartTLS, wantErr: "SMTP服务器不支持STARTTLS"},
+		{name: "auto 服务端支持", mode: smtpTlsAuto, starttls: true, wantTLS: true},
+		{name: "auto 服务端不支持时明文", mode: smtpTlsAuto},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var tlsConfig *tls.Config
+			if tc.starttls {
+				tlsConfig = serverTLS
+			}
+			srv := startFakeSMTP(t, tlsConfig)
+			cfg := srv.config(tc.mode)
+			to := []string{"ops@example.com", "dba@example.com"}
+			msg := (&SmtpNotifier{}).buildMessage(testAlert(), cfg.From, to, time.Now())
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			err := sendMail(ctx, cfg, to, msg)
+			if tc.wantErr != "" {
+				if err == nil || err.Error() != tc.wantErr {
+					t.Fatalf("err = %v, 期望 %s", err, tc.wantErr)
+				}
+				if mails := srv.received(); len(mails) != 0 {
+					t.Fatalf("不应投递邮件, 收到 %d 封", len(mails))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			mails := srv.received()
+			if len(mails) != 1 {
+				t.Fatalf("收到 %d 封邮件, 期望 1", len(mails))
+			}
+			mail := mails[0]
+			if mail.TLS != tc.wantTLS {
+				t.Errorf("TLS = %v, 期望 %v", mail.TLS, tc.wantTLS)
+			}
+			if mail.From != cfg.From || strings.Join(mail.To, ",") != strings.Join(to, ",") {
+				t.Errorf("信封 = %s -> %v", mail.From, mail.To)
+			}
+			if !strings.Contains(mail.Data, "Subject: =?UTF-8?b?") || !strings.Contains(mail.Data, "触发数值: 93%") {
+				t.Errorf("邮件内容不符: %q", mail.Data)
+			}
+		})
+	}
+}
+
+func TestSendMailUnknownMode(t *testing.T) {
+	srv := startFakeSMTP(t, nil)
+	err := sendMail(context.Background(), srv.config("ssl"), []string{"ops@example.com"}, []byte("x"))
+	if err == nil || !strings.Contains(err.Error(), "ssl") {
+		t.Fatalf("err = %v, 期望未知加密方式的错误", err)
+	}
+}
+
+func TestSmtpNotifierDefaultRecipients(t *testing.T) {
+	srv := startFakeSMTP(t, nil)
+	withServerConfig(t, func(c *config.Server) {
+		c.Notify.Smtp = srv.config(smtpTlsNone)
+		c.Notify.Smtp.To = []string{"oncall@example.com"}
+	})
+	if err := (&SmtpNotifier{}).Notify(context.Background(), testAlert(), nil); err != nil {
+		t.Fatal(err)
+	}
+	mails := srv.received()
+	if len(mails) != 1 || strings.Join(mails[0].To, ",") != "oncall@example.com" {
+		t.Fatalf("收件人 = %v, 期望 notify.smtp.to", mails)
+	}
+}
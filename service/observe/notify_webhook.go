@@ -0,0 +1,78 @@
+package observe
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"main.go/global"
+	"main.go/model/observe"
+)
+
+// 通用 webhook 的签名请求头
+const (
+	WebhookTimestampHeader = "X-Finops-Timestamp"
+	WebhookSignatureHeader = "X-Finops-Signature"
+)
+
+// WebhookNotifier 通用 HTTP webhook 渠道
+// 配置 secret 时, 签名为 "sha256=" + hex(HMAC-SHA256(secret, 时间戳 + "." + 请求体)),
+// 接收方应校验签名并拒绝时间戳过旧的请求以防重放
+type WebhookNotifier struct{}
+
+// Name 实现 Notifier
+func (n *WebhookNotifier) Name() string {
+	return ChannelWebhook
+}
+
+// Notify 实现 Notifier, receivers 原样放入消息体
+func (n *WebhookNotifier) Notify(ctx context.Context, alert observe.PrometheusAlert, receivers []string) error {
+	cfg := global.GVA_CONFIG.Notify.Webhook
+	if cfg.Url == "" {
+		return errors.New("未配置 notify.webhook.url")
+	}
+	body, err := json.Marshal(n.buildMessage(alert, receivers))
+	if err != nil {
+		return fmt.Errorf("序列化webhook消息失败: %w", err)
+	}
+
+	headers := make(map[string]string, len(cfg.Headers)+2)
+	for k, v := range cfg.Headers {
+		headers[k] = v
+	}
+	if cfg.Secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		headers[WebhookTimestampHeader] = timestamp
+		headers[WebhookSignatureHeader] = SignWebhook(cfg.Secret, timestamp, body)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, notifyTimeout(cfg.Timeout))
+	defer cancel()
+	_, err = postJSON(ctx, cfg.Url, body, headers)
+	return err
+}
+
+// buildMessage 构建通用 webhook 消息体
+func (n *WebhookNotifier) buildMessage(alert observe.PrometheusAlert, receivers []string) observe.WebhookNotifyMessage {
+	return observe.WebhookNotifyMessage{
+		Title:     BuildEmailSubject(alert.Status, alert.Labels),
+		Receivers: receivers,
+		Detail:    buildAlertDetail(alert),
+		Alert:     alert,
+	}
+}
+
+// SignWebhook 计算通用 webhook 的签名, 接收方用同样的方式校验
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
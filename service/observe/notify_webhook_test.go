@@ -0,0 +1,88 @@
+package observe
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"main.go/config"
+	"main.go/model/observe"
+)
+
+func TestWebhookNotifierSignature(t *testing.T) {
+	srv, requests := captureServer(t, http.StatusOK, "")
+	withServerConfig(t, func(cfg *config.Server) {
+		cfg.Notify.Webhook = config.NotifyWebhook{
+			Url:     srv.URL,
+			Secret:  "s3cret",
+			Headers: map[string]string{"X-Tenant": "paas"},
+		}
+	})
+
+	if err := (&WebhookNotifier{}).Notify(context.Background(), testAlert(), []string{"ops", "dba"}); err != nil {
+		t.Fatal(err)
+	}
+	got := requests()
+	if len(got) != 1 {
+		t.Fatalf("收到 %d 个请求, 期望 1", len(got))
+	}
+	req := got[0]
+
+	timestamp := req.Header.Get(WebhookTimestampHeader)
+	if timestamp == "" {
+		t.Fatalf("缺少 %s", WebhookTimestampHeader)
+	}
+	if sig, want := req.Header.Get(WebhookSignatureHeader), SignWebhook("s3cret", timestamp, req.Body); sig != want {
+		t.Errorf("%s = %q, 期望 %q", WebhookSignatureHeader, sig, want)
+	}
+	if sig := req.Header.Get(WebhookSignatureHeader); sig == SignWebhook("other", timestamp, req.Body) {
+		t.Errorf("不同密钥得到相同签名 %q", sig)
+	}
+	if v := req.Header.Get("X-Tenant"); v != "paas" {
+		t.Errorf("X-Tenant = %q, 期望 paas", v)
+	}
+
+	// alert 按 map 解析, NullTime 只用于写库, 不支持 JSON 反序列化
+	var msg struct {
+		observe.WebhookNotifyMessage
+		Alert map[string]interface{} `json:"alert"`
+	}
+	decodeBody(t, req, &msg)
+	if msg.Title != BuildEmailSubject("firing", testAlert().Labels) {
+		t.Errorf("title = %q", msg.Title)
+	}
+	if strings.Join(msg.Receivers, ",") != "ops,dba" {
+		t.Errorf("receivers = %v, 期望 [ops dba]", msg.Receivers)
+	}
+	if msg.Detail.TriggerValue != "93%" || msg.Alert["fingerprint"] != "f1" {
+		t.Errorf("消息体缺少告警内容: %+v", msg)
+	}
+}
+
+func TestWebhookNotifierUnsigned(t *testing.T) {
+	srv, requests := captureServer(t, http.StatusOK, "")
+	withServerConfig(t, func(cfg *config.Server) {
+		cfg.Notify.Webhook = config.NotifyWebhook{Url: srv.URL}
+	})
+
+	if err := (&WebhookNotifier{}).Notify(context.Background(), testAlert(), nil); err != nil {
+		t.Fatal(err)
+	}
+	req := requests()[0]
+	if req.Header.Get(WebhookSignatureHeader) != "" || req.Header.Get(WebhookTimestampHeader) != "" {
+		t.Errorf("未配置 secret 时不应签名: %v", req.Header)
+	}
+}
+
+func TestWebhookNotifierErrorStatus(t *testing.T) {
+	srv, _ := captureServer(t, http.StatusBadGateway, "upstream down")
+	withServerConfig(t, func(cfg *config.Server) {
+		cfg.Notify.Webhook = config.NotifyWebhook{Url: srv.URL}
+	})
+
+	err := (&WebhookNotifier{}).Notify(context.Background(), testAlert(), nil)
+	if err == nil || !strings.Contains(err.Error(), "502") || !strings.Contains(err.Error(), "upstream down") {
+		t.Fatalf("err = %v, 期望包含状态码和响应体", err)
+	}
+}
@@ -1,6 +1,8 @@
 package observe
 
 import (
+	"errors"
+	"fmt"
 	"time"
 
 	"go.uber.org/zap"
@@ -15,26 +17,113 @@ import (
 type ObserveAlertService struct {
 }
 
+// 通知结果
+const (
+	notifyQueued      = "queued"       // 已预占配额, 已写入发件箱
+	notifyRateLimited = "rate_limited" // 已达每日通知上限
+	notifySilenced    = "silenced"     // 被静默抑制, 不占用配额
+	notifyError       = "error"        // 预占配额失败
+)
+
 // CreateAlert 创建告警(含去重和通知限流逻辑)
 // 使用 Upsert 模式解决并发问题：通过数据库唯一约束保证同一指纹的告警只有一条记录
+// 告警、通知配额与发件箱记录在同一事务中写入, 进程在两者之间退出时不会丢失通知
 func (m *ObserveAlertService) CreateAlert(req observe.AlertRequest) (err error, alert observe.PrometheusAlert) {
+	record, err := newAlertRecord(req, time.Now())
+	if err != nil {
+		return err, record
+	}
+	notify := ""
+	err = global.GVA_DB.Transaction(func(tx *gorm.DB) error {
+		saved, err := upsertAlert(tx, record)
+		if err != nil {
+			return err
+		}
+		alert = saved
+		notify = notifyAlert(tx, alert)
+		return nil
+	})
+	if err != nil {
+		return err, record
+	}
+	if notify == notifyQueued {
+		kickOutbox()
+	}
+	return nil, alert
+}
+
+// CreateAlertBatch 批量创建告警, 每条告警走与 CreateAlert 相同的指纹、Upsert 与通知流程
+// 整批在一个事务中写入, 单条失败回滚到该条的保存点并在结果中报告, 不影响其余告警;
+// 通知与告警在同一事务中写入发件箱, 事务提交后才会被发送, 回滚的告警不会留下通知
+func (m *ObserveAlertService) CreateAlertBatch(reqs []observe.AlertRequest) (err error, results []observe.AlertBatchResult) {
+	now := time.Now()
+	results = make([]observe.AlertBatchResult, len(reqs))
+	queued := false
+	err = global.GVA_DB.Transaction(func(tx *gorm.DB) error {
+		for i, req := range reqs {
+			results[i].Index = i
+			record, err := newAlertRecord(req, now)
+			if err != nil {
+				results[i].Result = observe.AlertBatchInvalid
+				results[i].Error = err.Error()
+				continue
+			}
+			results[i].Fingerprint = record.Fingerprint
+
+			savePoint := fmt.Sprintf("alert_%d", i)
+			if err := tx.SavePoint(savePoint).Error; err != nil {
+				return err
+			}
+			alert, err := upsertAlert(tx, record)
+			if err != nil {
+				if rollbackErr := tx.RollbackTo(savePoint).Error; rollbackErr != nil {
+					return rollbackErr
+				}
+				results[i].Result = observe.AlertBatchFailed
+				results[i].Error = err.Error()
+				continue
+			}
+			results[i].AlertId = alert.AlertId
+			results[i].Result = observe.AlertBatchCreated
+			if alert.AlertCount > 1 {
+				results[i].Result = observe.AlertBatchDeduplicated
+			}
+			results[i].Notify = notifyAlert(tx, alert)
+			queued = queued || results[i].Notify == notifyQueued
+		}
+		return nil
+	})
+	if err != nil {
+		return err, nil
+	}
+	if queued {
+		kickOutbox()
+	}
+	return nil, results
+}
+
+// newAlertRecord 解析请求并构建待写入的告警记录
+func newAlertRecord(req observe.AlertRequest, now time.Time) (alert observe.PrometheusAlert, err error) {
 	startsAt, err := time.Parse(time.RFC3339, req.StartsAt)
 	if err != nil {
-		return err, alert
+		return alert, err
 	}
 
 	var endsAt time.Time
 	if req.EndsAt != "" {
 		endsAt, err = time.Parse(time.RFC3339, req.EndsAt)
 		if err != nil {
-			return err, alert
+			return alert, err
 		}
 	}
+	alertsIngested.WithLabelValues(req.Status).Inc()
 
 	// 生成告警指纹（不包含状态，以便firing和resolved可以匹配）
-	dedupService := AlertDedupService{}
-	fingerprint := dedupService.GenerateFingerprint(req.Labels)
-	now := time.Now()
+	fingerprint := req.Fingerprint
+	if fingerprint == "" {
+		dedupService := AlertDedupService{}
+		fingerprint = dedupService.GenerateFingerprint(req.Labels)
+	}
 
 	// 构建告警对象
 	alert = observe.PrometheusAlert{
@@ -52,69 +141,110 @@ func (m *ObserveAlertService) CreateAlert(req observe.AlertRequest) (err error,
 		CreateTime:       common.JSONTime{Time: now},
 		UpdateTime:       common.JSONTime{Time: now},
 	}
+	return alert, nil
+}
 
+// upsertAlert 按指纹原子插入或合并告警, 返回写入后的最新记录
+func upsertAlert(db *gorm.DB, alert observe.PrometheusAlert) (observe.PrometheusAlert, error) {
 	// 原子 Upsert: 插入或更新
 	// 当 fingerprint+is_deleted 冲突时，更新现有记录并增加 alert_count
-	err = global.GVA_DB.Clauses(clause.OnConflict{
+	err := db.Clauses(clause.OnConflict{
 		Columns: []clause.Column{{Name: "fingerprint"}, {Name: "is_deleted"}},
 		DoUpdates: clause.Assignments(map[string]interface{}{
-			"status":      req.Status,
-			"starts_at":   startsAt,
-			"ends_at":     endsAt,
-			"annotations": req.Annotations,
-			"labels":      req.Labels,
+			"status":      alert.Status,
+			"starts_at":   *alert.StartsAt.Time,
+			"ends_at":     *alert.EndsAt.Time,
+			"annotations": alert.Annotations,
+			"labels":      alert.Labels,
 			"alert_count": gorm.Expr("alert_count + 1"),
-			"update_time": now,
+			"update_time": alert.UpdateTime.Time,
 		}),
 	}).Create(&alert).Error
 
 	if err != nil {
-		return err, alert
+		return alert, err
 	}
 
 	// 获取最新记录（upsert 后需要获取完整数据，包括正确的 alert_id 和 alert_count）
-	err = global.GVA_DB.Where("fingerprint = ? AND is_deleted = 0", fingerprint).First(&alert).Error
+	err = db.Where("fingerprint = ? AND is_deleted = 0", alert.Fingerprint).First(&alert).Error
 	if err != nil {
-		return err, alert
+		return alert, err
+	}
+	if alert.AlertCount > 1 {
+		alertsDeduplicated.Inc()
+	}
+	return alert, nil
+}
+
+// notifyAlert 判断是否需要发送通知(未被静默且未达每日上限), 需要时按告警路由把各渠道的通知写入发件箱(失败仅记录日志，不影响主流程)
+// tx 为写入告警的事务, 配额预占与入箱随告警一同提交; 入箱失败时回滚到预占前的保存点, 告警照常写入
+// 发件箱负责发送与重试, 各渠道的发送结果记录到告警的 notify_result
+func notifyAlert(tx *gorm.DB, alert observe.PrometheusAlert) string {
+	dedupService := AlertDedupService{}
+
+	// 生效中的静默匹配时不发送通知, 静默ID记录到告警上; 查询静默失败时照常通知
+	silenceId := 0
+	silence, silenceErr := matchSilence(alert.Labels, time.Now())
+	if silenceErr != nil {
+		global.GVA_LOG.Error("查询静默失败", zap.Error(silenceErr), zap.Int("alertId", alert.AlertId))
+	} else if silence != nil {
+		silenceId = silence.SilenceId
+	}
+	if silenceErr == nil && silenceId != alert.SilenceId {
+		if err := dedupService.RecordSilence(tx, alert.AlertId, silenceId); err != nil {
+			global.GVA_LOG.Error("记录静默ID失败", zap.Error(err), zap.Int("alertId", alert.AlertId))
+		}
+	}
+	if silenceId != 0 {
+		alertsSilenced.Inc()
+		global.GVA_LOG.Info("跳过通知(已静默)",
+			zap.Int("alertId", alert.AlertId),
+			zap.String("fingerprint", alert.Fingerprint),
+			zap.Int("silenceId", silenceId),
+		)
+		return notifySilenced
+	}
+
+	savePoint := fmt.Sprintf("notify_%d", alert.AlertId)
+	if err := tx.SavePoint(savePoint).Error; err != nil {
+		global.GVA_LOG.Error("创建通知保存点失败", zap.Error(err), zap.Int("alertId", alert.AlertId))
+		return notifyError
 	}
 
-	// 判断是否需要发送通知
 	// 始终使用乐观锁原子预占通知配额，解决并发竞态问题
-	reserved, reserveErr := dedupService.TryReserveNotification(alert.AlertId)
+	reserved, reserveErr := dedupService.TryReserveNotification(tx, alert.AlertId)
 	if reserveErr != nil {
 		global.GVA_LOG.Error("预占通知配额失败", zap.Error(reserveErr), zap.Int("alertId", alert.AlertId))
+		return notifyError
 	}
-	shouldNotify := reserved
-
-	// 异步发送MQ通知(失败仅记录日志，不影响主流程)
-	if shouldNotify {
-		go func(alertId int, alertCopy observe.PrometheusAlert) {
-			mqService := MQClientService{}
-			if sendErr := mqService.SendAlertNotification(alertCopy); sendErr != nil {
-				global.GVA_LOG.Error("MQ通知发送失败", zap.Error(sendErr), zap.Int("alertId", alertId))
-				// 发送失败时回滚计数
-				if rollbackErr := dedupService.RollbackNotification(alertId); rollbackErr != nil {
-					global.GVA_LOG.Error("回滚通知计数失败", zap.Error(rollbackErr), zap.Int("alertId", alertId))
-				}
-			} else {
-				// 发送成功，确认通知已发送(清除NotifyPending)
-				if confirmErr := dedupService.ConfirmNotifySent(alertId); confirmErr != nil {
-					global.GVA_LOG.Error("确认通知发送状态失败", zap.Error(confirmErr), zap.Int("alertId", alertId))
-				} else {
-					global.GVA_LOG.Info("MQ通知发送成功", zap.Int("alertId", alertId))
-				}
-			}
-		}(alert.AlertId, alert)
-	} else {
-		global.GVA_LOG.Info("跳过MQ通知(已达每日限制)",
+	if !reserved {
+		alertsRateLimited.Inc()
+		global.GVA_LOG.Info("跳过通知(已达每日限制)",
 			zap.Int("alertId", alert.AlertId),
-			zap.String("fingerprint", fingerprint),
+			zap.String("fingerprint", alert.Fingerprint),
 			zap.Int("alertCount", alert.AlertCount),
 			zap.Int("dailyNotifyCount", alert.DailyNotifyCount),
 		)
+		return notifyRateLimited
 	}
 
-	return nil, alert
+	// 按路由树选择通知渠道与接收人, 写入发件箱后由后台工作协程发送
+	tasks := notifyTasks(routeAlert(alert.Labels))
+	var enqueueErr error
+	if len(tasks) == 0 {
+		enqueueErr = errors.New("没有可用的通知渠道")
+	} else {
+		enqueueErr = enqueueNotifications(tx, alert.AlertId, tasks)
+	}
+	if enqueueErr != nil {
+		global.GVA_LOG.Error("通知入箱失败", zap.Error(enqueueErr), zap.Int("alertId", alert.AlertId))
+		// 入箱失败时回滚配额预占
+		if rollbackErr := tx.RollbackTo(savePoint).Error; rollbackErr != nil {
+			global.GVA_LOG.Error("回滚通知计数失败", zap.Error(rollbackErr), zap.Int("alertId", alert.AlertId))
+		}
+		return notifyError
+	}
+	return notifyQueued
 }
 
 // DeleteAlert 删除告警（软删除）
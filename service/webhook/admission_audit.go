@@ -0,0 +1,145 @@
+package webhook
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"main.go/global"
+	"main.go/model/common"
+	modelObserve "main.go/model/observe"
+	modelWebhook "main.go/model/webhook"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	defaultAuditBufferSize    = 1000
+	defaultAuditBatchSize     = 100
+	defaultAuditFlushInterval = 5 * time.Second
+)
+
+var (
+	auditQueue     chan modelObserve.AdmissionAudit
+	auditQueueOnce sync.Once
+)
+
+// RecordAdmission queues the audit records of one admission. It never blocks the admission: the
+// records are written in batches in the background and dropped when the buffer is full.
+func (s *RecommendationService) RecordAdmission(uid string, pod *corev1.Pod, namespace string, result *modelWebhook.MutationResult) {
+	cfg := global.GVA_CONFIG.Webhook.Audit
+	if !cfg.Enabled || global.GVA_DB == nil {
+		return
+	}
+	if reason := podSkipReason(result); reason != "" && containsString(cfg.ExcludeReasons, reason) {
+		return
+	}
+
+	auditQueueOnce.Do(startAuditWriter)
+	for _, record := range admissionAuditRecords(uid, pod, namespace, result, time.Now()) {
+		select {
+		case auditQueue <- record:
+		default:
+			auditRecordsDropped.Inc()
+		}
+	}
+}
+
+// admissionAuditRecords turns a mutation result into one record per evaluated container, or a
+// single pod-level record when the pod was skipped before any container was looked at.
+func admissionAuditRecords(uid string, pod *corev1.Pod, namespace string, result *modelWebhook.MutationResult, now time.Time) []modelObserve.AdmissionAudit {
+	base := modelObserve.AdmissionAudit{
+		AdmissionUid:                  uid,
+		Cluster:                       result.Cluster,
+		Namespace:                     namespace,
+		WorkloadKind:                  result.Workload.Kind,
+		WorkloadName:                  result.Workload.Name,
+		PodGenerateName:               pod.GenerateName,
+		MutationMode:                  result.Mode,
+		RecommendationName:            result.RecommendationName,
+		RecommendationResourceVersion: result.RecommendationResourceVersion,
+		CreateTime:                    common.JSONTime{Time: now},
+	}
+	if base.PodGenerateName == "" {
+		base.PodGenerateName = pod.Name
+	}
+
+	if len(result.Containers) == 0 {
+		base.Decision = "skipped"
+		base.SkipReason = result.SkipReason
+		return []modelObserve.AdmissionAudit{base}
+	}
+
+	records := make([]modelObserve.AdmissionAudit, 0, len(result.Containers))
+	for _, c := range result.Containers {
+		record := base
+		record.Container = c.Name
+		record.ContainerKind = c.Kind
+		record.OldCpu, record.NewCpu = c.OldCPU, c.NewCPU
+		record.OldMemory, record.NewMemory = c.OldMemory, c.NewMemory
+		switch {
+		case c.Reason != DecisionReasonPatched:
+			record.Decision = "skipped"
+			record.SkipReason = c.Reason
+		case podSkipReason(result) != "":
+			// The container would have been patched but the pod was not, e.g. in dry-run mode.
+			record.Decision = "skipped"
+			record.SkipReason = podSkipReason(result)
+		default:
+			record.Decision = "patched"
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// podSkipReason is why the pod was not patched. A server-side dry-run admission is reported as
+// dry_run even when a patch was returned, since the pod is never created.
+func podSkipReason(result *modelWebhook.MutationResult) string {
+	if result.SkipReason == "" && result.DryRun {
+		return SkipReasonDryRun
+	}
+	return result.SkipReason
+}
+
+func startAuditWriter() {
+	cfg := global.GVA_CONFIG.Webhook.Audit
+	bufferSize, batchSize, flushInterval := cfg.BufferSize, cfg.BatchSize, cfg.FlushInterval
+	if bufferSize <= 0 {
+		bufferSize = defaultAuditBufferSize
+	}
+	if batchSize <= 0 {
+		batchSize = defaultAuditBatchSize
+	}
+	if flushInterval <= 0 {
+		flushInterval = defaultAuditFlushInterval
+	}
+	auditQueue = make(chan modelObserve.AdmissionAudit, bufferSize)
+
+	go func() {
+		ticker := time.NewTicker(flushInterval)
+		defer ticker.Stop()
+		batch := make([]modelObserve.AdmissionAudit, 0, batchSize)
+		flush := func() {
+			if len(batch) == 0 {
+				return
+			}
+			if err := global.GVA_DB.CreateInBatches(batch, batchSize).Error; err != nil {
+				log.Printf("Failed to write %d admission audit records: %v", len(batch), err)
+				auditRecordsDropped.Add(float64(len(batch)))
+			}
+			batch = make([]modelObserve.AdmissionAudit, 0, batchSize)
+		}
+		for {
+			select {
+			case record := <-auditQueue:
+				batch = append(batch, record)
+				if len(batch) >= batchSize {
+					flush()
+				}
+			case <-ticker.C:
+				flush()
+			}
+		}
+	}()
+}
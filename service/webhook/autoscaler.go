@@ -0,0 +1,484 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"main.go/global"
+	modelWebhook "main.go/model/webhook"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Autoscaler policies, set in webhook.autoscalers.
+const (
+	// HPAPolicySkipResource leaves the resources an HPA scales on by utilization unchanged.
+	HPAPolicySkipResource = "skip-resource"
+	// HPAPolicySkip admits the pods of a workload with such an HPA unchanged.
+	HPAPolicySkip = "skip"
+	// HPAPolicyScaleTarget patches the pod and scales the HPA's target utilization by old/new request,
+	// so the absolute usage at which it scales stays the same.
+	HPAPolicyScaleTarget = "scale-target"
+	// VPAPolicySkip admits the pods of a workload with an active VPA unchanged.
+	VPAPolicySkip = "skip"
+	// AutoscalerPolicyIgnore patches as if there was no autoscaler.
+	AutoscalerPolicyIgnore = "ignore"
+)
+
+// HPABaselineAnnotation records, per utilization metric, the target and pod request an HPA had before
+// its target was first scaled, so later scaling is computed from the original threshold.
+const HPABaselineAnnotation = "finops.io/hpa-baseline"
+
+const scaleTargetIndex = "scaleTargetIndex"
+
+// metricSourceFields maps the HPA metric types that can target utilization to their source field.
+var metricSourceFields = map[string]string{"Resource": "resource", "ContainerResource": "containerResource"}
+
+var (
+	hpaGVR = schema.GroupVersionResource{Group: "autoscaling", Version: "v2", Resource: "horizontalpodautoscalers"}
+	vpaGVR = schema.GroupVersionResource{Group: "autoscaling.k8s.io", Version: "v1", Resource: "verticalpodautoscalers"}
+)
+
+const (
+	// hpaFollowInterval is how often the target of an HPA is recomputed while its pods roll over.
+	hpaFollowInterval = 30 * time.Second
+	// hpaFollowTimeout stops following a rollout that has not converged since the last admission.
+	hpaFollowTimeout = 30 * time.Minute
+)
+
+// scaleTargetIndexFunc indexes HPAs (spec.scaleTargetRef) and VPAs (spec.targetRef) by namespace/kind/name
+// of the workload they target.
+func scaleTargetIndexFunc(refField string) cache.IndexFunc {
+	return func(obj interface{}) ([]string, error) {
+		u, ok := obj.(*unstructured.Unstructured)
+		if !ok {
+			return nil, nil
+		}
+		kind, _, _ := unstructured.NestedString(u.Object, "spec", refField, "kind")
+		name, _, _ := unstructured.NestedString(u.Object, "spec", refField, "name")
+		if kind == "" || name == "" {
+			return nil, nil
+		}
+		return []string{scaleTargetKey(u.GetNamespace(), kind, name)}, nil
+	}
+}
+
+func scaleTargetKey(namespace, kind, name string) string {
+	return fmt.Sprintf("%s/%s/%s", namespace, kind, name)
+}
+
+// autoscalerCheck is what MutatePod does about the autoscalers targeting a workload.
+type autoscalerCheck struct {
+	Skip          bool
+	SkipResources map[corev1.ResourceName]bool
+	Warnings      []string
+	// ScaleHPAs are the HPAs whose target utilization follows the new requests.
+	ScaleHPAs []*unstructured.Unstructured
+}
+
+// checkAutoscalers applies webhook.autoscalers to the HPAs and VPAs targeting a workload. Only HPAs
+// with Utilization targets are affected by requests; AverageValue targets are absolute already.
+func checkAutoscalers(cluster *memberCluster, namespace string, workload modelWebhook.WorkloadRef) autoscalerCheck {
+	cfg := global.GVA_CONFIG.Webhook.Autoscalers
+	key := scaleTargetKey(namespace, workload.Kind, workload.Name)
+	var check autoscalerCheck
+
+	if cfg.VPA != AutoscalerPolicyIgnore {
+		for _, vpa := range indexedObjects(cluster.VPAs, key) {
+			mode, _, _ := unstructured.NestedString(vpa.Object, "spec", "updatePolicy", "updateMode")
+			if mode == "Off" {
+				continue
+			}
+			if mode == "" {
+				mode = "Auto"
+			}
+			check.Skip = true
+			check.Warnings = append(check.Warnings, fmt.Sprintf("finops autoscaler: pod left unchanged, VerticalPodAutoscaler %s sets its resources (updateMode %s)", vpa.GetName(), mode))
+			return check
+		}
+	}
+
+	policy := cfg.HPA
+	if policy == "" {
+		policy = HPAPolicySkipResource
+	}
+	if policy == AutoscalerPolicyIgnore {
+		return check
+	}
+	for _, hpa := range indexedObjects(cluster.HPAs, key) {
+		metrics := hpaUtilizationMetrics(hpa)
+		if len(metrics) == 0 {
+			continue
+		}
+		switch policy {
+		case HPAPolicySkip:
+			check.Skip = true
+			check.Warnings = append(check.Warnings, fmt.Sprintf("finops autoscaler: pod left unchanged, HorizontalPodAutoscaler %s scales on %s utilization", hpa.GetName(), metrics[0].Resource))
+			return check
+		case HPAPolicyScaleTarget:
+			check.ScaleHPAs = append(check.ScaleHPAs, hpa)
+		default:
+			if check.SkipResources == nil {
+				check.SkipResources = map[corev1.ResourceName]bool{}
+			}
+			for _, m := range metrics {
+				if !check.SkipResources[m.Resource] {
+					check.SkipResources[m.Resource] = true
+					check.Warnings = append(check.Warnings, fmt.Sprintf("finops autoscaler: %s left unchanged, HorizontalPodAutoscaler %s scales on its utilization", m.Resource, hpa.GetName()))
+				}
+			}
+		}
+	}
+	return check
+}
+
+// withoutResources returns the targets minus the skipped resources.
+func withoutResources(targets corev1.ResourceList, skip map[corev1.ResourceName]bool) corev1.ResourceList {
+	if len(skip) == 0 {
+		return targets
+	}
+	out := corev1.ResourceList{}
+	for name, q := range targets {
+		if !skip[name] {
+			out[name] = q
+		}
+	}
+	return out
+}
+
+// hpaUtilizationMetric is a Utilization target of an HPA on a pod or container resource.
+type hpaUtilizationMetric struct {
+	Index       int
+	Type        string // Resource or ContainerResource
+	Resource    corev1.ResourceName
+	Container   string
+	Utilization int64
+}
+
+// key identifies the metric in HPABaselineAnnotation.
+func (m hpaUtilizationMetric) key() string {
+	if m.Type == "ContainerResource" {
+		return m.Type + "/" + m.Container + "/" + string(m.Resource)
+	}
+	return m.Type + "/" + string(m.Resource)
+}
+
+// podRequest sums the requests the HPA divides usage by: all regular containers, or the one container.
+func (m hpaUtilizationMetric) podRequest(pod *corev1.Pod) resource.Quantity {
+	var total resource.Quantity
+	for _, c := range pod.Spec.Containers {
+		if m.Type == "ContainerResource" && c.Name != m.Container {
+			continue
+		}
+		if q, ok := c.Resources.Requests[m.Resource]; ok {
+			total.Add(q)
+		}
+	}
+	return total
+}
+
+func hpaUtilizationMetrics(hpa *unstructured.Unstructured) []hpaUtilizationMetric {
+	metrics, _, _ := unstructured.NestedSlice(hpa.Object, "spec", "metrics")
+	var out []hpaUtilizationMetric
+	for i, raw := range metrics {
+		metric, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		metricType, _, _ := unstructured.NestedString(metric, "type")
+		field := metricSourceFields[metricType]
+		if field == "" {
+			continue
+		}
+		targetType, _, _ := unstructured.NestedString(metric, field, "target", "type")
+		utilization, found, _ := unstructured.NestedInt64(metric, field, "target", "averageUtilization")
+		if targetType != "Utilization" || !found {
+			continue
+		}
+		name, _, _ := unstructured.NestedString(metric, field, "name")
+		container, _, _ := unstructured.NestedString(metric, field, "container")
+		out = append(out, hpaUtilizationMetric{
+			Index:       i,
+			Type:        metricType,
+			Resource:    corev1.ResourceName(name),
+			Container:   container,
+			Utilization: utilization,
+		})
+	}
+	return out
+}
+
+// hpaBaseline is the target utilization and pod request of one metric before finops changed it.
+type hpaBaseline struct {
+	Utilization int64  `json:"utilization"`
+	Request     string `json:"request"`
+}
+
+// scaleHPATargets reports the target utilization that keeps the HPA's original absolute threshold once
+// every pod of the workload runs with the patched requests and, unless dryRun, starts following the
+// rollout in the background. The target is not set from the patched pod alone: the HPA averages the
+// usage of old and new pods against one target, so while both run it follows their mean request.
+// Only the leader follows rollouts, so replicas never write the same HPA concurrently; the others
+// only report the change.
+func scaleHPATargets(cluster *memberCluster, hpa *unstructured.Unstructured, before, after *corev1.Pod, dryRun bool) []string {
+	baselines := hpaBaselines(hpa)
+	pending := map[string]hpaBaseline{}
+	targets := map[string]int64{}
+	var warnings []string
+	for _, m := range hpaUtilizationMetrics(hpa) {
+		base, ok := baselines[m.key()]
+		if !ok {
+			oldRequest := m.podRequest(before)
+			base = hpaBaseline{Utilization: m.Utilization, Request: oldRequest.String()}
+			pending[m.key()] = base
+		}
+		newRequest := m.podRequest(after)
+		targets[m.key()] = newRequest.MilliValue()
+		desired, ok := base.scaledUtilization(float64(newRequest.MilliValue()))
+		if !ok || desired == m.Utilization {
+			continue
+		}
+		verb := "will move"
+		if dryRun {
+			verb = "would move"
+		}
+		warnings = append(warnings, fmt.Sprintf("finops autoscaler: %s HorizontalPodAutoscaler %s %s target utilization %d%% -> %d%% as pods adopt request %s (baseline %d%% of %s)",
+			verb, hpa.GetName(), m.Resource, m.Utilization, desired, newRequest.String(), base.Utilization, base.Request))
+	}
+	if dryRun || cluster.Client == nil || !isLeader() || (len(warnings) == 0 && len(pending) == 0) {
+		return warnings
+	}
+	followHPARollout(cluster, hpa.GetNamespace(), hpa.GetName(), pending, targets)
+	return warnings
+}
+
+// hpaBaselines reads HPABaselineAnnotation; an invalid annotation is replaced by a new baseline.
+func hpaBaselines(hpa *unstructured.Unstructured) map[string]hpaBaseline {
+	baselines := map[string]hpaBaseline{}
+	if v := hpa.GetAnnotations()[HPABaselineAnnotation]; v != "" {
+		if err := json.Unmarshal([]byte(v), &baselines); err != nil {
+			log.Printf("Invalid %s annotation on HorizontalPodAutoscaler %s/%s, recording a new baseline: %v", HPABaselineAnnotation, hpa.GetNamespace(), hpa.GetName(), err)
+			return map[string]hpaBaseline{}
+		}
+	}
+	return baselines
+}
+
+// scaledUtilization is the target utilization at which pods with the given mean request (in milli
+// units) scale at the same absolute usage as the baseline.
+func (b hpaBaseline) scaledUtilization(meanRequest float64) (int64, bool) {
+	baseRequest, err := resource.ParseQuantity(b.Request)
+	if err != nil || baseRequest.IsZero() || meanRequest <= 0 {
+		return 0, false
+	}
+	desired := int64(math.Round(float64(b.Utilization) * float64(baseRequest.MilliValue()) / meanRequest))
+	if desired < 1 {
+		desired = 1
+	}
+	return desired, true
+}
+
+// hpaFollower is the state of an HPA whose target follows a rollout: the pod request, in milli units
+// by metric key, the rollout converges on, and when to give up.
+type hpaFollower struct {
+	targets  map[string]int64
+	deadline time.Time
+}
+
+// hpaFollowers holds the followed HPAs by cluster/namespace/name.
+var hpaFollowers = struct {
+	sync.Mutex
+	followers map[string]*hpaFollower
+}{followers: map[string]*hpaFollower{}}
+
+// followHPARollout rescales the HPA from the live pods of its target every hpaFollowInterval until
+// they all have the target request or hpaFollowTimeout passes without a new admission. A rollout
+// that starts while one is followed replaces the targets and extends the deadline. Baselines missing
+// from the annotation are written with the first rescale.
+func followHPARollout(cluster *memberCluster, namespace, name string, pending map[string]hpaBaseline, targets map[string]int64) {
+	key := cluster.ID + "/" + namespace + "/" + name
+	hpaFollowers.Lock()
+	_, running := hpaFollowers.followers[key]
+	hpaFollowers.followers[key] = &hpaFollower{targets: targets, deadline: time.Now().Add(hpaFollowTimeout)}
+	hpaFollowers.Unlock()
+	if running {
+		return
+	}
+
+	go func() {
+		defer func() {
+			hpaFollowers.Lock()
+			delete(hpaFollowers.followers, key)
+			hpaFollowers.Unlock()
+		}()
+		for {
+			time.Sleep(hpaFollowInterval)
+			if !isLeader() {
+				log.Printf("Lost leadership, no longer following the rollout of HorizontalPodAutoscaler %s/%s", namespace, name)
+				return
+			}
+			hpaFollowers.Lock()
+			follower := *hpaFollowers.followers[key]
+			hpaFollowers.Unlock()
+
+			done, err := rescaleHPA(cluster, namespace, name, pending, follower.targets)
+			if err != nil {
+				log.Printf("Failed to scale the target of HorizontalPodAutoscaler %s/%s: %v", namespace, name, err)
+			} else {
+				pending = nil
+			}
+			if done || time.Now().After(follower.deadline) {
+				return
+			}
+		}
+	}()
+}
+
+// rescaleHPA sets the target utilization of every baselined metric from the mean request of the
+// running pods of the HPA's target. It reports done once all those pods have the target request.
+func rescaleHPA(cluster *memberCluster, namespace, name string, pending map[string]hpaBaseline, targets map[string]int64) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), controllerRequestTimeout)
+	defer cancel()
+	hpa, err := cluster.Client.Resource(hpaGVR).Namespace(namespace).Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	pods, err := scaleTargetPods(ctx, cluster, hpa)
+	if err != nil {
+		return false, err
+	}
+
+	baselines := hpaBaselines(hpa)
+	changed := false
+	for k, base := range pending {
+		if _, ok := baselines[k]; !ok {
+			baselines[k] = base
+			changed = true
+		}
+	}
+	metrics, _, _ := unstructured.NestedSlice(hpa.Object, "spec", "metrics")
+	done := true
+	for _, m := range hpaUtilizationMetrics(hpa) {
+		base, ok := baselines[m.key()]
+		if !ok || len(pods) == 0 {
+			continue
+		}
+		var total int64
+		for _, pod := range pods {
+			request := m.podRequest(pod)
+			if target, ok := targets[m.key()]; ok && request.MilliValue() != target {
+				done = false
+			}
+			total += request.MilliValue()
+		}
+		desired, ok := base.scaledUtilization(float64(total) / float64(len(pods)))
+		if !ok || desired == m.Utilization {
+			continue
+		}
+		metric := metrics[m.Index].(map[string]interface{})
+		if err := unstructured.SetNestedField(metric, desired, metricSourceFields[m.Type], "target", "averageUtilization"); err != nil {
+			continue
+		}
+		changed = true
+	}
+	if !changed {
+		return done, nil
+	}
+
+	baselineBytes, err := json.Marshal(baselines)
+	if err != nil {
+		return false, err
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"resourceVersion": hpa.GetResourceVersion(),
+			"annotations":     map[string]string{HPABaselineAnnotation: string(baselineBytes)},
+		},
+		"spec": map[string]interface{}{"metrics": metrics},
+	})
+	if err != nil {
+		return false, err
+	}
+	// The resourceVersion makes a concurrent change win; the next pass recomputes from it.
+	if _, err := cluster.Client.Resource(hpaGVR).Namespace(namespace).Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		return false, err
+	}
+	log.Printf("Scaled the target of HorizontalPodAutoscaler %s/%s: %s", namespace, name, patch)
+	return done, nil
+}
+
+// scaleTargetPods lists the pods the HPA averages over: the running and pending pods selected by its
+// target workload.
+func scaleTargetPods(ctx context.Context, cluster *memberCluster, hpa *unstructured.Unstructured) ([]*corev1.Pod, error) {
+	ref := modelWebhook.WorkloadRef{}
+	ref.APIVersion, _, _ = unstructured.NestedString(hpa.Object, "spec", "scaleTargetRef", "apiVersion")
+	ref.Kind, _, _ = unstructured.NestedString(hpa.Object, "spec", "scaleTargetRef", "kind")
+	ref.Name, _, _ = unstructured.NestedString(hpa.Object, "spec", "scaleTargetRef", "name")
+	workload := cluster.resolver().Workload(hpa.GetNamespace(), ref)
+	if workload == nil {
+		return nil, fmt.Errorf("target %s %s not found", ref.Kind, ref.Name)
+	}
+	raw, found, err := unstructured.NestedMap(workload.Object, "spec", "selector")
+	if err != nil || !found {
+		return nil, fmt.Errorf("target %s %s has no selector", ref.Kind, ref.Name)
+	}
+	var labelSelector metav1.LabelSelector
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(raw, &labelSelector); err != nil {
+		return nil, fmt.Errorf("convert selector of %s %s: %w", ref.Kind, ref.Name, err)
+	}
+	selector, err := metav1.LabelSelectorAsSelector(&labelSelector)
+	if err != nil {
+		return nil, fmt.Errorf("selector of %s %s: %w", ref.Kind, ref.Name, err)
+	}
+
+	list, err := cluster.Client.Resource(podGVR).Namespace(hpa.GetNamespace()).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, fmt.Errorf("list pods: %w", err)
+	}
+	pods := make([]*corev1.Pod, 0, len(list.Items))
+	for i := range list.Items {
+		pod := &corev1.Pod{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(list.Items[i].Object, pod); err != nil {
+			continue
+		}
+		if pod.DeletionTimestamp != nil || (pod.Status.Phase != corev1.PodRunning && pod.Status.Phase != corev1.PodPending) {
+			continue
+		}
+		pods = append(pods, pod)
+	}
+	return pods, nil
+}
+
+// indexedObjects returns the objects of an indexer under a scaleTargetIndex key; nil indexers hold nothing.
+func indexedObjects(indexer cache.Indexer, key string) []*unstructured.Unstructured {
+	if indexer == nil {
+		return nil
+	}
+	items, err := indexer.ByIndex(scaleTargetIndex, key)
+	if err != nil {
+		return nil
+	}
+	out := make([]*unstructured.Unstructured, 0, len(items))
+	for _, item := range items {
+		if u, ok := item.(*unstructured.Unstructured); ok {
+			out = append(out, u)
+		}
+	}
+	return out
+}
@@ -0,0 +1,64 @@
+package webhook
+
+import (
+	"strings"
+	"testing"
+
+	"main.go/config"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestScaleHPATargetsFollowsOnlyOnLeader(t *testing.T) {
+	hpa := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "autoscaling/v2", "kind": "HorizontalPodAutoscaler",
+		"metadata": map[string]interface{}{"name": "web", "namespace": resizeNamespace},
+		"spec": map[string]interface{}{
+			"scaleTargetRef": map[string]interface{}{"apiVersion": "apps/v1", "kind": "StatefulSet", "name": "web"},
+			"metrics": []interface{}{map[string]interface{}{
+				"type": "Resource",
+				"resource": map[string]interface{}{
+					"name":   "cpu",
+					"target": map[string]interface{}{"type": "Utilization", "averageUtilization": int64(60)},
+				},
+			}},
+		},
+	}}
+	before := runningPod("web-0", corev1.ResourceRequirements{Requests: resources("cpu", "1")})
+	after := runningPod("web-0", corev1.ResourceRequirements{Requests: resources("cpu", "500m")})
+
+	// Each case uses its own HPA, since a started follower outlives the test.
+	for _, tc := range []struct {
+		name    string
+		hpa     string
+		leading bool
+	}{
+		{"leader", "web-leader", true},
+		{"follower replica", "web-follower", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			withWebhookConfig(t, func(cfg *config.Webhook) {
+				cfg.LeaderElection.Enabled = true
+			})
+			saved := leading.Load()
+			leading.Store(tc.leading)
+			t.Cleanup(func() { leading.Store(saved) })
+			cluster, _ := resizeCluster(t)
+			hpa := hpa.DeepCopy()
+			hpa.SetName(tc.hpa)
+			key := cluster.ID + "/" + resizeNamespace + "/" + tc.hpa
+
+			warnings := scaleHPATargets(cluster, hpa, before, after, false)
+			if len(warnings) != 1 || !strings.Contains(warnings[0], "60% -> 120%") {
+				t.Errorf("warnings = %v, want the target moving from 60%% to 120%%", warnings)
+			}
+			hpaFollowers.Lock()
+			_, following := hpaFollowers.followers[key]
+			hpaFollowers.Unlock()
+			if following != tc.leading {
+				t.Errorf("following the rollout = %v, want %v", following, tc.leading)
+			}
+		})
+	}
+}
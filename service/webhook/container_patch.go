@@ -0,0 +1,207 @@
+package webhook
+
+import (
+	"sort"
+	"strings"
+
+	modelWebhook "main.go/model/webhook"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// containerPlan collects the resource changes decided for one container before they are turned into JSON patches.
+type containerPlan struct {
+	// Path is the JSON pointer of the container, e.g. /spec/containers/0
+	Path      string
+	Container *corev1.Container
+
+	Requests     corev1.ResourceList
+	Limits       corev1.ResourceList
+	RemoveLimits []corev1.ResourceName
+	// Reverted is the reason of the last revert, reported when it left the plan empty.
+	Reverted string
+}
+
+// applyTo writes the planned values into a container, mirroring what the JSON patches do.
+func (p *containerPlan) applyTo(c *corev1.Container) {
+	if len(p.Requests) > 0 && c.Resources.Requests == nil {
+		c.Resources.Requests = corev1.ResourceList{}
+	}
+	for name, q := range p.Requests {
+		c.Resources.Requests[name] = q.DeepCopy()
+	}
+	if len(p.Limits) > 0 && c.Resources.Limits == nil {
+		c.Resources.Limits = corev1.ResourceList{}
+	}
+	for name, q := range p.Limits {
+		c.Resources.Limits[name] = q.DeepCopy()
+	}
+	for _, name := range p.RemoveLimits {
+		delete(c.Resources.Limits, name)
+	}
+}
+
+// applyPlans returns a copy of the pod with all plans applied.
+func applyPlans(pod *corev1.Pod, plans []*containerPlan) *corev1.Pod {
+	patched := pod.DeepCopy()
+	byPath := make(map[string]*corev1.Container)
+	for _, ref := range mutableContainers(patched) {
+		byPath[ref.Path] = ref.Container
+	}
+	for _, plan := range plans {
+		if c, ok := byPath[plan.Path]; ok {
+			plan.applyTo(c)
+		}
+	}
+	return patched
+}
+
+// nonEmptyPlans drops the plans left without changes, e.g. after a revert.
+func nonEmptyPlans(plans []*containerPlan) []*containerPlan {
+	out := make([]*containerPlan, 0, len(plans))
+	for _, plan := range plans {
+		if !plan.empty() {
+			out = append(out, plan)
+		}
+	}
+	return out
+}
+
+func newContainerPlan(path string, container *corev1.Container) *containerPlan {
+	return &containerPlan{
+		Path:      path,
+		Container: container,
+		Requests:  corev1.ResourceList{},
+		Limits:    corev1.ResourceList{},
+	}
+}
+
+func (p *containerPlan) empty() bool {
+	return len(p.Requests) == 0 && len(p.Limits) == 0 && len(p.RemoveLimits) == 0
+}
+
+// apply records a limit decision for one resource.
+func (p *containerPlan) apply(name corev1.ResourceName, d limitDecision) {
+	p.Requests[name] = d.Request
+	if d.Limit != nil {
+		p.Limits[name] = *d.Limit
+	}
+	if d.RemoveLimit {
+		p.RemoveLimits = append(p.RemoveLimits, name)
+	}
+}
+
+// revert drops every planned change of one resource, leaving the container's values as they are.
+func (p *containerPlan) revert(name corev1.ResourceName, reason string) {
+	_, requested := p.Requests[name]
+	_, limited := p.Limits[name]
+	delete(p.Requests, name)
+	delete(p.Limits, name)
+	removes := p.RemoveLimits[:0]
+	removed := false
+	for _, n := range p.RemoveLimits {
+		if n == name {
+			removed = true
+			continue
+		}
+		removes = append(removes, n)
+	}
+	p.RemoveLimits = removes
+	if requested || limited || removed {
+		p.Reverted = reason
+	}
+}
+
+// removesLimit reports whether the plan drops the limit of a resource.
+func (p *containerPlan) removesLimit(name corev1.ResourceName) bool {
+	for _, n := range p.RemoveLimits {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// patches renders the plan as JSON patch operations against the container's resources.
+func (p *containerPlan) patches() []modelWebhook.JSONPatch {
+	var patches []modelWebhook.JSONPatch
+	patches = append(patches, resourceListPatches(p.Path+"/resources/requests", p.Container.Resources.Requests, p.Requests)...)
+
+	// Limits are written before they are removed so a remove never targets a key we just added.
+	patches = append(patches, resourceListPatches(p.Path+"/resources/limits", p.Container.Resources.Limits, p.Limits)...)
+	for _, name := range p.RemoveLimits {
+		if _, ok := p.Container.Resources.Limits[name]; !ok {
+			continue
+		}
+		patches = append(patches, modelWebhook.JSONPatch{
+			Op:   "remove",
+			Path: p.Path + "/resources/limits/" + escapeJSONPointer(string(name)),
+		})
+	}
+	return patches
+}
+
+// resourceListPatches emits either one add for a missing list or one add/replace per key, in a stable order.
+func resourceListPatches(path string, current corev1.ResourceList, desired corev1.ResourceList) []modelWebhook.JSONPatch {
+	if len(desired) == 0 {
+		return nil
+	}
+	names := sortedResourceNames(desired)
+
+	if current == nil {
+		value := make(map[string]string, len(desired))
+		for _, name := range names {
+			q := desired[name]
+			value[string(name)] = q.String()
+		}
+		return []modelWebhook.JSONPatch{{Op: "add", Path: path, Value: value}}
+	}
+
+	patches := make([]modelWebhook.JSONPatch, 0, len(names))
+	for _, name := range names {
+		op := "replace"
+		if _, ok := current[name]; !ok {
+			op = "add"
+		}
+		q := desired[name]
+		patches = append(patches, modelWebhook.JSONPatch{
+			Op:    op,
+			Path:  path + "/" + escapeJSONPointer(string(name)),
+			Value: q.String(),
+		})
+	}
+	return patches
+}
+
+// sortedResourceNames orders cpu and memory first, then everything else alphabetically.
+func sortedResourceNames(m corev1.ResourceList) []corev1.ResourceName {
+	names := make([]corev1.ResourceName, 0, len(m))
+	for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+		if _, ok := m[name]; ok {
+			names = append(names, name)
+		}
+	}
+	var rest []corev1.ResourceName
+	for name := range m {
+		if name != corev1.ResourceCPU && name != corev1.ResourceMemory {
+			rest = append(rest, name)
+		}
+	}
+	sort.Slice(rest, func(i, j int) bool { return rest[i] < rest[j] })
+	return append(names, rest...)
+}
+
+// formatResources renders a resource map as "cpu=100m memory=128Mi" for logs and warnings.
+func formatResources(m corev1.ResourceList) string {
+	parts := make([]string, 0, len(m))
+	for _, name := range sortedResourceNames(m) {
+		q := m[name]
+		parts = append(parts, string(name)+"="+q.String())
+	}
+	return strings.Join(parts, " ")
+}
+
+// escapeJSONPointer escapes a JSON pointer reference token per RFC 6901.
+func escapeJSONPointer(s string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(s)
+}
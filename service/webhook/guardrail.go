@@ -0,0 +1,103 @@
+package webhook
+
+import (
+	"fmt"
+	"log"
+
+	"main.go/config"
+	"main.go/global"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// guardrailFor merges, from weakest to strongest, the global guardrail, the cluster override, the global
+// namespace override and the cluster's namespace override. Fields left unset on an override keep the
+// value below it; min/max are merged per resource.
+func guardrailFor(cluster config.WebhookCluster, namespace string) config.Guardrail {
+	cfg := global.GVA_CONFIG.Webhook
+	g := mergeGuardrail(cfg.Guardrail, cluster.Guardrail)
+	if override, ok := cfg.NamespaceGuardrails[namespace]; ok {
+		g = mergeGuardrail(g, override)
+	}
+	if override, ok := cluster.NamespaceGuardrails[namespace]; ok {
+		g = mergeGuardrail(g, override)
+	}
+	return g
+}
+
+func mergeGuardrail(g, override config.Guardrail) config.Guardrail {
+	g.Min = mergeQuantityMap(g.Min, override.Min)
+	g.Max = mergeQuantityMap(g.Max, override.Max)
+	if override.MaxDecreaseRatio != 0 {
+		g.MaxDecreaseRatio = override.MaxDecreaseRatio
+	}
+	if override.MaxIncreaseRatio != 0 {
+		g.MaxIncreaseRatio = override.MaxIncreaseRatio
+	}
+	return g
+}
+
+func mergeQuantityMap(base, override map[string]string) map[string]string {
+	if len(override) == 0 {
+		return base
+	}
+	out := make(map[string]string, len(base)+len(override))
+	for k, v := range base {
+		out[k] = v
+	}
+	for k, v := range override {
+		out[k] = v
+	}
+	return out
+}
+
+// clampRecommendation bounds a recommended request. The relative change versus the current request is
+// limited first, then the absolute floor and ceiling are enforced so they always win. Every adjustment is
+// returned as a human-readable note for logs and admission warnings.
+func clampRecommendation(g config.Guardrail, name corev1.ResourceName, target resource.Quantity, current *resource.Quantity) (resource.Quantity, []string) {
+	var notes []string
+	clamped := target.DeepCopy()
+
+	if current != nil && !current.IsZero() {
+		if g.MaxDecreaseRatio > 0 && g.MaxDecreaseRatio < 1 {
+			floor := scaleQuantity(*current, 1-g.MaxDecreaseRatio)
+			if clamped.Cmp(floor) < 0 {
+				notes = append(notes, fmt.Sprintf("%s %s raised to %s (max decrease %.0f%% from %s)",
+					name, clamped.String(), floor.String(), g.MaxDecreaseRatio*100, current.String()))
+				clamped = floor
+			}
+		}
+		if g.MaxIncreaseRatio > 0 {
+			ceiling := scaleQuantity(*current, 1+g.MaxIncreaseRatio)
+			if clamped.Cmp(ceiling) > 0 {
+				notes = append(notes, fmt.Sprintf("%s %s lowered to %s (max increase %.0f%% from %s)",
+					name, clamped.String(), ceiling.String(), g.MaxIncreaseRatio*100, current.String()))
+				clamped = ceiling
+			}
+		}
+	}
+
+	if ceiling, ok := parseGuardrailQuantity(g.Max, name); ok && clamped.Cmp(ceiling) > 0 {
+		notes = append(notes, fmt.Sprintf("%s %s lowered to ceiling %s", name, clamped.String(), ceiling.String()))
+		clamped = ceiling
+	}
+	if floor, ok := parseGuardrailQuantity(g.Min, name); ok && clamped.Cmp(floor) < 0 {
+		notes = append(notes, fmt.Sprintf("%s %s raised to floor %s", name, clamped.String(), floor.String()))
+		clamped = floor
+	}
+	return clamped, notes
+}
+
+func parseGuardrailQuantity(m map[string]string, name corev1.ResourceName) (resource.Quantity, bool) {
+	v, ok := m[string(name)]
+	if !ok || v == "" {
+		return resource.Quantity{}, false
+	}
+	q, err := resource.ParseQuantity(v)
+	if err != nil {
+		log.Printf("Invalid guardrail quantity %s=%q: %v", name, v, err)
+		return resource.Quantity{}, false
+	}
+	return q, true
+}
@@ -0,0 +1,316 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"main.go/config"
+	"main.go/global"
+	modelWebhook "main.go/model/webhook"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	defaultResizeInterval            = time.Minute
+	defaultMaxConcurrentResizesPerNs = 1
+)
+
+// In-place resize outcomes, used as metric labels.
+const (
+	ResizeResultResized = "resized"
+	ResizeResultDryRun  = "dry_run"
+	ResizeResultError   = "error"
+)
+
+var podGVR = schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+
+// podResizer brings running pods to their cached recommendation through the pods/resize subresource,
+// so long-lived pods adopt it without being recreated. It runs on the leader only and shares the
+// selection, guardrail, limit policy and mutation mode of the webhook.
+type podResizer struct {
+	mu sync.Mutex
+	// dryRunReported remembers the recommendation version a dry-run resize was logged for, by pod UID.
+	dryRunReported map[types.UID]string
+}
+
+var resizerStart sync.Once
+
+func newPodResizer() *podResizer {
+	return &podResizer{dryRunReported: map[types.UID]string{}}
+}
+
+// startPodResizer starts the resize loop once, after the first successful InitK8s. Replicas that
+// are not the leader keep the loop running but do nothing.
+func startPodResizer() {
+	cfg := global.GVA_CONFIG.Webhook.InPlaceResize
+	if !cfg.Enabled {
+		return
+	}
+	resizerStart.Do(func() {
+		interval := cfg.Interval
+		if interval <= 0 {
+			interval = defaultResizeInterval
+		}
+		r := newPodResizer()
+		log.Printf("[Resize] Resizing running pods in place every %s", interval)
+		go func() {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for range ticker.C {
+				if !isLeader() {
+					continue
+				}
+				r.reconcile(time.Now())
+			}
+		}()
+	})
+}
+
+// reconcile resizes the pods of every workload with an eligible recommendation, namespace by namespace.
+func (r *podResizer) reconcile(now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// cluster -> namespace -> workload key -> recommendation
+	targets := map[string]map[string]map[string]*cachedRecommendation{}
+	for _, group := range recommendationCache.workloadGroups() {
+		rec, _ := selectRecommendation(group, now)
+		if rec == nil || rec.ParseErr != nil || len(rec.Containers) == 0 {
+			continue
+		}
+		if targets[rec.Cluster] == nil {
+			targets[rec.Cluster] = map[string]map[string]*cachedRecommendation{}
+		}
+		if targets[rec.Cluster][rec.TargetNamespace] == nil {
+			targets[rec.Cluster][rec.TargetNamespace] = map[string]*cachedRecommendation{}
+		}
+		targets[rec.Cluster][rec.TargetNamespace][rec.WorkloadKey] = rec
+	}
+
+	for _, clusterID := range sortedKeys(targets) {
+		clusterCfg, served := clusterConfig(clusterID)
+		if !served || !clusterEnabled(clusterCfg) {
+			continue
+		}
+		cluster := memberClusters.get(clusterID)
+		if cluster == nil || !cluster.ready || cluster.Client == nil {
+			continue
+		}
+		for _, namespace := range sortedKeys(targets[clusterID]) {
+			if err := r.reconcileNamespace(cluster, clusterCfg, namespace, targets[clusterID][namespace]); err != nil {
+				log.Printf("[Resize] Failed to resize pods of cluster %s namespace %s: %v", clusterID, namespace, err)
+			}
+		}
+	}
+}
+
+// reconcileNamespace resizes the pods of one namespace whose workload has a recommendation, keeping
+// at most webhook.in-place-resize.max-concurrent-per-namespace resizes in flight. Resizes started by
+// anyone else count against the budget too.
+func (r *podResizer) reconcileNamespace(cluster *memberCluster, clusterCfg config.WebhookCluster, namespace string, recs map[string]*cachedRecommendation) error {
+	budget := global.GVA_CONFIG.Webhook.InPlaceResize.MaxConcurrentPerNamespace
+	if budget <= 0 {
+		budget = defaultMaxConcurrentResizesPerNs
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), controllerRequestTimeout)
+	defer cancel()
+	list, err := cluster.Client.Resource(podGVR).Namespace(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("list pods: %w", err)
+	}
+	pods := make([]*corev1.Pod, 0, len(list.Items))
+	inFlight := 0
+	for i := range list.Items {
+		pod := &corev1.Pod{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(list.Items[i].Object, pod); err != nil {
+			log.Printf("[Resize] Failed to convert pod %s/%s: %v", namespace, list.Items[i].GetName(), err)
+			continue
+		}
+		if resizeInProgress(pod) {
+			inFlight++
+		}
+		pods = append(pods, pod)
+	}
+	sort.Slice(pods, func(i, j int) bool { return pods[i].Name < pods[j].Name })
+
+	for _, pod := range pods {
+		if pod.Status.Phase != corev1.PodRunning || pod.DeletionTimestamp != nil || resizeInProgress(pod) {
+			continue
+		}
+		workload := cluster.resolver().Resolve(namespace, pod.OwnerReferences)
+		if workload.Name == "" {
+			continue
+		}
+		rec := recs[targetWorkloadIndexKey(cluster.ID, namespace, workload.Kind, workload.Name)]
+		if rec == nil {
+			continue
+		}
+		if inFlight >= budget {
+			log.Printf("[Resize] %d resize(s) in flight in %s, deferring the remaining pods", inFlight, namespace)
+			return nil
+		}
+
+		patches, mode := resizePatches(cluster, clusterCfg, pod, rec)
+		if len(patches) == 0 {
+			continue
+		}
+		patchBytes, err := json.Marshal(patches)
+		if err != nil {
+			return err
+		}
+		if mode == MutationDryRun {
+			if r.dryRunReported[pod.UID] != rec.ResourceVersion {
+				r.dryRunReported[pod.UID] = rec.ResourceVersion
+				log.Printf("[Resize] [Dry Run] Pod: %s/%s, resize not applied: %s", namespace, pod.Name, patchBytes)
+				inPlaceResizes.WithLabelValues(ResizeResultDryRun).Inc()
+			}
+			continue
+		}
+		if _, err := cluster.Client.Resource(podGVR).Namespace(namespace).Patch(ctx, pod.Name, types.JSONPatchType, patchBytes, metav1.PatchOptions{}, "resize"); err != nil {
+			log.Printf("[Resize] Failed to resize pod %s/%s: %v", namespace, pod.Name, err)
+			inPlaceResizes.WithLabelValues(ResizeResultError).Inc()
+			continue
+		}
+		log.Printf("[Resize] Pod: %s/%s, applied Recommendation %s/%s: %s", namespace, pod.Name, rec.Namespace, rec.Name, patchBytes)
+		inPlaceResizes.WithLabelValues(ResizeResultResized).Inc()
+		inFlight++
+	}
+	return nil
+}
+
+// resizePatches decides the in-place resize of a running pod with the same checks as MutatePod. Only
+// the cpu and memory of regular containers are resized, resources whose resizePolicy requires a
+// container restart are left alone unless webhook.in-place-resize.allow-container-restart is set,
+// and a resize that would remove a limit or change the pod's QoS class is not attempted, since the
+// API server rejects both.
+func resizePatches(cluster *memberCluster, clusterCfg config.WebhookCluster, pod *corev1.Pod, rec *cachedRecommendation) ([]modelWebhook.JSONPatch, MutationMode) {
+	workload := cluster.resolver().Resolve(pod.Namespace, pod.OwnerReferences)
+	annotations := cluster.resolver().WorkloadAnnotations(pod.Namespace, workload)
+	mode, _ := mutationModeFor(cluster, pod, pod.Namespace, annotations)
+	if mode == MutationDisabled {
+		return nil, mode
+	}
+	autoscalers := checkAutoscalers(cluster, pod.Namespace, workload)
+	if autoscalers.Skip || qosSettingsFor(pod, annotations).skips() {
+		return nil, mode
+	}
+	allowRestart := global.GVA_CONFIG.Webhook.InPlaceResize.AllowContainerRestart
+	limits := limitSettingsFor(pod, annotations)
+	guardrail := guardrailFor(clusterCfg, pod.Namespace)
+	subject := "Pod: " + pod.Name
+
+	var plans []*containerPlan
+	for _, ref := range mutableContainers(pod) {
+		if ref.Init {
+			continue
+		}
+		targets, ok := rec.Containers[ref.Container.Name]
+		if !ok {
+			continue
+		}
+		targets = resizableTargets(ref.Container, withoutResources(targets, autoscalers.SkipResources), allowRestart)
+		plan, _ := planContainer(subject, ref, targets, limits, guardrail)
+		if plan.empty() {
+			continue
+		}
+		if len(plan.RemoveLimits) > 0 {
+			log.Printf("[Resize] %s, container %s: limit policy %s removes a limit, which a resize cannot do; skipping", subject, ref.Container.Name, limits.Policy)
+			continue
+		}
+		plans = append(plans, plan)
+	}
+	// The LimitRanger and the quota admission check resizes too; the quota only charges the increase.
+	for _, note := range fitNamespacePolicies(cluster, pod, pod.Namespace, plans, true) {
+		log.Printf("[Resize] %s, %s", subject, note)
+	}
+	plans = nonEmptyPlans(plans)
+	if len(plans) == 0 {
+		return nil, mode
+	}
+	resized := applyPlans(pod, plans)
+	if before, after := qosClass(pod), qosClass(resized); before != after {
+		log.Printf("[Resize] %s, resize would change QoS class from %s to %s; skipping", subject, before, after)
+		return nil, mode
+	}
+	for _, hpa := range autoscalers.ScaleHPAs {
+		for _, w := range scaleHPATargets(cluster, hpa, pod, resized, mode == MutationDryRun) {
+			log.Printf("[Resize] %s, %s", subject, w)
+		}
+	}
+
+	var patches []modelWebhook.JSONPatch
+	for _, plan := range plans {
+		patches = append(patches, plan.patches()...)
+	}
+	return patches, mode
+}
+
+// resizableTargets keeps the cpu and memory targets, the only resources a resize may change, and
+// drops those whose resizePolicy restarts the container unless allowRestart is set.
+func resizableTargets(c *corev1.Container, targets corev1.ResourceList, allowRestart bool) corev1.ResourceList {
+	out := corev1.ResourceList{}
+	for name, q := range targets {
+		if name != corev1.ResourceCPU && name != corev1.ResourceMemory {
+			continue
+		}
+		restart := false
+		for _, p := range c.ResizePolicy {
+			if p.ResourceName == name && p.RestartPolicy == corev1.RestartContainer {
+				restart = true
+			}
+		}
+		if allowRestart || !restart {
+			out[name] = q
+		}
+	}
+	return out
+}
+
+// resizeInProgress reports whether the kubelet has not finished a resize of the pod yet. Infeasible
+// resizes never finish and do not count. The deprecated status.resize covers clusters before 1.33.
+func resizeInProgress(pod *corev1.Pod) bool {
+	for _, cond := range pod.Status.Conditions {
+		if cond.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch cond.Type {
+		case corev1.PodResizeInProgress:
+			return true
+		case corev1.PodResizePending:
+			return cond.Reason != corev1.PodReasonInfeasible
+		}
+	}
+	switch pod.Status.Resize {
+	case corev1.PodResizeStatusInProgress, corev1.PodResizeStatusDeferred:
+		return true
+	}
+	return false
+}
+
+// resolver returns the cluster's owner resolver, falling back to live lookups.
+func (c *memberCluster) resolver() *OwnerResolver {
+	if c.Resolver != nil {
+		return c.Resolver
+	}
+	return NewOwnerResolver(c.Client, nil)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,327 @@
+package webhook
+
+import (
+	"encoding/json"
+	"testing"
+
+	"main.go/config"
+	"main.go/global"
+	modelWebhook "main.go/model/webhook"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	clienttesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+)
+
+const resizeNamespace = "shop"
+
+// withWebhookConfig applies change to the webhook config for the duration of the test.
+func withWebhookConfig(t *testing.T, change func(*config.Webhook)) {
+	t.Helper()
+	saved := global.GVA_CONFIG.Webhook
+	t.Cleanup(func() { global.GVA_CONFIG.Webhook = saved })
+	change(&global.GVA_CONFIG.Webhook)
+}
+
+// resources builds a resource list from name/quantity pairs.
+func resources(pairs ...string) corev1.ResourceList {
+	l := corev1.ResourceList{}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		l[corev1.ResourceName(pairs[i])] = resource.MustParse(pairs[i+1])
+	}
+	return l
+}
+
+// runningPod is a running pod of StatefulSet web with a single container app.
+func runningPod(name string, requirements corev1.ResourceRequirements, policy ...corev1.ContainerResizePolicy) *corev1.Pod {
+	isController := true
+	return &corev1.Pod{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: resizeNamespace,
+			UID:       types.UID("uid-" + name),
+			OwnerReferences: []metav1.OwnerReference{
+				{APIVersion: "apps/v1", Kind: "StatefulSet", Name: "web", Controller: &isController},
+			},
+		},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{
+			{Name: "app", Resources: requirements, ResizePolicy: policy},
+		}},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+}
+
+// resizeCluster is a member cluster backed by a fake dynamic client holding the namespace, the
+// StatefulSet web and the given pods.
+func resizeCluster(t *testing.T, pods ...*corev1.Pod) (*memberCluster, *dynamicfake.FakeDynamicClient) {
+	t.Helper()
+	objects := []runtime.Object{
+		&unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1", "kind": "Namespace",
+			"metadata": map[string]interface{}{"name": resizeNamespace},
+		}},
+		&unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "apps/v1", "kind": "StatefulSet",
+			"metadata": map[string]interface{}{"name": "web", "namespace": resizeNamespace},
+		}},
+	}
+	for _, pod := range pods {
+		obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(pod)
+		if err != nil {
+			t.Fatal(err)
+		}
+		objects = append(objects, &unstructured.Unstructured{Object: obj})
+	}
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		podGVR:       "PodList",
+		namespaceGVR: "NamespaceList",
+		{Group: "apps", Version: "v1", Resource: "statefulsets"}: "StatefulSetList",
+	}, objects...)
+	return &memberCluster{ID: "member", Client: client, ready: true}, client
+}
+
+// webRecommendation recommends targets for container app of StatefulSet web.
+func webRecommendation(targets corev1.ResourceList) map[string]*cachedRecommendation {
+	key := targetWorkloadIndexKey("member", resizeNamespace, "StatefulSet", "web")
+	return map[string]*cachedRecommendation{key: {
+		Namespace:       "bcs-finops-system",
+		Name:            "web",
+		ResourceVersion: "7",
+		WorkloadKey:     key,
+		Containers:      map[string]corev1.ResourceList{"app": targets},
+	}}
+}
+
+// resizes returns the JSON patches sent to the resize subresource, by pod name.
+func resizes(t *testing.T, client *dynamicfake.FakeDynamicClient) map[string]map[string]interface{} {
+	t.Helper()
+	out := map[string]map[string]interface{}{}
+	for _, action := range client.Actions() {
+		patch, ok := action.(clienttesting.PatchAction)
+		if !ok || action.GetResource() != podGVR {
+			continue
+		}
+		if action.GetSubresource() != "resize" {
+			t.Errorf("pod %s patched through %q, want the resize subresource", patch.GetName(), action.GetSubresource())
+		}
+		var ops []modelWebhook.JSONPatch
+		if err := json.Unmarshal(patch.GetPatch(), &ops); err != nil {
+			t.Fatalf("pod %s: invalid patch %s: %v", patch.GetName(), patch.GetPatch(), err)
+		}
+		values := map[string]interface{}{}
+		for _, op := range ops {
+			values[op.Path] = op.Value
+		}
+		out[patch.GetName()] = values
+	}
+	return out
+}
+
+func TestResizeHonoursResizePolicy(t *testing.T) {
+	policy := []corev1.ContainerResizePolicy{
+		{ResourceName: corev1.ResourceCPU, RestartPolicy: corev1.NotRequired},
+		{ResourceName: corev1.ResourceMemory, RestartPolicy: corev1.RestartContainer},
+	}
+	requirements := corev1.ResourceRequirements{Requests: resources("cpu", "1", "memory", "1Gi")}
+
+	for _, tc := range []struct {
+		name         string
+		allowRestart bool
+		want         map[string]interface{}
+	}{
+		{
+			name: "NotRequired only",
+			want: map[string]interface{}{"/spec/containers/0/resources/requests/cpu": "500m"},
+		},
+		{
+			name:         "RestartContainer allowed",
+			allowRestart: true,
+			want: map[string]interface{}{
+				"/spec/containers/0/resources/requests/cpu":    "500m",
+				"/spec/containers/0/resources/requests/memory": "512Mi",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			withWebhookConfig(t, func(cfg *config.Webhook) {
+				cfg.InPlaceResize.AllowContainerRestart = tc.allowRestart
+			})
+			cluster, client := resizeCluster(t, runningPod("web-0", requirements, policy...))
+			err := newPodResizer().reconcileNamespace(cluster, config.WebhookCluster{ID: "member"}, resizeNamespace,
+				webRecommendation(resources("cpu", "500m", "memory", "512Mi")))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := resizes(t, client)["web-0"]
+			if len(got) != len(tc.want) {
+				t.Fatalf("patch = %v, want %v", got, tc.want)
+			}
+			for path, value := range tc.want {
+				if got[path] != value {
+					t.Errorf("%s = %v, want %v", path, got[path], value)
+				}
+			}
+		})
+	}
+}
+
+func TestResizeKeepsWithinLimits(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		limitPolicy  string
+		qosPolicy    string
+		requirements corev1.ResourceRequirements
+		targets      corev1.ResourceList
+		// want is the patch, nil when no resize may be attempted.
+		want map[string]interface{}
+	}{
+		{
+			name:         "request capped at the limit",
+			limitPolicy:  string(LimitPolicyCap),
+			requirements: corev1.ResourceRequirements{Requests: resources("cpu", "500m"), Limits: resources("cpu", "1")},
+			targets:      resources("cpu", "2"),
+			want:         map[string]interface{}{"/spec/containers/0/resources/requests/cpu": "1"},
+		},
+		{
+			name:         "limit removal is not a resize",
+			limitPolicy:  string(LimitPolicyRemoveCPULimit),
+			requirements: corev1.ResourceRequirements{Requests: resources("cpu", "500m"), Limits: resources("cpu", "1")},
+			targets:      resources("cpu", "800m"),
+		},
+		{
+			name:         "QoS class change",
+			qosPolicy:    string(QoSPolicyIgnore),
+			requirements: corev1.ResourceRequirements{Requests: resources("cpu", "1", "memory", "1Gi"), Limits: resources("cpu", "1", "memory", "1Gi")},
+			targets:      resources("cpu", "500m"),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			withWebhookConfig(t, func(cfg *config.Webhook) {
+				cfg.LimitPolicy = tc.limitPolicy
+				cfg.QoSPolicy = tc.qosPolicy
+			})
+			cluster, client := resizeCluster(t, runningPod("web-0", tc.requirements))
+			if err := newPodResizer().reconcileNamespace(cluster, config.WebhookCluster{ID: "member"}, resizeNamespace, webRecommendation(tc.targets)); err != nil {
+				t.Fatal(err)
+			}
+			got, resized := resizes(t, client)["web-0"]
+			if tc.want == nil {
+				if resized {
+					t.Fatalf("resized with %v, want no resize", got)
+				}
+				return
+			}
+			for path, value := range tc.want {
+				if got[path] != value {
+					t.Errorf("%s = %v, want %v (patch %v)", path, got[path], value, got)
+				}
+			}
+		})
+	}
+}
+
+func TestResizeResourceQuotaChargesIncrease(t *testing.T) {
+	// The running pod's cpu request of 1 is part of the 2500m used, so 500m of the quota is left.
+	quota, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&corev1.ResourceQuota{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ResourceQuota"},
+		ObjectMeta: metav1.ObjectMeta{Name: "compute", Namespace: resizeNamespace},
+		Spec:       corev1.ResourceQuotaSpec{Hard: resources("requests.cpu", "3")},
+		Status:     corev1.ResourceQuotaStatus{Hard: resources("requests.cpu", "3"), Used: resources("requests.cpu", "2500m")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name    string
+		targets corev1.ResourceList
+		// want is the patched cpu request, "" when the quota leaves the pod unchanged.
+		want string
+	}{
+		{"increase within the quota", resources("cpu", "1500m"), "1500m"},
+		{"increase beyond the quota", resources("cpu", "2"), ""},
+		{"decrease", resources("cpu", "500m"), "500m"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cluster, client := resizeCluster(t, runningPod("web-0", corev1.ResourceRequirements{Requests: resources("cpu", "1")}))
+			cluster.ResourceQuotas = cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+			if err := cluster.ResourceQuotas.Add(&unstructured.Unstructured{Object: quota}); err != nil {
+				t.Fatal(err)
+			}
+			if err := newPodResizer().reconcileNamespace(cluster, config.WebhookCluster{ID: "member"}, resizeNamespace, webRecommendation(tc.targets)); err != nil {
+				t.Fatal(err)
+			}
+			got, resized := resizes(t, client)["web-0"]
+			if tc.want == "" {
+				if resized {
+					t.Fatalf("resized with %v, want no resize", got)
+				}
+				return
+			}
+			if cpu := got["/spec/containers/0/resources/requests/cpu"]; cpu != tc.want {
+				t.Errorf("cpu request = %v, want %s (patch %v)", cpu, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestResizeNamespaceBudget(t *testing.T) {
+	requirements := corev1.ResourceRequirements{Requests: resources("cpu", "1")}
+	inProgress := runningPod("web-0", requirements)
+	inProgress.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodResizeInProgress, Status: corev1.ConditionTrue}}
+	infeasible := runningPod("web-1", requirements)
+	infeasible.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodResizePending, Status: corev1.ConditionTrue, Reason: corev1.PodReasonInfeasible}}
+
+	for _, tc := range []struct {
+		name   string
+		budget int
+		pods   []*corev1.Pod
+		want   int
+	}{
+		{"default budget of one", 0, []*corev1.Pod{runningPod("web-0", requirements), runningPod("web-1", requirements), runningPod("web-2", requirements)}, 1},
+		{"budget of two", 2, []*corev1.Pod{runningPod("web-0", requirements), runningPod("web-1", requirements), runningPod("web-2", requirements)}, 2},
+		{"resize in progress uses the budget", 1, []*corev1.Pod{inProgress, runningPod("web-2", requirements)}, 0},
+		{"infeasible resize does not count", 1, []*corev1.Pod{infeasible, runningPod("web-2", requirements)}, 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			withWebhookConfig(t, func(cfg *config.Webhook) {
+				cfg.InPlaceResize.MaxConcurrentPerNamespace = tc.budget
+			})
+			cluster, client := resizeCluster(t, tc.pods...)
+			if err := newPodResizer().reconcileNamespace(cluster, config.WebhookCluster{ID: "member"}, resizeNamespace, webRecommendation(resources("cpu", "500m"))); err != nil {
+				t.Fatal(err)
+			}
+			if got := resizes(t, client); len(got) != tc.want {
+				t.Errorf("resized %d pods (%v), want %d", len(got), got, tc.want)
+			}
+		})
+	}
+}
+
+func TestResizeDryRun(t *testing.T) {
+	withWebhookConfig(t, func(cfg *config.Webhook) {
+		cfg.MutationMode = string(MutationDryRun)
+	})
+	cluster, client := resizeCluster(t, runningPod("web-0", corev1.ResourceRequirements{Requests: resources("cpu", "1")}))
+	r := newPodResizer()
+	recs := webRecommendation(resources("cpu", "500m"))
+	for pass := 0; pass < 2; pass++ {
+		if err := r.reconcileNamespace(cluster, config.WebhookCluster{ID: "member"}, resizeNamespace, recs); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := resizes(t, client); len(got) != 0 {
+		t.Fatalf("dry run resized %v", got)
+	}
+	if got := r.dryRunReported["uid-web-0"]; got != "7" {
+		t.Errorf("dry-run report recorded for version %q, want 7", got)
+	}
+}
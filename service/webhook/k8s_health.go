@@ -0,0 +1,161 @@
+package webhook
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"main.go/global"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+)
+
+const (
+	defaultSyncTimeout    = 2 * time.Minute
+	defaultRetryMaxDelay  = 5 * time.Minute
+	defaultUnhealthyAfter = 5 * time.Minute
+	// The reflector backs off watch retries up to 30s, so a quiet minute means the watch is back.
+	watchRecoveredAfter = time.Minute
+)
+
+// k8sHealth tracks the Kubernetes startup and informer watches for the readiness and liveness
+// probes. The webhook admits pods without a patch until it is ready.
+type k8sHealth struct {
+	mu           sync.RWMutex
+	ready        bool
+	lastInitErr  error
+	initAttempts int
+	// watchFailingSince and watchLastErr are keyed by resource and cleared once the watch recovers.
+	watchFailingSince map[string]time.Time
+	watchLastErr      map[string]error
+	watchLastErrAt    map[string]time.Time
+
+	clientOnce  sync.Once
+	clientReady chan struct{}
+	client      dynamic.Interface
+}
+
+var k8sStatus = &k8sHealth{
+	watchFailingSince: map[string]time.Time{},
+	watchLastErr:      map[string]error{},
+	watchLastErrAt:    map[string]time.Time{},
+	clientReady:       make(chan struct{}),
+}
+
+func (h *k8sHealth) initFailed(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.initAttempts++
+	h.lastInitErr = err
+}
+
+func (h *k8sHealth) setReady() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.ready = true
+	h.lastInitErr = nil
+}
+
+// setClient publishes the first working dynamic client to WaitForK8sClient callers.
+func (h *k8sHealth) setClient(client dynamic.Interface) {
+	h.clientOnce.Do(func() {
+		h.client = client
+		close(h.clientReady)
+	})
+}
+
+func (h *k8sHealth) watchFailed(resource string, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := time.Now()
+	if last, ok := h.watchLastErrAt[resource]; !ok || now.Sub(last) > watchRecoveredAfter {
+		h.watchFailingSince[resource] = now
+	}
+	h.watchLastErr[resource] = err
+	h.watchLastErrAt[resource] = now
+}
+
+// StartK8s initializes the Kubernetes client and informers, and those of the member clusters, in
+// the background, retrying with backoff until it succeeds. Nothing here exits the process, so the
+// alert API keeps serving while the cluster is unreachable.
+func (s *RecommendationService) StartK8s() {
+	startup := global.GVA_CONFIG.Webhook.Startup
+	maxDelay := startup.RetryMaxDelay
+	if maxDelay <= 0 {
+		maxDelay = defaultRetryMaxDelay
+	}
+
+	startMemberClusters()
+	go func() {
+		backoff := wait.Backoff{Duration: time.Second, Factor: 2, Jitter: 0.2, Steps: math.MaxInt32, Cap: maxDelay}
+		for {
+			err := s.InitK8s()
+			if err == nil {
+				startRecommendationController()
+				startPodResizer()
+				return
+			}
+			k8sStatus.initFailed(err)
+			k8sInitFailures.Inc()
+			delay := backoff.Step()
+			log.Printf("Kubernetes initialization failed, webhook admits pods unchanged; retrying in %s: %v", delay.Round(time.Second), err)
+			time.Sleep(delay)
+		}
+	}()
+}
+
+// WaitForK8sClient blocks until a dynamic client has been created or stopCh is closed, in which
+// case it returns nil.
+func (s *RecommendationService) WaitForK8sClient(stopCh <-chan struct{}) dynamic.Interface {
+	select {
+	case <-k8sStatus.clientReady:
+		return k8sStatus.client
+	case <-stopCh:
+		return nil
+	}
+}
+
+// Ready reports whether the informer caches have synced and the webhook can apply recommendations.
+// Member clusters that are still syncing are listed but do not make the webhook unready, since
+// their pods are admitted unchanged until they catch up.
+func (s *RecommendationService) Ready() (bool, string) {
+	k8sStatus.mu.RLock()
+	defer k8sStatus.mu.RUnlock()
+	if k8sStatus.ready {
+		if pending := memberClusters.notReady(); len(pending) > 0 {
+			return true, fmt.Sprintf("informer caches synced, member clusters not synced: %s", strings.Join(pending, ", "))
+		}
+		return true, "informer caches synced"
+	}
+	if k8sStatus.lastInitErr != nil {
+		return false, fmt.Sprintf("kubernetes initialization failed %d time(s), last error: %v", k8sStatus.initAttempts, k8sStatus.lastInitErr)
+	}
+	return false, "waiting for informer caches to sync"
+}
+
+// Live reports whether the informers are healthy. A process that is still retrying its startup
+// is live, since restarting it would not help and would take the alert API down with it; only
+// a watch that keeps failing after the caches synced is reported.
+func (s *RecommendationService) Live() (bool, string) {
+	unhealthyAfter := global.GVA_CONFIG.Webhook.Startup.UnhealthyAfter
+	if unhealthyAfter <= 0 {
+		unhealthyAfter = defaultUnhealthyAfter
+	}
+
+	k8sStatus.mu.RLock()
+	defer k8sStatus.mu.RUnlock()
+	now := time.Now()
+	for resource, since := range k8sStatus.watchFailingSince {
+		if now.Sub(k8sStatus.watchLastErrAt[resource]) > watchRecoveredAfter {
+			continue
+		}
+		if now.Sub(since) > unhealthyAfter {
+			return false, fmt.Sprintf("%s watch failing since %s: %v", resource, since.Format(time.RFC3339), k8sStatus.watchLastErr[resource])
+		}
+	}
+	return true, "ok"
+}
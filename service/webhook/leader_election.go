@@ -0,0 +1,105 @@
+package webhook
+
+import (
+	"context"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"main.go/global"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+const (
+	defaultLeaseDuration = 15 * time.Second
+	defaultRenewDeadline = 10 * time.Second
+	defaultRetryPeriod   = 2 * time.Second
+)
+
+var (
+	leading             atomic.Bool
+	leaderElectionStart sync.Once
+)
+
+// IsLeader reports whether this replica may perform cluster-wide writes such as issuing the
+// webhook certificate or applying recommendations to workloads. Every replica serves admission
+// requests regardless of leadership. With leader election disabled each replica is its own leader.
+func (s *RecommendationService) IsLeader() bool {
+	return isLeader()
+}
+
+func isLeader() bool {
+	if !global.GVA_CONFIG.Webhook.LeaderElection.Enabled {
+		return true
+	}
+	return leading.Load()
+}
+
+// startLeaderElection campaigns for the configured Lease in the background for the lifetime of
+// the process. It is started once, by the first successful client initialization.
+func startLeaderElection(client kubernetes.Interface) {
+	cfg := global.GVA_CONFIG.Webhook.LeaderElection
+	if !cfg.Enabled {
+		return
+	}
+	leaderElectionStart.Do(func() {
+		identity, err := os.Hostname()
+		if err != nil || identity == "" {
+			identity = "finops-extend"
+		}
+		if cfg.LeaseDuration <= 0 {
+			cfg.LeaseDuration = defaultLeaseDuration
+		}
+		if cfg.RenewDeadline <= 0 {
+			cfg.RenewDeadline = defaultRenewDeadline
+		}
+		if cfg.RetryPeriod <= 0 {
+			cfg.RetryPeriod = defaultRetryPeriod
+		}
+
+		lock := &resourcelock.LeaseLock{
+			LeaseMeta:  metav1.ObjectMeta{Name: cfg.LeaseName, Namespace: cfg.LeaseNamespace},
+			Client:     client.CoordinationV1(),
+			LockConfig: resourcelock.ResourceLockConfig{Identity: identity},
+		}
+		electionConfig := leaderelection.LeaderElectionConfig{
+			Lock:          lock,
+			LeaseDuration: cfg.LeaseDuration,
+			RenewDeadline: cfg.RenewDeadline,
+			RetryPeriod:   cfg.RetryPeriod,
+			Name:          cfg.LeaseName,
+			Callbacks: leaderelection.LeaderCallbacks{
+				OnStartedLeading: func(context.Context) {
+					log.Printf("Acquired leader lease %s/%s as %s", cfg.LeaseNamespace, cfg.LeaseName, identity)
+					leading.Store(true)
+					leaderGauge.Set(1)
+				},
+				OnStoppedLeading: func() {
+					log.Printf("Lost leader lease %s/%s", cfg.LeaseNamespace, cfg.LeaseName)
+					leading.Store(false)
+					leaderGauge.Set(0)
+				},
+			},
+		}
+
+		go func() {
+			for {
+				elector, err := leaderelection.NewLeaderElector(electionConfig)
+				if err != nil {
+					// A configuration error will not fix itself; stay a follower.
+					log.Printf("Leader election disabled, invalid configuration: %v", err)
+					return
+				}
+				// Run returns once leadership is lost; campaign again.
+				elector.Run(context.Background())
+				time.Sleep(cfg.RetryPeriod)
+			}
+		}()
+	})
+}
@@ -0,0 +1,175 @@
+package webhook
+
+import (
+	"log"
+	"math"
+	"strconv"
+
+	"main.go/global"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// LimitPolicy decides how container limits follow a recommended request.
+type LimitPolicy string
+
+const (
+	// LimitPolicyCap keeps limits untouched and caps the recommended request at the existing limit.
+	LimitPolicyCap LimitPolicy = "cap"
+	// LimitPolicyKeepRatio scales the limit so the original request:limit ratio is preserved.
+	LimitPolicyKeepRatio LimitPolicy = "keep-ratio"
+	// LimitPolicyMultiplier sets the limit to the recommended request times a configured multiplier.
+	LimitPolicyMultiplier LimitPolicy = "multiplier"
+	// LimitPolicyRemoveCPULimit drops the CPU limit and caps memory like LimitPolicyCap.
+	LimitPolicyRemoveCPULimit LimitPolicy = "remove-cpu-limit"
+)
+
+const (
+	// LimitPolicyAnnotation overrides the configured limit policy for a workload. Like MutationAnnotation
+	// it is read from the owning workload and from the pod (template), where the pod's value wins.
+	LimitPolicyAnnotation = "finops.io/limit-policy"
+	// LimitMultiplierAnnotation overrides the configured multiplier for LimitPolicyMultiplier. It is
+	// read from the same places as LimitPolicyAnnotation.
+	LimitMultiplierAnnotation = "finops.io/limit-multiplier"
+)
+
+const defaultLimitMultiplier = 2.0
+
+// limitSettings is the effective limit policy for one pod.
+type limitSettings struct {
+	Policy     LimitPolicy
+	Multiplier float64
+	// MatchRequests sets every existing limit to the new request, overriding Policy, so a
+	// Guaranteed pod stays Guaranteed.
+	MatchRequests bool
+}
+
+// limitSettingsFor merges the global webhook config with the workload and pod annotations and the
+// QoS policy. workloadAnnotations are the annotations of the pod's top-level workload, nil when it has none.
+func limitSettingsFor(pod *corev1.Pod, workloadAnnotations map[string]string) limitSettings {
+	cfg := global.GVA_CONFIG.Webhook
+	settings := limitSettings{
+		Policy:        parseLimitPolicy(cfg.LimitPolicy),
+		Multiplier:    cfg.LimitMultiplier,
+		MatchRequests: qosSettingsFor(pod, workloadAnnotations).matchLimits(),
+	}
+
+	if v, ok := workloadSetting(pod, workloadAnnotations, LimitPolicyAnnotation); ok {
+		settings.Policy = parseLimitPolicy(v)
+	}
+	if v, ok := workloadSetting(pod, workloadAnnotations, LimitMultiplierAnnotation); ok {
+		if m, err := strconv.ParseFloat(v, 64); err == nil {
+			settings.Multiplier = m
+		} else {
+			log.Printf("Invalid %s annotation %q for pod %s: %v", LimitMultiplierAnnotation, v, pod.GenerateName, err)
+		}
+	}
+
+	if settings.Multiplier < 1 {
+		settings.Multiplier = defaultLimitMultiplier
+	}
+	return settings
+}
+
+// workloadSetting reads a per-workload annotation from the pod (template), falling back to the
+// annotations of the owning workload.
+func workloadSetting(pod *corev1.Pod, workloadAnnotations map[string]string, key string) (string, bool) {
+	if v, ok := pod.Annotations[key]; ok {
+		return v, true
+	}
+	v, ok := workloadAnnotations[key]
+	return v, ok
+}
+
+func parseLimitPolicy(v string) LimitPolicy {
+	switch p := LimitPolicy(v); p {
+	case LimitPolicyCap, LimitPolicyKeepRatio, LimitPolicyMultiplier, LimitPolicyRemoveCPULimit:
+		return p
+	case "":
+		return LimitPolicyCap
+	default:
+		log.Printf("Unknown limit policy %q, falling back to %s", v, LimitPolicyCap)
+		return LimitPolicyCap
+	}
+}
+
+// limitDecision is the outcome of applying a limit policy to one resource of one container.
+type limitDecision struct {
+	Request     resource.Quantity
+	Limit       *resource.Quantity // nil leaves the limit untouched
+	RemoveLimit bool
+	// Capped is set when the request was lowered to the existing limit.
+	Capped bool
+}
+
+// decide computes the request and limit to write for a resource. Only resources that already
+// carry a limit have it changed, except hugepages and extended resources, whose limit always
+// follows the request; the result always keeps request <= limit.
+func (l limitSettings) decide(name corev1.ResourceName, target resource.Quantity, current corev1.ResourceRequirements) limitDecision {
+	decision := limitDecision{Request: target}
+
+	limit, hasLimit := current.Limits[name]
+	overcommit := overcommitAllowed(name)
+	if !hasLimit && overcommit {
+		return decision
+	}
+	if l.MatchRequests || !overcommit {
+		// Hugepages and extended resources need a limit equal to the request, so one is set even
+		// where the container had none.
+		if !hasLimit || target.Cmp(limit) != 0 {
+			matched := target.DeepCopy()
+			decision.Limit = &matched
+		}
+		return decision
+	}
+
+	policy := l.Policy
+	if policy == LimitPolicyRemoveCPULimit && name != corev1.ResourceCPU {
+		policy = LimitPolicyCap
+	}
+
+	switch policy {
+	case LimitPolicyRemoveCPULimit:
+		decision.RemoveLimit = true
+	case LimitPolicyKeepRatio:
+		ratio := 1.0
+		if request, ok := current.Requests[name]; ok && request.MilliValue() > 0 {
+			ratio = float64(limit.MilliValue()) / float64(request.MilliValue())
+		}
+		newLimit := scaleQuantity(target, ratio)
+		decision.Limit = &newLimit
+	case LimitPolicyMultiplier:
+		newLimit := scaleQuantity(target, l.Multiplier)
+		decision.Limit = &newLimit
+	default:
+		if target.Cmp(limit) > 0 {
+			log.Printf("Recommended %s %s exceeds limit %s, capping at the limit", name, target.String(), limit.String())
+			decision.Request = limit.DeepCopy()
+			decision.Capped = true
+		}
+	}
+
+	// Rounding must never leave the request above the limit.
+	if decision.Limit != nil {
+		if decision.Limit.Cmp(decision.Request) < 0 {
+			fixed := decision.Request.DeepCopy()
+			decision.Limit = &fixed
+		}
+		if decision.Limit.Cmp(limit) == 0 {
+			decision.Limit = nil
+		}
+	}
+	return decision
+}
+
+// scaleQuantity multiplies q by factor, rounding up to a whole millicore or, for binary
+// quantities such as memory, to a whole Mi.
+func scaleQuantity(q resource.Quantity, factor float64) resource.Quantity {
+	if q.Format == resource.BinarySI {
+		const mi = 1 << 20
+		mib := math.Ceil(float64(q.Value()) * factor / mi)
+		return *resource.NewQuantity(int64(mib)*mi, resource.BinarySI)
+	}
+	return *resource.NewMilliQuantity(int64(math.Ceil(float64(q.MilliValue())*factor)), q.Format)
+}
@@ -0,0 +1,48 @@
+package webhook
+
+import (
+	"testing"
+
+	"main.go/config"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestLimitSettingsAnnotationSources(t *testing.T) {
+	withWebhookConfig(t, func(cfg *config.Webhook) {
+		cfg.LimitPolicy = string(LimitPolicyCap)
+		cfg.QoSPolicy = string(QoSPolicyPreserve)
+	})
+	guaranteed := corev1.ResourceRequirements{Requests: resources("cpu", "1", "memory", "1Gi"), Limits: resources("cpu", "1", "memory", "1Gi")}
+
+	for _, tc := range []struct {
+		name     string
+		workload map[string]string
+		pod      map[string]string
+		want     limitSettings
+	}{
+		{"config", nil, nil, limitSettings{Policy: LimitPolicyCap, Multiplier: defaultLimitMultiplier, MatchRequests: true}},
+		{
+			name:     "workload annotations",
+			workload: map[string]string{LimitPolicyAnnotation: string(LimitPolicyMultiplier), LimitMultiplierAnnotation: "3", QoSPolicyAnnotation: string(QoSPolicyIgnore)},
+			want:     limitSettings{Policy: LimitPolicyMultiplier, Multiplier: 3},
+		},
+		{
+			name:     "pod template wins",
+			workload: map[string]string{LimitPolicyAnnotation: string(LimitPolicyMultiplier), LimitMultiplierAnnotation: "3", QoSPolicyAnnotation: string(QoSPolicyIgnore)},
+			pod:      map[string]string{LimitPolicyAnnotation: string(LimitPolicyKeepRatio), QoSPolicyAnnotation: string(QoSPolicyPreserve)},
+			want:     limitSettings{Policy: LimitPolicyKeepRatio, Multiplier: 3, MatchRequests: true},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{GenerateName: "app-0-", Annotations: tc.pod},
+				Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Resources: guaranteed}}},
+			}
+			if got := limitSettingsFor(pod, tc.workload); got != tc.want {
+				t.Errorf("limitSettingsFor = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,347 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"main.go/config"
+	"main.go/global"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/metadata"
+	"k8s.io/client-go/metadata/metadatainformer"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/record"
+)
+
+// memberCluster is a cluster whose pods the webhook admits. Owner references and namespace labels
+// are read from that cluster, while Recommendations come from the shared store, which is keyed by
+// spec.cluster.
+type memberCluster struct {
+	ID         string
+	Client     dynamic.Interface
+	Resolver   *OwnerResolver
+	Namespaces cache.Indexer
+	// HPAs and VPAs are indexed by scaleTargetIndex; VPAs is nil when the CRD is not installed.
+	HPAs cache.Indexer
+	VPAs cache.Indexer
+	// LimitRanges and ResourceQuotas are indexed by namespace.
+	LimitRanges    cache.Indexer
+	ResourceQuotas cache.Indexer
+	// Events records Events on the workloads of mutated pods.
+	Events record.EventRecorder
+
+	ready   bool
+	lastErr error
+}
+
+// clusterRegistry holds the member clusters by ID. Entries are replaced, never modified.
+type clusterRegistry struct {
+	mu       sync.RWMutex
+	clusters map[string]*memberCluster
+}
+
+var (
+	memberClusters     = &clusterRegistry{clusters: map[string]*memberCluster{}}
+	memberClusterStart sync.Once
+)
+
+func (r *clusterRegistry) set(c *memberCluster) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.clusters[c.ID] = c
+}
+
+func (r *clusterRegistry) get(id string) *memberCluster {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.clusters[id]
+}
+
+// notReady describes the clusters whose caches have not synced yet.
+func (r *clusterRegistry) notReady() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var out []string
+	for id, c := range r.clusters {
+		if c.ready {
+			continue
+		}
+		if c.lastErr != nil {
+			out = append(out, fmt.Sprintf("%s (%v)", id, c.lastErr))
+		} else {
+			out = append(out, id)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// clusterConfig returns the settings of a served cluster. The cluster of system.cluster-id is always
+// served; other clusters have to be listed under webhook.clusters.
+func clusterConfig(id string) (config.WebhookCluster, bool) {
+	for _, c := range global.GVA_CONFIG.Webhook.Clusters {
+		if c.ID == id {
+			return c, true
+		}
+	}
+	if id == global.GVA_CONFIG.System.ClusterId {
+		return config.WebhookCluster{ID: id}, true
+	}
+	return config.WebhookCluster{}, false
+}
+
+func clusterEnabled(c config.WebhookCluster) bool {
+	return c.Enabled == nil || *c.Enabled
+}
+
+// hasOwnConnection reports whether a member cluster is reached through its own kubeconfig rather
+// than the connection the Recommendations are read from.
+func hasOwnConnection(c config.K8s) bool {
+	return c.KubeConfig != "" || c.Host != ""
+}
+
+// restConfigFor builds the client config from a kubeconfig file, a host and token, or the
+// in-cluster service account, in that order.
+func restConfigFor(k8sConfig config.K8s) (*rest.Config, error) {
+	if k8sConfig.KubeConfig != "" {
+		return clientcmd.BuildConfigFromFlags("", k8sConfig.KubeConfig)
+	}
+	if k8sConfig.Host != "" && k8sConfig.BearerToken != "" {
+		return &rest.Config{
+			Host:        k8sConfig.Host,
+			BearerToken: k8sConfig.BearerToken,
+			TLSClientConfig: rest.TLSClientConfig{
+				Insecure: true,
+			},
+		}, nil
+	}
+	return rest.InClusterConfig()
+}
+
+// clusterInformers are the per-cluster caches used to resolve pod owners, namespace labels and
+// policies, and the autoscalers of a workload.
+type clusterInformers struct {
+	informers      map[schema.GroupVersionResource]cache.SharedIndexInformer
+	ownerIndexers  map[schema.GroupVersionResource]cache.Indexer
+	namespaces     cache.Indexer
+	hpas           cache.Indexer
+	vpas           cache.Indexer
+	limitRanges    cache.Indexer
+	resourceQuotas cache.Indexer
+}
+
+// addClusterInformers registers the owner, namespace, namespace policy and autoscaler informers of
+// one cluster on its factories. The VPA informer is only added when the cluster serves the CRD, since it would never sync otherwise.
+func addClusterInformers(factory dynamicinformer.DynamicSharedInformerFactory, metadataFactory metadatainformer.SharedInformerFactory, watchVPA bool) (clusterInformers, error) {
+	ci := clusterInformers{
+		informers:     map[schema.GroupVersionResource]cache.SharedIndexInformer{},
+		ownerIndexers: make(map[schema.GroupVersionResource]cache.Indexer, len(intermediateOwnerGVRs)+len(workloadMetadataGVRs)),
+	}
+	// Intermediate owners (ReplicaSets, Jobs) are cached so pods can be resolved to their top-level workload
+	for _, gvr := range intermediateOwnerGVRs {
+		ownerInformer := factory.ForResource(gvr).Informer()
+		ci.ownerIndexers[gvr] = ownerInformer.GetIndexer()
+		ci.informers[gvr] = ownerInformer
+	}
+	// Only the metadata of the top-level workloads is cached, for their finops.io/mutation annotation
+	for _, gvr := range workloadMetadataGVRs {
+		workloadInformer := metadataFactory.ForResource(gvr).Informer()
+		ci.ownerIndexers[gvr] = workloadInformer.GetIndexer()
+		ci.informers[gvr] = workloadInformer
+	}
+	// Namespace labels drive the namespace selector and per-namespace mutation mode
+	namespaceInformer := factory.ForResource(namespaceGVR).Informer()
+	ci.namespaces = namespaceInformer.GetIndexer()
+	ci.informers[namespaceGVR] = namespaceInformer
+
+	// LimitRanges and ResourceQuotas bound what a patch may set, see fitNamespacePolicies
+	limitRangeInformer := factory.ForResource(limitRangeGVR).Informer()
+	ci.limitRanges = limitRangeInformer.GetIndexer()
+	ci.informers[limitRangeGVR] = limitRangeInformer
+	quotaInformer := factory.ForResource(resourceQuotaGVR).Informer()
+	ci.resourceQuotas = quotaInformer.GetIndexer()
+	ci.informers[resourceQuotaGVR] = quotaInformer
+
+	// HPAs and VPAs decide how a workload's requests may change, see checkAutoscalers
+	autoscalers := map[schema.GroupVersionResource]string{hpaGVR: "scaleTargetRef"}
+	if watchVPA {
+		autoscalers[vpaGVR] = "targetRef"
+	}
+	for gvr, refField := range autoscalers {
+		inf := factory.ForResource(gvr).Informer()
+		if err := inf.AddIndexers(cache.Indexers{scaleTargetIndex: scaleTargetIndexFunc(refField)}); err != nil {
+			return ci, fmt.Errorf("add %s indexer: %w", gvr.Resource, err)
+		}
+		ci.informers[gvr] = inf
+	}
+	ci.hpas = ci.informers[hpaGVR].GetIndexer()
+	if watchVPA {
+		ci.vpas = ci.informers[vpaGVR].GetIndexer()
+	}
+	return ci, nil
+}
+
+// servesResource reports whether the API server serves a resource, e.g. a CRD that may not be installed.
+func servesResource(restConfig *rest.Config, gvr schema.GroupVersionResource) (bool, error) {
+	dc, err := discovery.NewDiscoveryClientForConfig(restConfig)
+	if err != nil {
+		return false, fmt.Errorf("create discovery client: %w", err)
+	}
+	resources, err := dc.ServerResourcesForGroupVersion(gvr.GroupVersion().String())
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("discover %s: %w", gvr.GroupVersion(), err)
+	}
+	for _, r := range resources.APIResources {
+		if r.Name == gvr.Resource {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// informerFactory is the part of the dynamic and metadata informer factories syncInformers needs.
+type informerFactory interface {
+	Start(stopCh <-chan struct{})
+	Shutdown()
+}
+
+// syncInformers starts the factories and waits up to webhook.startup.sync-timeout for the informers.
+// On failure the informers are stopped again. Watch failures feed the liveness probe only when
+// trackLiveness is set, since restarting this process does not help an unreachable member cluster.
+func syncInformers(clusterID string, factories []informerFactory, informers map[schema.GroupVersionResource]cache.SharedIndexInformer, trackLiveness bool) error {
+	cacheSyncs := make([]cache.InformerSynced, 0, len(informers))
+	for gvr, inf := range informers {
+		resourceName := gvr.Resource
+		if trackLiveness {
+			if err := inf.SetWatchErrorHandlerWithContext(func(ctx context.Context, r *cache.Reflector, err error) {
+				k8sStatus.watchFailed(resourceName, err)
+				cache.DefaultWatchErrorHandler(ctx, r, err)
+			}); err != nil {
+				return fmt.Errorf("set %s watch error handler: %w", resourceName, err)
+			}
+		}
+		cacheSyncs = append(cacheSyncs, inf.HasSynced)
+		informerSynced.WithLabelValues(clusterID, resourceName).Set(0)
+	}
+
+	// stopCh stays open once synced to keep the informers running; a failed attempt closes it.
+	stopCh := make(chan struct{})
+	syncTimeout := global.GVA_CONFIG.Webhook.Startup.SyncTimeout
+	if syncTimeout <= 0 {
+		syncTimeout = defaultSyncTimeout
+	}
+	syncStopCh := make(chan struct{})
+	timer := time.AfterFunc(syncTimeout, func() { close(syncStopCh) })
+
+	for _, factory := range factories {
+		factory.Start(stopCh)
+	}
+	synced := cache.WaitForCacheSync(syncStopCh, cacheSyncs...)
+	timer.Stop()
+	if !synced {
+		close(stopCh)
+		for _, factory := range factories {
+			factory.Shutdown()
+		}
+		return fmt.Errorf("informer caches of cluster %s did not sync within %s", clusterID, syncTimeout)
+	}
+	for gvr := range informers {
+		informerSynced.WithLabelValues(clusterID, gvr.Resource).Set(1)
+	}
+	return nil
+}
+
+// startMemberClusters connects to every configured cluster with its own kubeconfig source in the
+// background. Each one retries independently, so an unreachable member never delays the others.
+// The cluster list is read once at startup.
+func startMemberClusters() {
+	memberClusterStart.Do(func() {
+		for _, cfg := range global.GVA_CONFIG.Webhook.Clusters {
+			if cfg.ID == "" || !hasOwnConnection(cfg.K8s) {
+				continue
+			}
+			cfg := cfg
+			memberClusters.set(&memberCluster{ID: cfg.ID})
+			go func() {
+				maxDelay := global.GVA_CONFIG.Webhook.Startup.RetryMaxDelay
+				if maxDelay <= 0 {
+					maxDelay = defaultRetryMaxDelay
+				}
+				backoff := wait.Backoff{Duration: time.Second, Factor: 2, Jitter: 0.2, Steps: math.MaxInt32, Cap: maxDelay}
+				for {
+					cluster, err := connectMemberCluster(cfg)
+					if err == nil {
+						memberClusters.set(cluster)
+						log.Printf("Member cluster %s caches synced", cfg.ID)
+						return
+					}
+					memberClusters.set(&memberCluster{ID: cfg.ID, lastErr: err})
+					delay := backoff.Step()
+					log.Printf("Member cluster %s initialization failed, its pods are admitted unchanged; retrying in %s: %v", cfg.ID, delay.Round(time.Second), err)
+					time.Sleep(delay)
+				}
+			}()
+		}
+	})
+}
+
+func connectMemberCluster(cfg config.WebhookCluster) (*memberCluster, error) {
+	restConfig, err := restConfigFor(cfg.K8s)
+	if err != nil {
+		return nil, fmt.Errorf("load kubeconfig: %w", err)
+	}
+	client, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("create dynamic client: %w", err)
+	}
+	metadataClient, err := metadata.NewForConfig(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("create metadata client: %w", err)
+	}
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("create clientset: %w", err)
+	}
+	watchVPA, err := servesResource(restConfig, vpaGVR)
+	if err != nil {
+		return nil, err
+	}
+	factory := dynamicinformer.NewDynamicSharedInformerFactory(client, 10*time.Minute)
+	metadataFactory := metadatainformer.NewSharedInformerFactory(metadataClient, 10*time.Minute)
+	ci, err := addClusterInformers(factory, metadataFactory, watchVPA)
+	if err != nil {
+		return nil, err
+	}
+	if err := syncInformers(cfg.ID, []informerFactory{factory, metadataFactory}, ci.informers, false); err != nil {
+		return nil, err
+	}
+	return &memberCluster{
+		ID:             cfg.ID,
+		Client:         client,
+		Resolver:       NewOwnerResolver(client, ci.ownerIndexers),
+		Namespaces:     ci.namespaces,
+		HPAs:           ci.hpas,
+		VPAs:           ci.vpas,
+		LimitRanges:    ci.limitRanges,
+		ResourceQuotas: ci.resourceQuotas,
+		Events:         newEventRecorder(clientset),
+		ready:          true,
+	}, nil
+}
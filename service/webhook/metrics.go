@@ -0,0 +1,105 @@
+package webhook
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Skip reasons explain why a pod or container was admitted without a patch.
+// They are used as metric labels and in logs, so keep them short and stable.
+const (
+	SkipReasonNotReady             = "not_ready"
+	SkipReasonError                = "error"
+	SkipReasonUnknownCluster       = "unknown_cluster"
+	SkipReasonMutationDisabled     = "mutation_disabled"
+	SkipReasonNoWorkload           = "no_workload"
+	SkipReasonNoRecommendation     = "no_recommendation"
+	SkipReasonAutoscaler           = "autoscaler"
+	SkipReasonIneligible           = "ineligible_recommendation"
+	SkipReasonParseError           = "parse_error"
+	SkipReasonNoContainerTarget    = "no_container_target"
+	SkipReasonAlreadyAtTarget      = "already_at_target"
+	SkipReasonDryRun               = "dry_run"
+	SkipReasonLimitRange           = "limit_range"
+	SkipReasonQuotaExceeded        = "quota_exceeded"
+	SkipReasonGuaranteedQoS        = "guaranteed_qos"
+	SkipReasonQoSChange            = "qos_change"
+	DecisionReasonPatched          = "patched"
+	DecisionReasonLimitCapped      = "limit_capped"
+	DecisionReasonGuardrailClamped = "guardrail_clamped"
+	DecisionReasonLimitRangeFitted = "limit_range_fitted"
+)
+
+var (
+	admissionDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "finops_webhook_admission_duration_seconds",
+		Help:    "Time spent handling a pod admission review, by outcome.",
+		Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"outcome"})
+
+	recommendationCacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "finops_webhook_recommendation_cache_total",
+		Help: "Recommendation cache lookups by result (hit, miss, ineligible).",
+	}, []string{"result"})
+
+	podDecisions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "finops_webhook_pod_decisions_total",
+		Help: "Pods admitted by the webhook, by whether they were patched and why not.",
+	}, []string{"decision", "reason"})
+
+	resourceDecisions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "finops_webhook_resource_decisions_total",
+		Help: "Per-container resource decisions (patched, skipped, capped, clamped), by resource.",
+	}, []string{"resource", "decision"})
+
+	recommendationParseErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "finops_webhook_recommendation_parse_errors_total",
+		Help: "Recommendation CRs or quantities that could not be parsed.",
+	})
+
+	informerSynced = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "finops_webhook_informer_synced",
+		Help: "Whether the informer for a resource of a cluster has synced (1) or not (0).",
+	}, []string{"cluster", "resource"})
+
+	k8sInitFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "finops_webhook_k8s_init_failures_total",
+		Help: "Failed attempts to initialize the Kubernetes client and informers.",
+	})
+
+	auditRecordsDropped = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "finops_webhook_audit_records_dropped_total",
+		Help: "Admission audit records dropped because the buffer was full or the database write failed.",
+	})
+
+	leaderGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "finops_webhook_leader",
+		Help: "Whether this replica holds the leader lease (1) or not (0).",
+	})
+
+	controllerApplies = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "finops_controller_applies_total",
+		Help: "Recommendations written to workload templates by the controller, by result (applied, dry_run, error).",
+	}, []string{"result"})
+
+	rolloutsInProgress = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "finops_controller_rollouts_in_progress",
+		Help: "Workloads patched by the controller whose rollout has not finished.",
+	})
+
+	inPlaceResizes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "finops_inplace_resizes_total",
+		Help: "Running pods resized in place, by result (resized, dry_run, error).",
+	}, []string{"result"})
+)
+
+// ObserveAdmission records the latency of one admission review.
+func (s *RecommendationService) ObserveAdmission(outcome string, elapsed time.Duration) {
+	admissionDuration.WithLabelValues(outcome).Observe(elapsed.Seconds())
+}
+
+func recordPodSkipped(reason string) {
+	podDecisions.WithLabelValues("skipped", reason).Inc()
+}
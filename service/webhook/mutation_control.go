@@ -0,0 +1,143 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"main.go/global"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// MutationMode controls whether the webhook patches a pod.
+type MutationMode string
+
+const (
+	MutationEnabled  MutationMode = "enabled"
+	MutationDisabled MutationMode = "disabled"
+	// MutationDryRun computes the patches and reports them in warnings and audit annotations without applying them.
+	MutationDryRun MutationMode = "dry-run"
+)
+
+// MutationAnnotation selects the mutation mode. As a namespace label it overrides webhook.mutation-mode;
+// as an annotation on the pod (template) or on the owning workload it can only narrow the mode to
+// dry-run or disabled, so a workload cannot opt itself into mutation in a namespace that opted out.
+const MutationAnnotation = "finops.io/mutation"
+
+var namespaceGVR = schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}
+
+// mutationModeFor decides the mutation mode for a pod and explains where the decision came from.
+// workloadAnnotations are the annotations of the pod's top-level workload, nil when it has none.
+func mutationModeFor(cluster *memberCluster, pod *corev1.Pod, namespace string, workloadAnnotations map[string]string) (MutationMode, string) {
+	mode, reason := namespaceMutationMode(cluster, namespace)
+	mode, reason = narrowMutationMode(mode, reason, "workload annotation", workloadAnnotations, pod, namespace)
+	return narrowMutationMode(mode, reason, "pod annotation", pod.Annotations, pod, namespace)
+}
+
+// namespaceMutationMode decides the mutation mode from webhook.namespace-selector, webhook.mutation-mode
+// and the namespace label, before any annotation narrows it.
+func namespaceMutationMode(cluster *memberCluster, namespace string) (MutationMode, string) {
+	cfg := global.GVA_CONFIG.Webhook
+
+	nsLabels, err := namespaceLabels(cluster, namespace)
+	if err != nil {
+		// Without the namespace labels the selector cannot be evaluated safely.
+		return MutationDisabled, fmt.Sprintf("namespace %s labels unavailable: %v", namespace, err)
+	}
+
+	if cfg.NamespaceSelector != "" {
+		selector, err := labels.Parse(cfg.NamespaceSelector)
+		if err != nil {
+			return MutationDisabled, fmt.Sprintf("invalid namespace selector %q: %v", cfg.NamespaceSelector, err)
+		}
+		if !selector.Matches(labels.Set(nsLabels)) {
+			return MutationDisabled, fmt.Sprintf("namespace %s does not match selector %q", namespace, cfg.NamespaceSelector)
+		}
+	}
+
+	mode, reason := MutationEnabled, "default"
+	if m, valid := parseMutationMode(cfg.MutationMode); valid {
+		mode, reason = m, "webhook.mutation-mode"
+	}
+	if v, ok := nsLabels[MutationAnnotation]; ok {
+		if m, valid := parseMutationMode(v); valid {
+			mode, reason = m, fmt.Sprintf("namespace label %s=%s", MutationAnnotation, v)
+		} else {
+			log.Printf("Invalid %s label %q on namespace %s, ignoring", MutationAnnotation, v, namespace)
+		}
+	}
+	return mode, reason
+}
+
+// narrowMutationMode applies the MutationAnnotation found in annotations, which can only narrow mode.
+// source names where the annotations came from in the returned reason.
+func narrowMutationMode(mode MutationMode, reason, source string, annotations map[string]string, pod *corev1.Pod, namespace string) (MutationMode, string) {
+	v, ok := annotations[MutationAnnotation]
+	if !ok {
+		return mode, reason
+	}
+	m, valid := parseMutationMode(v)
+	if !valid {
+		log.Printf("Invalid %s %s %q for pod %s in %s, ignoring", MutationAnnotation, source, v, pod.GenerateName, namespace)
+		return mode, reason
+	}
+	if narrower(m, mode) {
+		return m, fmt.Sprintf("%s %s=%s", source, MutationAnnotation, v)
+	}
+	return mode, reason
+}
+
+// mutationModeRank orders the modes by how much they mutate.
+var mutationModeRank = map[MutationMode]int{MutationDisabled: 0, MutationDryRun: 1, MutationEnabled: 2}
+
+// narrower reports whether mode a mutates less than mode b.
+func narrower(a, b MutationMode) bool {
+	return mutationModeRank[a] < mutationModeRank[b]
+}
+
+func parseMutationMode(v string) (MutationMode, bool) {
+	switch mode := MutationMode(v); mode {
+	case MutationEnabled, MutationDisabled, MutationDryRun:
+		return mode, true
+	default:
+		return "", false
+	}
+}
+
+// namespaceLabels reads the namespace labels from the cluster's informer cache, falling back to a live GET.
+// The kubernetes.io/metadata.name label is always present so selectors on the name keep working.
+func namespaceLabels(cluster *memberCluster, namespace string) (map[string]string, error) {
+	if cluster.Namespaces != nil {
+		item, exists, err := cluster.Namespaces.GetByKey(namespace)
+		if err == nil && exists {
+			if u, ok := item.(*unstructured.Unstructured); ok {
+				return withNameLabel(u.GetLabels(), namespace), nil
+			}
+		}
+	}
+
+	if cluster.Client == nil {
+		return withNameLabel(nil, namespace), nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), ownerLookupTimeout)
+	defer cancel()
+	u, err := cluster.Client.Resource(namespaceGVR).Get(ctx, namespace, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return withNameLabel(u.GetLabels(), namespace), nil
+}
+
+func withNameLabel(l map[string]string, namespace string) map[string]string {
+	out := make(map[string]string, len(l)+1)
+	for k, v := range l {
+		out[k] = v
+	}
+	out[corev1.LabelMetadataName] = namespace
+	return out
+}
@@ -0,0 +1,124 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	modelWebhook "main.go/model/webhook"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+const (
+	// OriginalResourcesAnnotation keeps the requests and limits a mutated pod was created with, by
+	// container name, so the developer's values stay visible on the pod.
+	OriginalResourcesAnnotation = "finops.io/original-resources"
+	// RecommendationAnnotation names the Recommendation CR, and the version of it, applied to a pod.
+	RecommendationAnnotation = "finops.io/recommendation"
+)
+
+const (
+	eventComponent = "finops-extend-webhook"
+	// EventReasonRecommendationApplied is the reason of the Event recorded on a workload whose pod
+	// was mutated.
+	EventReasonRecommendationApplied = "RecommendationApplied"
+	// maxEventMessage keeps the Event message within the 1kB the events API accepts.
+	maxEventMessage = 1024
+)
+
+// newEventRecorder returns a recorder that writes Events to the cluster of clientset in the
+// background, so recording never delays an admission. Repeated Events are aggregated by the
+// recorder, which keeps a rollout of many pods to a few Events.
+func newEventRecorder(clientset kubernetes.Interface) record.EventRecorder {
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: clientset.CoreV1().Events("")})
+	return broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: eventComponent})
+}
+
+// recordPatches adds the original resources of the patched containers and the applied Recommendation
+// to the pod's annotations. An existing original-resources annotation is kept, since it is closer to
+// what the developer asked for than the current values.
+func recordPatches(pod *corev1.Pod, rec *cachedRecommendation, plans []*containerPlan) ([]modelWebhook.JSONPatch, error) {
+	annotations := map[string]string{}
+
+	if _, ok := pod.Annotations[OriginalResourcesAnnotation]; !ok {
+		original := make(map[string]corev1.ResourceRequirements, len(plans))
+		for _, plan := range plans {
+			original[plan.Container.Name] = corev1.ResourceRequirements{
+				Requests: plan.Container.Resources.Requests,
+				Limits:   plan.Container.Resources.Limits,
+			}
+		}
+		b, err := json.Marshal(original)
+		if err != nil {
+			return nil, fmt.Errorf("marshal %s: %w", OriginalResourcesAnnotation, err)
+		}
+		annotations[OriginalResourcesAnnotation] = string(b)
+	}
+
+	b, err := json.Marshal(modelWebhook.AppliedRecommendation{
+		Namespace:       rec.Namespace,
+		Name:            rec.Name,
+		ResourceVersion: rec.ResourceVersion,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshal %s: %w", RecommendationAnnotation, err)
+	}
+	annotations[RecommendationAnnotation] = string(b)
+
+	if pod.Annotations == nil {
+		return []modelWebhook.JSONPatch{{Op: "add", Path: "/metadata/annotations", Value: annotations}}, nil
+	}
+	patches := make([]modelWebhook.JSONPatch, 0, len(annotations))
+	for _, key := range []string{OriginalResourcesAnnotation, RecommendationAnnotation} {
+		if value, ok := annotations[key]; ok {
+			patches = append(patches, modelWebhook.JSONPatch{Op: "add", Path: "/metadata/annotations/" + escapeJSONPointer(key), Value: value})
+		}
+	}
+	return patches, nil
+}
+
+// recordMutationEvent records an Event on the workload owning a mutated pod, so kubectl describe
+// on the workload shows the change.
+func recordMutationEvent(cluster *memberCluster, namespace string, workload modelWebhook.WorkloadRef, pod *corev1.Pod, rec *cachedRecommendation, plans []*containerPlan) {
+	if cluster.Events == nil {
+		return
+	}
+	changes := make([]string, 0, len(plans))
+	for _, plan := range plans {
+		change := fmt.Sprintf("%s: requests [%s]", plan.Container.Name, formatResources(plan.Requests))
+		if len(plan.Limits) > 0 {
+			change += fmt.Sprintf(", limits [%s]", formatResources(plan.Limits))
+		}
+		if len(plan.RemoveLimits) > 0 {
+			change += fmt.Sprintf(", removed limits %v", plan.RemoveLimits)
+		}
+		changes = append(changes, change)
+	}
+	message := fmt.Sprintf("Applied Recommendation %s/%s (resourceVersion %s) to pod %s: %s",
+		rec.Namespace, rec.Name, rec.ResourceVersion, podName(pod), strings.Join(changes, "; "))
+	if len(message) > maxEventMessage {
+		message = message[:maxEventMessage-3] + "..."
+	}
+	ref := &corev1.ObjectReference{
+		APIVersion: workload.APIVersion,
+		Kind:       workload.Kind,
+		Namespace:  namespace,
+		Name:       workload.Name,
+		UID:        workload.UID,
+	}
+	cluster.Events.Event(ref, corev1.EventTypeNormal, EventReasonRecommendationApplied, message)
+}
+
+// podName returns the pod's name, or its generateName while the API server has not named it yet.
+func podName(pod *corev1.Pod) string {
+	if pod.Name != "" {
+		return pod.Name
+	}
+	return pod.GenerateName
+}
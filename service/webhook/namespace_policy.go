@@ -0,0 +1,337 @@
+package webhook
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/cache"
+)
+
+// quotaLimitsPrefix prefixes the quota keys that count container limits, e.g. limits.cpu.
+const quotaLimitsPrefix = "limits."
+
+var (
+	limitRangeGVR    = schema.GroupVersionResource{Version: "v1", Resource: "limitranges"}
+	resourceQuotaGVR = schema.GroupVersionResource{Version: "v1", Resource: "resourcequotas"}
+)
+
+// fitNamespacePolicies adjusts the plans so the patched pod passes the LimitRanges of its namespace
+// and stays within the remaining ResourceQuota; otherwise the API server would reject the pod and the
+// rollout would stall. Requests are moved into a LimitRange's bounds where possible and a resource's
+// changes are dropped where not. The returned notes explain each adjustment.
+//
+// The LimitRanger defaults are applied before mutating webhooks run, so the pod already carries them.
+// running is set for the in-place resize of a pod that the quota already counts.
+func fitNamespacePolicies(cluster *memberCluster, pod *corev1.Pod, namespace string, plans []*containerPlan, running bool) []string {
+	var notes []string
+	for _, lr := range limitRangesIn(cluster, namespace) {
+		for _, item := range lr.Spec.Limits {
+			switch item.Type {
+			case corev1.LimitTypeContainer:
+				for _, plan := range plans {
+					notes = append(notes, fitContainerLimitRange(lr.Name, item, plan)...)
+				}
+			case corev1.LimitTypePod:
+				notes = append(notes, fitPodLimitRange(lr.Name, item, pod, plans)...)
+			}
+		}
+	}
+	for _, quota := range resourceQuotasIn(cluster, namespace) {
+		if quotaMatchesPod(quota, pod) {
+			notes = append(notes, fitResourceQuota(quota, pod, plans, running)...)
+		}
+	}
+	return notes
+}
+
+// fitContainerLimitRange applies the min, max and maxLimitRequestRatio of a Container LimitRange item
+// to the resources whose request the plan changes.
+func fitContainerLimitRange(lrName string, item corev1.LimitRangeItem, plan *containerPlan) []string {
+	var notes []string
+	name := plan.Container.Name
+	for _, res := range sortedResourceNames(plan.Requests) {
+		after := plan.Container.DeepCopy()
+		plan.applyTo(after)
+		req := after.Resources.Requests[res]
+		limit, hasLimit := after.Resources.Limits[res]
+
+		if max, ok := item.Max[res]; ok {
+			if !hasLimit {
+				// A LimitRange max requires a limit, so the limit cannot be removed.
+				plan.revert(res, SkipReasonLimitRange)
+				notes = append(notes, fmt.Sprintf("limitrange: container %s: %s left unchanged, LimitRange %s max %s requires a limit", name, res, lrName, max.String()))
+				resourceDecisions.WithLabelValues(string(res), SkipReasonLimitRange).Inc()
+				continue
+			}
+			if _, planned := plan.Limits[res]; planned && limit.Cmp(max) > 0 {
+				plan.Limits[res] = max.DeepCopy()
+				limit = max
+				notes = append(notes, fmt.Sprintf("limitrange: container %s: %s limit capped at LimitRange %s max %s", name, res, lrName, max.String()))
+				resourceDecisions.WithLabelValues(string(res), DecisionReasonLimitRangeFitted).Inc()
+			}
+			if req.Cmp(max) > 0 {
+				plan.Requests[res] = max.DeepCopy()
+				req = max
+				notes = append(notes, fmt.Sprintf("limitrange: container %s: %s request capped at LimitRange %s max %s", name, res, lrName, max.String()))
+				resourceDecisions.WithLabelValues(string(res), DecisionReasonLimitRangeFitted).Inc()
+			}
+		}
+		if min, ok := item.Min[res]; ok && req.Cmp(min) < 0 {
+			if hasLimit && limit.Cmp(min) < 0 {
+				plan.revert(res, SkipReasonLimitRange)
+				notes = append(notes, fmt.Sprintf("limitrange: container %s: %s left unchanged, limit %s is below LimitRange %s min %s", name, res, limit.String(), lrName, min.String()))
+				resourceDecisions.WithLabelValues(string(res), SkipReasonLimitRange).Inc()
+				continue
+			}
+			plan.Requests[res] = min.DeepCopy()
+			req = min
+			notes = append(notes, fmt.Sprintf("limitrange: container %s: %s request raised to LimitRange %s min %s", name, res, lrName, min.String()))
+			resourceDecisions.WithLabelValues(string(res), DecisionReasonLimitRangeFitted).Inc()
+		}
+		if ratio, ok := item.MaxLimitRequestRatio[res]; ok && hasLimit && !ratio.IsZero() {
+			// limit / request must not exceed the ratio, so the request may not drop below limit / ratio.
+			minMilli := int64(math.Ceil(float64(limit.MilliValue()) / ratio.AsApproximateFloat64()))
+			if req.MilliValue() < minMilli {
+				raised := resource.NewMilliQuantity(minMilli, req.Format)
+				plan.Requests[res] = *raised
+				notes = append(notes, fmt.Sprintf("limitrange: container %s: %s request raised to %s for LimitRange %s maxLimitRequestRatio %s", name, res, raised.String(), lrName, ratio.String()))
+				resourceDecisions.WithLabelValues(string(res), DecisionReasonLimitRangeFitted).Inc()
+			}
+		}
+	}
+	return notes
+}
+
+// fitPodLimitRange drops the changes of a resource when they move the pod's total request or limit
+// out of a Pod LimitRange item. Changes towards the bounds are kept.
+func fitPodLimitRange(lrName string, item corev1.LimitRangeItem, pod *corev1.Pod, plans []*containerPlan) []string {
+	var notes []string
+	for _, res := range plannedResources(plans) {
+		patched := applyPlans(pod, plans)
+		checks := []struct {
+			kind          string
+			before, after resource.Quantity
+		}{
+			{"request", podEffectiveRequests(pod)[res], podEffectiveRequests(patched)[res]},
+			{"limit", podEffectiveLimits(pod)[res], podEffectiveLimits(patched)[res]},
+		}
+		for _, c := range checks {
+			violation := ""
+			if max, ok := item.Max[res]; ok && c.after.Cmp(max) > 0 && c.after.Cmp(c.before) > 0 {
+				violation = "max " + max.String()
+			}
+			if min, ok := item.Min[res]; ok && c.after.Cmp(min) < 0 && c.after.Cmp(c.before) < 0 {
+				violation = "min " + min.String()
+			}
+			if violation == "" {
+				continue
+			}
+			revertResource(plans, res, SkipReasonLimitRange)
+			notes = append(notes, fmt.Sprintf("limitrange: %s left unchanged, pod %s %s would violate LimitRange %s %s", res, c.kind, c.after.String(), lrName, violation))
+			resourceDecisions.WithLabelValues(string(res), SkipReasonLimitRange).Inc()
+			break
+		}
+	}
+	return notes
+}
+
+// fitResourceQuota drops the changes of a resource that raise the pod's request or limit beyond what
+// is left of a quota, or that remove a limit the quota requires. A new pod needs its whole request
+// left in the quota; a running pod is already in status.used, so its resize only needs the increase.
+func fitResourceQuota(quota corev1.ResourceQuota, pod *corev1.Pod, plans []*containerPlan, running bool) []string {
+	hard := quota.Status.Hard
+	if len(hard) == 0 {
+		hard = quota.Spec.Hard
+	}
+	var notes []string
+	for _, key := range sortedResourceNames(hard) {
+		res, isLimit := quotaResource(key)
+		if res == "" {
+			continue
+		}
+		if isLimit && planRemovesLimit(plans, res) {
+			revertResource(plans, res, SkipReasonQuotaExceeded)
+			notes = append(notes, fmt.Sprintf("resourcequota: %s left unchanged, ResourceQuota %s on %s requires a limit", res, quota.Name, key))
+			resourceDecisions.WithLabelValues(string(res), SkipReasonQuotaExceeded).Inc()
+			continue
+		}
+
+		effective := podEffectiveRequests
+		if isLimit {
+			effective = podEffectiveLimits
+		}
+		before := effective(pod)[res]
+		after := effective(applyPlans(pod, plans))[res]
+		if after.Cmp(before) <= 0 {
+			continue
+		}
+		remaining := hard[key].DeepCopy()
+		remaining.Sub(quota.Status.Used[key])
+		needed, what := after, key.String()
+		if running {
+			needed = after.DeepCopy()
+			needed.Sub(before)
+			what += " increase"
+		}
+		if needed.Cmp(remaining) <= 0 {
+			continue
+		}
+		revertResource(plans, res, SkipReasonQuotaExceeded)
+		notes = append(notes, fmt.Sprintf("resourcequota: %s left unchanged, %s %s exceeds the %s left in ResourceQuota %s", res, what, needed.String(), remaining.String(), quota.Name))
+		resourceDecisions.WithLabelValues(string(res), SkipReasonQuotaExceeded).Inc()
+	}
+	return notes
+}
+
+// quotaResource maps a quota key to the resource it counts and whether it counts limits. Keys that
+// are not about container requests or limits, such as object counts, give "".
+func quotaResource(key corev1.ResourceName) (corev1.ResourceName, bool) {
+	switch s := string(key); {
+	case key == corev1.ResourceCPU || key == corev1.ResourceMemory || key == corev1.ResourceEphemeralStorage:
+		return key, false
+	case strings.HasPrefix(s, corev1.DefaultResourceRequestsPrefix):
+		return corev1.ResourceName(strings.TrimPrefix(s, corev1.DefaultResourceRequestsPrefix)), false
+	case strings.HasPrefix(s, quotaLimitsPrefix):
+		return corev1.ResourceName(strings.TrimPrefix(s, quotaLimitsPrefix)), true
+	}
+	return "", false
+}
+
+// quotaMatchesPod evaluates the quota scopes that depend on the pod. Scopes it cannot evaluate are
+// assumed to match, which errs on the side of keeping the pod admissible.
+func quotaMatchesPod(quota corev1.ResourceQuota, pod *corev1.Pod) bool {
+	for _, scope := range quota.Spec.Scopes {
+		if !quotaScopeMatches(scope, corev1.ScopeSelectorOpExists, nil, pod) {
+			return false
+		}
+	}
+	if quota.Spec.ScopeSelector != nil {
+		for _, req := range quota.Spec.ScopeSelector.MatchExpressions {
+			if !quotaScopeMatches(req.ScopeName, req.Operator, req.Values, pod) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func quotaScopeMatches(scope corev1.ResourceQuotaScope, op corev1.ScopeSelectorOperator, values []string, pod *corev1.Pod) bool {
+	switch scope {
+	case corev1.ResourceQuotaScopeTerminating:
+		return pod.Spec.ActiveDeadlineSeconds != nil
+	case corev1.ResourceQuotaScopeNotTerminating:
+		return pod.Spec.ActiveDeadlineSeconds == nil
+	case corev1.ResourceQuotaScopeBestEffort:
+		return qosClass(pod) == corev1.PodQOSBestEffort
+	case corev1.ResourceQuotaScopeNotBestEffort:
+		return qosClass(pod) != corev1.PodQOSBestEffort
+	case corev1.ResourceQuotaScopePriorityClass:
+		switch op {
+		case corev1.ScopeSelectorOpExists:
+			return pod.Spec.PriorityClassName != ""
+		case corev1.ScopeSelectorOpDoesNotExist:
+			return pod.Spec.PriorityClassName == ""
+		case corev1.ScopeSelectorOpIn:
+			return containsString(values, pod.Spec.PriorityClassName)
+		case corev1.ScopeSelectorOpNotIn:
+			return !containsString(values, pod.Spec.PriorityClassName)
+		}
+	}
+	return true
+}
+
+// podEffectiveLimits is podEffectiveRequests for limits.
+func podEffectiveLimits(pod *corev1.Pod) corev1.ResourceList {
+	limits := pod.DeepCopy()
+	for _, ref := range mutableContainers(limits) {
+		ref.Container.Resources.Requests = ref.Container.Resources.Limits
+	}
+	return podEffectiveRequests(limits)
+}
+
+// plannedResources lists the resources any plan changes.
+func plannedResources(plans []*containerPlan) []corev1.ResourceName {
+	all := corev1.ResourceList{}
+	for _, plan := range plans {
+		for name, q := range plan.Requests {
+			all[name] = q
+		}
+		for name, q := range plan.Limits {
+			all[name] = q
+		}
+		for _, name := range plan.RemoveLimits {
+			all[name] = resource.Quantity{}
+		}
+	}
+	return sortedResourceNames(all)
+}
+
+func planRemovesLimit(plans []*containerPlan, name corev1.ResourceName) bool {
+	for _, plan := range plans {
+		if plan.removesLimit(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func revertResource(plans []*containerPlan, name corev1.ResourceName, reason string) {
+	for _, plan := range plans {
+		plan.revert(name, reason)
+	}
+}
+
+// limitRangesIn reads the LimitRanges of a namespace from the cluster's informer cache.
+func limitRangesIn(cluster *memberCluster, namespace string) []corev1.LimitRange {
+	var out []corev1.LimitRange
+	for _, u := range namespacedObjects(cluster.LimitRanges, namespace) {
+		var lr corev1.LimitRange
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, &lr); err != nil {
+			log.Printf("Failed to convert LimitRange %s/%s: %v", namespace, u.GetName(), err)
+			continue
+		}
+		out = append(out, lr)
+	}
+	return out
+}
+
+// resourceQuotasIn reads the ResourceQuotas of a namespace from the cluster's informer cache.
+func resourceQuotasIn(cluster *memberCluster, namespace string) []corev1.ResourceQuota {
+	var out []corev1.ResourceQuota
+	for _, u := range namespacedObjects(cluster.ResourceQuotas, namespace) {
+		var quota corev1.ResourceQuota
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, &quota); err != nil {
+			log.Printf("Failed to convert ResourceQuota %s/%s: %v", namespace, u.GetName(), err)
+			continue
+		}
+		out = append(out, quota)
+	}
+	return out
+}
+
+// namespacedObjects returns the objects of one namespace ordered by name; nil indexers hold nothing.
+func namespacedObjects(indexer cache.Indexer, namespace string) []*unstructured.Unstructured {
+	if indexer == nil {
+		return nil
+	}
+	items, err := indexer.ByIndex(cache.NamespaceIndex, namespace)
+	if err != nil {
+		return nil
+	}
+	out := make([]*unstructured.Unstructured, 0, len(items))
+	for _, item := range items {
+		if u, ok := item.(*unstructured.Unstructured); ok {
+			out = append(out, u)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].GetName() < out[j].GetName() })
+	return out
+}
@@ -0,0 +1,178 @@
+package webhook
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	modelWebhook "main.go/model/webhook"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/cache"
+)
+
+// maxOwnerDepth bounds the ownerReferences walk so a malformed owner chain can never loop forever.
+const maxOwnerDepth = 5
+
+// ownerLookupTimeout bounds the live GET used when an intermediate owner is not in the informer cache yet.
+const ownerLookupTimeout = 2 * time.Second
+
+// intermediateOwnerGVRs lists the owner kinds that are never recommendation targets themselves,
+// so the walk continues to their controller. Every other kind (Deployment, StatefulSet, DaemonSet,
+// CronJob, Argo Rollout, OpenKruise CloneSet, ...) is treated as the top-level workload.
+var intermediateOwnerGVRs = map[schema.GroupKind]schema.GroupVersionResource{
+	{Group: "apps", Kind: "ReplicaSet"}: {Group: "apps", Version: "v1", Resource: "replicasets"},
+	{Group: "batch", Kind: "Job"}:       {Group: "batch", Version: "v1", Resource: "jobs"},
+}
+
+// workloadMetadataGVRs are the top-level workload kinds whose metadata is cached for WorkloadAnnotations.
+// ReplicaSets and Jobs are already cached in full as intermediate owners.
+var workloadMetadataGVRs = []schema.GroupVersionResource{
+	{Group: "apps", Version: "v1", Resource: "deployments"},
+	{Group: "apps", Version: "v1", Resource: "statefulsets"},
+	{Group: "apps", Version: "v1", Resource: "daemonsets"},
+	{Group: "batch", Version: "v1", Resource: "cronjobs"},
+}
+
+// OwnerResolver walks ownerReferences from a pod up to its top-level workload.
+// Intermediate owners are read from informer caches and fall back to the dynamic client on a cache miss.
+// The indexers hold full objects, or only the metadata for the kinds in workloadMetadataGVRs.
+type OwnerResolver struct {
+	client   dynamic.Interface
+	indexers map[schema.GroupVersionResource]cache.Indexer
+}
+
+func NewOwnerResolver(client dynamic.Interface, indexers map[schema.GroupVersionResource]cache.Indexer) *OwnerResolver {
+	return &OwnerResolver{client: client, indexers: indexers}
+}
+
+// Resolve returns the top-level workload owning an object with the given ownerReferences.
+// An empty WorkloadRef is returned when the object has no owner.
+func (r *OwnerResolver) Resolve(namespace string, owners []metav1.OwnerReference) modelWebhook.WorkloadRef {
+	owner := controllerOf(owners)
+	if owner == nil {
+		return modelWebhook.WorkloadRef{}
+	}
+
+	for depth := 0; depth < maxOwnerDepth; depth++ {
+		current := modelWebhook.WorkloadRef{APIVersion: owner.APIVersion, Kind: owner.Kind, Name: owner.Name, UID: owner.UID}
+
+		gv, err := schema.ParseGroupVersion(owner.APIVersion)
+		if err != nil {
+			return current
+		}
+		gvr, ok := intermediateOwnerGVRs[schema.GroupKind{Group: gv.Group, Kind: owner.Kind}]
+		if !ok {
+			return current
+		}
+
+		obj := r.get(gvr, namespace, owner.Name)
+		if obj == nil {
+			// The intermediate owner is gone or unreachable, so it is the best answer we have.
+			return current
+		}
+		next := controllerOf(obj.GetOwnerReferences())
+		if next == nil {
+			// A bare ReplicaSet or Job is its own top-level workload.
+			return current
+		}
+		owner = next
+	}
+
+	log.Printf("Owner chain deeper than %d for %s/%s, stopping at %s %s", maxOwnerDepth, namespace, owner.Name, owner.Kind, owner.Name)
+	return modelWebhook.WorkloadRef{APIVersion: owner.APIVersion, Kind: owner.Kind, Name: owner.Name, UID: owner.UID}
+}
+
+// workloadGVRs maps the common top-level workload kinds to their resources. Other kinds fall back to
+// the lower-cased plural of the kind, which holds for Argo Rollouts and OpenKruise CloneSets.
+var workloadGVRs = map[schema.GroupKind]string{
+	{Group: "apps", Kind: "Deployment"}:  "deployments",
+	{Group: "apps", Kind: "StatefulSet"}: "statefulsets",
+	{Group: "apps", Kind: "DaemonSet"}:   "daemonsets",
+	{Group: "apps", Kind: "ReplicaSet"}:  "replicasets",
+	{Group: "batch", Kind: "Job"}:        "jobs",
+	{Group: "batch", Kind: "CronJob"}:    "cronjobs",
+}
+
+// WorkloadAnnotations returns the annotations of a top-level workload returned by Resolve from the
+// informer caches only, so admission never waits on the API server for them. Kinds outside
+// workloadGVRs, e.g. the Node of a static pod, and workloads not cached yet have none.
+func (r *OwnerResolver) WorkloadAnnotations(namespace string, ref modelWebhook.WorkloadRef) map[string]string {
+	gv, err := schema.ParseGroupVersion(ref.APIVersion)
+	if err != nil || ref.Name == "" {
+		return nil
+	}
+	resource, ok := workloadGVRs[schema.GroupKind{Group: gv.Group, Kind: ref.Kind}]
+	if !ok {
+		return nil
+	}
+	indexer, ok := r.indexers[gv.WithResource(resource)]
+	if !ok || indexer == nil {
+		return nil
+	}
+	item, exists, err := indexer.GetByKey(namespace + "/" + ref.Name)
+	if err != nil || !exists {
+		return nil
+	}
+	obj, err := meta.Accessor(item)
+	if err != nil {
+		return nil
+	}
+	return obj.GetAnnotations()
+}
+
+// Workload reads a top-level workload returned by Resolve, or nil when it cannot be read.
+func (r *OwnerResolver) Workload(namespace string, ref modelWebhook.WorkloadRef) *unstructured.Unstructured {
+	gv, err := schema.ParseGroupVersion(ref.APIVersion)
+	if err != nil || ref.Kind == "" || ref.Name == "" {
+		return nil
+	}
+	resource, ok := workloadGVRs[schema.GroupKind{Group: gv.Group, Kind: ref.Kind}]
+	if !ok {
+		resource = strings.ToLower(ref.Kind) + "s"
+	}
+	return r.get(gv.WithResource(resource), namespace, ref.Name)
+}
+
+// get reads an owner from its informer cache, falling back to a live GET on a miss or when only the
+// metadata is cached. Pods are often admitted before the informer has observed a freshly created ReplicaSet.
+func (r *OwnerResolver) get(gvr schema.GroupVersionResource, namespace, name string) *unstructured.Unstructured {
+	if indexer, ok := r.indexers[gvr]; ok && indexer != nil {
+		item, exists, err := indexer.GetByKey(namespace + "/" + name)
+		if err == nil && exists {
+			if u, ok := item.(*unstructured.Unstructured); ok {
+				return u
+			}
+		}
+	}
+
+	if r.client == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), ownerLookupTimeout)
+	defer cancel()
+	u, err := r.client.Resource(gvr).Namespace(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		log.Printf("Failed to get %s %s/%s: %v", gvr.Resource, namespace, name, err)
+		return nil
+	}
+	return u
+}
+
+// controllerOf returns the managing controller reference, or the first owner when none is marked as controller.
+func controllerOf(owners []metav1.OwnerReference) *metav1.OwnerReference {
+	for i := range owners {
+		if owners[i].Controller != nil && *owners[i].Controller {
+			return &owners[i]
+		}
+	}
+	if len(owners) > 0 {
+		return &owners[0]
+	}
+	return nil
+}
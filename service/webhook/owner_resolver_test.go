@@ -0,0 +1,46 @@
+package webhook
+
+import (
+	"testing"
+
+	modelWebhook "main.go/model/webhook"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestWorkloadAnnotationsCacheOnly(t *testing.T) {
+	deployments := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	if err := deployments.Add(&metav1.PartialObjectMetadata{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "shop", Annotations: map[string]string{MutationAnnotation: "disabled"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+	resolver := NewOwnerResolver(client, map[schema.GroupVersionResource]cache.Indexer{workloadMetadataGVRs[0]: deployments})
+
+	for _, tc := range []struct {
+		name string
+		ref  modelWebhook.WorkloadRef
+		want string
+	}{
+		{"cached deployment", modelWebhook.WorkloadRef{APIVersion: "apps/v1", Kind: "Deployment", Name: "web"}, "disabled"},
+		{"deployment not cached yet", modelWebhook.WorkloadRef{APIVersion: "apps/v1", Kind: "Deployment", Name: "api"}, ""},
+		{"statefulset without cache", modelWebhook.WorkloadRef{APIVersion: "apps/v1", Kind: "StatefulSet", Name: "web"}, ""},
+		{"static pod owned by a node", modelWebhook.WorkloadRef{APIVersion: "v1", Kind: "Node", Name: "node-1"}, ""},
+		{"unknown kind", modelWebhook.WorkloadRef{APIVersion: "argoproj.io/v1alpha1", Kind: "Rollout", Name: "web"}, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := resolver.WorkloadAnnotations("shop", tc.ref)[MutationAnnotation]; got != tc.want {
+				t.Errorf("%s = %q, want %q", MutationAnnotation, got, tc.want)
+			}
+		})
+	}
+	if actions := client.Actions(); len(actions) != 0 {
+		t.Errorf("WorkloadAnnotations called the API server: %v", actions)
+	}
+}
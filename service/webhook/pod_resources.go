@@ -0,0 +1,174 @@
+package webhook
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// containerRef points at a container the webhook may resize together with its JSON pointer.
+type containerRef struct {
+	Path      string
+	Container *corev1.Container
+	Init      bool
+	// Sidecar marks a native sidecar: an init container with restartPolicy Always.
+	Sidecar bool
+}
+
+// kindLabel describes the container type for logs.
+func (c containerRef) kindLabel() string {
+	switch {
+	case c.Sidecar:
+		return "sidecar"
+	case c.Init:
+		return "init"
+	default:
+		return "container"
+	}
+}
+
+// mutableContainers lists init containers (including native sidecars) followed by regular containers.
+// Ephemeral containers are left out on purpose: the API server rejects resources on them and they are
+// only ever added through the ephemeralcontainers subresource, never on pod CREATE.
+func mutableContainers(pod *corev1.Pod) []containerRef {
+	refs := make([]containerRef, 0, len(pod.Spec.InitContainers)+len(pod.Spec.Containers))
+	for i := range pod.Spec.InitContainers {
+		c := &pod.Spec.InitContainers[i]
+		refs = append(refs, containerRef{
+			Path:      fmt.Sprintf("/spec/initContainers/%d", i),
+			Container: c,
+			Init:      true,
+			Sidecar:   isSidecar(c),
+		})
+	}
+	for i := range pod.Spec.Containers {
+		refs = append(refs, containerRef{
+			Path:      fmt.Sprintf("/spec/containers/%d", i),
+			Container: &pod.Spec.Containers[i],
+		})
+	}
+	return refs
+}
+
+func isSidecar(c *corev1.Container) bool {
+	return c.RestartPolicy != nil && *c.RestartPolicy == corev1.ContainerRestartPolicyAlways
+}
+
+// podEffectiveRequests computes the pod's effective request the way the scheduler does:
+// the larger of the steady state (all containers plus all sidecars) and the peak of the
+// init phase, where each regular init container runs next to the sidecars started before it.
+// Pod overhead is added on top.
+func podEffectiveRequests(pod *corev1.Pod) corev1.ResourceList {
+	steady := corev1.ResourceList{}
+	for _, c := range pod.Spec.Containers {
+		addResourceList(steady, c.Resources.Requests)
+	}
+
+	initPeak := corev1.ResourceList{}
+	sidecars := corev1.ResourceList{}
+	for i := range pod.Spec.InitContainers {
+		c := &pod.Spec.InitContainers[i]
+		if isSidecar(c) {
+			addResourceList(sidecars, c.Resources.Requests)
+			maxResourceList(initPeak, sidecars)
+			continue
+		}
+		running := sidecars.DeepCopy()
+		addResourceList(running, c.Resources.Requests)
+		maxResourceList(initPeak, running)
+	}
+	addResourceList(steady, sidecars)
+
+	effective := steady
+	maxResourceList(effective, initPeak)
+	addResourceList(effective, pod.Spec.Overhead)
+	return effective
+}
+
+// addResourceList adds every quantity of src into dst.
+func addResourceList(dst, src corev1.ResourceList) {
+	for name, q := range src {
+		if cur, ok := dst[name]; ok {
+			cur.Add(q)
+			dst[name] = cur
+		} else {
+			dst[name] = q.DeepCopy()
+		}
+	}
+}
+
+// maxResourceList raises every quantity of dst to at least the one in src.
+func maxResourceList(dst, src corev1.ResourceList) {
+	for name, q := range src {
+		if cur, ok := dst[name]; !ok || q.Cmp(cur) > 0 {
+			dst[name] = q.DeepCopy()
+		}
+	}
+}
+
+// qosClass computes the QoS class of a pod from its cpu and memory the way the kubelet does. The
+// API server rejects an in-place resize that would change it.
+func qosClass(pod *corev1.Pod) corev1.PodQOSClass {
+	requests := corev1.ResourceList{}
+	limits := corev1.ResourceList{}
+	guaranteed := true
+	containers := append(append([]corev1.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...)
+	for _, c := range containers {
+		limitsFound := 0
+		for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+			if q, ok := c.Resources.Requests[name]; ok && !q.IsZero() {
+				addResourceList(requests, corev1.ResourceList{name: q})
+			}
+			if q, ok := c.Resources.Limits[name]; ok && !q.IsZero() {
+				addResourceList(limits, corev1.ResourceList{name: q})
+				limitsFound++
+			}
+		}
+		if limitsFound < 2 {
+			guaranteed = false
+		}
+	}
+	if len(requests) == 0 && len(limits) == 0 {
+		return corev1.PodQOSBestEffort
+	}
+	if guaranteed {
+		for name, req := range requests {
+			if lim, ok := limits[name]; !ok || lim.Cmp(req) != 0 {
+				guaranteed = false
+				break
+			}
+		}
+	}
+	if guaranteed && len(requests) == len(limits) {
+		return corev1.PodQOSGuaranteed
+	}
+	return corev1.PodQOSBurstable
+}
+
+// containerResourceSupported reports whether a container may request the resource: cpu, memory,
+// ephemeral-storage, hugepages-<size> and extended resources such as nvidia.com/gpu.
+func containerResourceSupported(name corev1.ResourceName) bool {
+	switch name {
+	case corev1.ResourceCPU, corev1.ResourceMemory, corev1.ResourceEphemeralStorage:
+		return true
+	}
+	if strings.HasPrefix(string(name), corev1.ResourceHugePagesPrefix) {
+		return true
+	}
+	return !isNativeResource(name) && !strings.HasPrefix(string(name), corev1.DefaultResourceRequestsPrefix) &&
+		len(validation.IsQualifiedName(string(name))) == 0
+}
+
+// overcommitAllowed reports whether a container may request less than its limit. The API server
+// requires request == limit for hugepages and extended resources.
+func overcommitAllowed(name corev1.ResourceName) bool {
+	return isNativeResource(name) && !strings.HasPrefix(string(name), corev1.ResourceHugePagesPrefix)
+}
+
+// isNativeResource reports whether the resource belongs to Kubernetes itself: unprefixed names and
+// names under kubernetes.io/.
+func isNativeResource(name corev1.ResourceName) bool {
+	return !strings.Contains(string(name), "/") || strings.Contains(string(name), corev1.ResourceDefaultNamespacePrefix)
+}
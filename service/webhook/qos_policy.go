@@ -0,0 +1,78 @@
+package webhook
+
+import (
+	"log"
+
+	"main.go/global"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// QoSPolicy decides what happens to a pod whose requests equal its limits (QoS class Guaranteed).
+// Patching only the requests would make it Burstable, which changes its eviction priority and
+// takes it off the static CPU manager's exclusive cores. Burstable and BestEffort pods are patched
+// as usual under every policy.
+type QoSPolicy string
+
+const (
+	// QoSPolicyPreserve sets the limits to the recommended requests so the pod stays Guaranteed.
+	QoSPolicyPreserve QoSPolicy = "preserve"
+	// QoSPolicySkip admits Guaranteed pods unchanged.
+	QoSPolicySkip QoSPolicy = "skip"
+	// QoSPolicyIgnore applies the limit policy regardless of the QoS class.
+	QoSPolicyIgnore QoSPolicy = "ignore"
+)
+
+// QoSPolicyAnnotation overrides the configured QoS policy for a workload. Like the limit policy
+// annotation it is read from the owning workload and from the pod (template), where the pod's value wins.
+const QoSPolicyAnnotation = "finops.io/qos-policy"
+
+// qosSettings is the pod's QoS class before any patch and the policy that protects it.
+type qosSettings struct {
+	Policy QoSPolicy
+	Class  corev1.PodQOSClass
+}
+
+// qosSettingsFor merges the global webhook config with the workload and pod annotations.
+func qosSettingsFor(pod *corev1.Pod, workloadAnnotations map[string]string) qosSettings {
+	policy := parseQoSPolicy(global.GVA_CONFIG.Webhook.QoSPolicy)
+	if v, ok := workloadSetting(pod, workloadAnnotations, QoSPolicyAnnotation); ok {
+		policy = parseQoSPolicy(v)
+	}
+	return qosSettings{Policy: policy, Class: qosClass(pod)}
+}
+
+func parseQoSPolicy(v string) QoSPolicy {
+	switch p := QoSPolicy(v); p {
+	case QoSPolicyPreserve, QoSPolicySkip, QoSPolicyIgnore:
+		return p
+	case "":
+		return QoSPolicyPreserve
+	default:
+		log.Printf("Unknown QoS policy %q, falling back to %s", v, QoSPolicyPreserve)
+		return QoSPolicyPreserve
+	}
+}
+
+// skips reports whether the pod is left unchanged before anything is planned.
+func (q qosSettings) skips() bool {
+	return q.Class == corev1.PodQOSGuaranteed && q.Policy == QoSPolicySkip
+}
+
+// matchLimits reports whether limits must follow the recommended requests.
+func (q qosSettings) matchLimits() bool {
+	return q.Class == corev1.PodQOSGuaranteed && q.Policy == QoSPolicyPreserve
+}
+
+// changedBy returns the QoS class the plans would move a protected pod to, or "" when the class is
+// kept or not protected. A guardrail, LimitRange or quota adjustment to one resource can still
+// leave a request and its limit apart after the limits were matched.
+func (q qosSettings) changedBy(pod *corev1.Pod, plans []*containerPlan) corev1.PodQOSClass {
+	if q.Class != corev1.PodQOSGuaranteed || q.Policy == QoSPolicyIgnore || len(plans) == 0 {
+		return ""
+	}
+	if after := qosClass(applyPlans(pod, plans)); after != q.Class {
+		return after
+	}
+	return ""
+}
@@ -0,0 +1,200 @@
+package webhook
+
+import (
+	"encoding/json"
+	"testing"
+
+	"main.go/config"
+
+	jsonpatch "gopkg.in/evanphx/json-patch.v4"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const qosCluster = "cluster-a"
+
+var qosPodRequirements = map[corev1.PodQOSClass]corev1.ResourceRequirements{
+	corev1.PodQOSGuaranteed: {Requests: resources("cpu", "2", "memory", "2Gi"), Limits: resources("cpu", "2", "memory", "2Gi")},
+	corev1.PodQOSBurstable:  {Requests: resources("cpu", "2", "memory", "2Gi"), Limits: resources("cpu", "4", "memory", "4Gi")},
+	corev1.PodQOSBestEffort: {},
+}
+
+// loadQoSCluster loads Deployment app-0's recommendation (app: cpu 1265m, memory 1150Mi) and the
+// given namespace objects offline, restoring the previous caches when the test ends.
+func loadQoSCluster(t *testing.T, qosPolicy string, objects ...*unstructured.Unstructured) {
+	t.Helper()
+	withWebhookConfig(t, func(cfg *config.Webhook) {
+		cfg.Clusters = []config.WebhookCluster{{ID: qosCluster}}
+		cfg.QoSPolicy = qosPolicy
+	})
+	savedCache, savedClusters := recommendationCache, memberClusters
+	t.Cleanup(func() { recommendationCache, memberClusters = savedCache, savedClusters })
+	memberClusters = &clusterRegistry{clusters: map[string]*memberCluster{}}
+
+	objects = append(objects, recommendationFixture(1)...)
+	objects = append(objects, &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1", "kind": "Namespace",
+		"metadata": map[string]interface{}{"name": "default"},
+	}})
+	if err := new(RecommendationService).LoadOffline(qosCluster, objects, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// deploymentPod is a pod of Deployment app-0 with a single container app.
+func deploymentPod(requirements corev1.ResourceRequirements) *corev1.Pod {
+	isController := true
+	return &corev1.Pod{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: "app-0-",
+			Namespace:    "default",
+			OwnerReferences: []metav1.OwnerReference{
+				{APIVersion: "apps/v1", Kind: "Deployment", Name: "app-0", Controller: &isController},
+			},
+		},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Resources: requirements}}},
+	}
+}
+
+// admit runs the pod through MutatePod and returns the result and the pod the API server would store.
+func admit(t *testing.T, pod *corev1.Pod) (*corev1.Pod, string, int) {
+	t.Helper()
+	result, err := new(RecommendationService).MutatePod(qosCluster, pod, "default", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Patches) == 0 {
+		return pod, result.SkipReason, 0
+	}
+	ops, err := json.Marshal(result.Patches)
+	if err != nil {
+		t.Fatal(err)
+	}
+	patch, err := jsonpatch.DecodePatch(ops)
+	if err != nil {
+		t.Fatal(err)
+	}
+	original, err := json.Marshal(pod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	patched, err := patch.Apply(original)
+	if err != nil {
+		t.Fatalf("patch %s does not apply: %v", ops, err)
+	}
+	var out corev1.Pod
+	if err := json.Unmarshal(patched, &out); err != nil {
+		t.Fatal(err)
+	}
+	return &out, result.SkipReason, len(result.Patches)
+}
+
+func TestQoSPolicyByClass(t *testing.T) {
+	for _, tc := range []struct {
+		policy QoSPolicy
+		class  corev1.PodQOSClass
+		// skip is the expected skip reason, "" when the pod is patched.
+		skip string
+		want corev1.PodQOSClass
+	}{
+		{QoSPolicyPreserve, corev1.PodQOSGuaranteed, "", corev1.PodQOSGuaranteed},
+		{QoSPolicyPreserve, corev1.PodQOSBurstable, "", corev1.PodQOSBurstable},
+		{QoSPolicyPreserve, corev1.PodQOSBestEffort, "", corev1.PodQOSBurstable},
+		{QoSPolicySkip, corev1.PodQOSGuaranteed, SkipReasonGuaranteedQoS, corev1.PodQOSGuaranteed},
+		{QoSPolicySkip, corev1.PodQOSBurstable, "", corev1.PodQOSBurstable},
+		{QoSPolicySkip, corev1.PodQOSBestEffort, "", corev1.PodQOSBurstable},
+		{QoSPolicyIgnore, corev1.PodQOSGuaranteed, "", corev1.PodQOSBurstable},
+		{QoSPolicyIgnore, corev1.PodQOSBurstable, "", corev1.PodQOSBurstable},
+		{QoSPolicyIgnore, corev1.PodQOSBestEffort, "", corev1.PodQOSBurstable},
+	} {
+		t.Run(string(tc.policy)+"/"+string(tc.class), func(t *testing.T) {
+			loadQoSCluster(t, string(tc.policy))
+			pod := deploymentPod(qosPodRequirements[tc.class])
+			if got := qosClass(pod); got != tc.class {
+				t.Fatalf("fixture is %s, want %s", got, tc.class)
+			}
+
+			patched, skip, patches := admit(t, pod)
+			if skip != tc.skip {
+				t.Fatalf("skip reason = %q, want %q", skip, tc.skip)
+			}
+			if tc.skip == "" && patches == 0 {
+				t.Fatal("pod admitted without patches")
+			}
+			if tc.skip != "" && patches != 0 {
+				t.Fatalf("skipped pod got %d patches", patches)
+			}
+			if got := qosClass(patched); got != tc.want {
+				t.Errorf("patched pod is %s, want %s", got, tc.want)
+			}
+			if tc.skip == "" {
+				cpu := patched.Spec.Containers[0].Resources.Requests[corev1.ResourceCPU]
+				if cpu.String() != "1265m" {
+					t.Errorf("cpu request = %s, want 1265m", cpu.String())
+				}
+			}
+		})
+	}
+}
+
+// TestQoSPolicyPreserveRevertsResource covers a LimitRange that rejects the matched cpu limit: cpu
+// is left unchanged while memory is still patched, and the pod stays Guaranteed.
+func TestQoSPolicyPreserveRevertsResource(t *testing.T) {
+	lr := &corev1.LimitRange{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "LimitRange"},
+		ObjectMeta: metav1.ObjectMeta{Name: "floor", Namespace: "default"},
+		Spec: corev1.LimitRangeSpec{Limits: []corev1.LimitRangeItem{
+			{Type: corev1.LimitTypeContainer, Min: resources("cpu", "1500m")},
+		}},
+	}
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(lr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loadQoSCluster(t, string(QoSPolicyPreserve), &unstructured.Unstructured{Object: obj})
+
+	patched, skip, _ := admit(t, deploymentPod(qosPodRequirements[corev1.PodQOSGuaranteed]))
+	if skip != "" {
+		t.Fatalf("skip reason = %q, want the memory patch", skip)
+	}
+	if got := qosClass(patched); got != corev1.PodQOSGuaranteed {
+		t.Errorf("patched pod is %s, want %s", got, corev1.PodQOSGuaranteed)
+	}
+	res := patched.Spec.Containers[0].Resources
+	if cpu := res.Requests[corev1.ResourceCPU]; cpu.String() != "2" {
+		t.Errorf("cpu request = %s, want 2 (reverted)", cpu.String())
+	}
+	if memory := res.Limits[corev1.ResourceMemory]; memory.String() != "1150Mi" {
+		t.Errorf("memory limit = %s, want 1150Mi", memory.String())
+	}
+}
+
+// TestQoSPolicyChangedBy covers the final check that drops a plan leaving a request apart from its
+// limit, e.g. after a guardrail or namespace policy moved only one of them.
+func TestQoSPolicyChangedBy(t *testing.T) {
+	for _, tc := range []struct {
+		policy QoSPolicy
+		class  corev1.PodQOSClass
+		want   corev1.PodQOSClass
+	}{
+		{QoSPolicyPreserve, corev1.PodQOSGuaranteed, corev1.PodQOSBurstable},
+		{QoSPolicySkip, corev1.PodQOSGuaranteed, corev1.PodQOSBurstable},
+		{QoSPolicyIgnore, corev1.PodQOSGuaranteed, ""},
+		{QoSPolicyPreserve, corev1.PodQOSBurstable, ""},
+		{QoSPolicyPreserve, corev1.PodQOSBestEffort, ""},
+	} {
+		t.Run(string(tc.policy)+"/"+string(tc.class), func(t *testing.T) {
+			pod := deploymentPod(qosPodRequirements[tc.class])
+			plan := newContainerPlan("/spec/containers/0", &pod.Spec.Containers[0])
+			plan.Requests[corev1.ResourceCPU] = resources("cpu", "500m")[corev1.ResourceCPU]
+
+			q := qosSettings{Policy: tc.policy, Class: qosClass(pod)}
+			if got := q.changedBy(pod, []*containerPlan{plan}); got != tc.want {
+				t.Errorf("changedBy = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,439 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"main.go/global"
+	modelWebhook "main.go/model/webhook"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// AppliedChangesAnnotation on a Recommendation CR lists, newest first, the changes the controller
+	// wrote to the pod template of the target workload.
+	AppliedChangesAnnotation = "finops.io/applied-changes"
+	maxAppliedChanges        = 10
+
+	completionStrategyOnce       = "Once"
+	completionStrategyPeriodical = "Periodical"
+
+	defaultControllerInterval    = time.Minute
+	defaultMaxConcurrentRollouts = 1
+	defaultRolloutTimeout        = 30 * time.Minute
+	defaultCompletionPeriod      = 24 * time.Hour
+	controllerRequestTimeout     = 10 * time.Second
+)
+
+// Controller outcomes, used as metric labels.
+const (
+	ControllerResultApplied = "applied"
+	ControllerResultDryRun  = "dry_run"
+	ControllerResultError   = "error"
+)
+
+// controlledWorkloadGVRs are the workload kinds whose pod template the controller writes.
+var controlledWorkloadGVRs = map[string]schema.GroupVersionResource{
+	"Deployment":  {Group: "apps", Version: "v1", Resource: "deployments"},
+	"StatefulSet": {Group: "apps", Version: "v1", Resource: "statefulsets"},
+}
+
+// recommendationController writes recommendations into the pod templates of their target workloads,
+// so the workload spec matches what the webhook would admit and GitOps diffs show the real requests.
+// It runs on the leader only and shares the selection, guardrail, limit policy and mutation mode of
+// the webhook.
+type recommendationController struct {
+	mu sync.Mutex
+	// rollouts are the workloads patched by this replica whose rollout has not finished, by workload key.
+	rollouts map[string]rollout
+	// dryRunReported remembers the recommendation and workload versions a dry-run patch was logged for.
+	dryRunReported map[string]string
+}
+
+type rollout struct {
+	Cluster   string
+	GVR       schema.GroupVersionResource
+	Namespace string
+	Name      string
+	Started   time.Time
+}
+
+var controllerStart sync.Once
+
+// startRecommendationController starts the reconcile loop once, after the first successful
+// InitK8s. Replicas that are not the leader keep the loop running but do nothing.
+func startRecommendationController() {
+	cfg := global.GVA_CONFIG.Webhook.Controller
+	if !cfg.Enabled {
+		return
+	}
+	controllerStart.Do(func() {
+		interval := cfg.Interval
+		if interval <= 0 {
+			interval = defaultControllerInterval
+		}
+		c := &recommendationController{
+			rollouts:       map[string]rollout{},
+			dryRunReported: map[string]string{},
+		}
+		log.Printf("[Controller] Applying recommendations to workload templates every %s", interval)
+		go func() {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for range ticker.C {
+				if !isLeader() {
+					continue
+				}
+				c.reconcile(time.Now())
+			}
+		}()
+	})
+}
+
+// reconcile applies the selected recommendation of every workload that is due, starting at most as
+// many rollouts as webhook.controller.max-concurrent-rollouts allows.
+func (c *recommendationController) reconcile(now time.Time) {
+	cfg := global.GVA_CONFIG.Webhook.Controller
+	maxRollouts := cfg.MaxConcurrentRollouts
+	if maxRollouts <= 0 {
+		maxRollouts = defaultMaxConcurrentRollouts
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pruneRollouts(now)
+
+	for _, group := range recommendationCache.workloadGroups() {
+		rec, _ := selectRecommendation(group, now)
+		if rec == nil {
+			continue
+		}
+		if _, rolling := c.rollouts[rec.WorkloadKey]; rolling {
+			continue
+		}
+		if len(c.rollouts) >= maxRollouts {
+			log.Printf("[Controller] %d workload(s) rolling out, deferring the remaining recommendations", len(c.rollouts))
+			return
+		}
+		result, err := c.apply(rec, now)
+		if err != nil {
+			log.Printf("[Controller] Failed to apply Recommendation %s/%s to %s %s/%s: %v",
+				rec.Namespace, rec.Name, rec.Target.Kind, rec.TargetNamespace, rec.Target.Name, err)
+			controllerApplies.WithLabelValues(ControllerResultError).Inc()
+			continue
+		}
+		if result != "" {
+			controllerApplies.WithLabelValues(result).Inc()
+		}
+	}
+}
+
+// apply patches the pod template of one workload when the recommendation is due and differs from it.
+// It returns the outcome, or "" when there was nothing to do.
+func (c *recommendationController) apply(rec *cachedRecommendation, now time.Time) (string, error) {
+	gvr, ok := controlledWorkloadGVRs[rec.Target.Kind]
+	if !ok || rec.TemplateErr != nil || len(rec.TemplateRequests) == 0 {
+		return "", nil
+	}
+	if !completionDue(rec, now) {
+		return "", nil
+	}
+	clusterCfg, served := clusterConfig(rec.Cluster)
+	if !served || !clusterEnabled(clusterCfg) {
+		return "", nil
+	}
+	cluster := memberClusters.get(rec.Cluster)
+	if cluster == nil || !cluster.ready || cluster.Client == nil {
+		return "", nil
+	}
+
+	namespace := rec.TargetNamespace
+	ctx, cancel := context.WithTimeout(context.Background(), controllerRequestTimeout)
+	defer cancel()
+	workload, err := cluster.Client.Resource(gvr).Namespace(namespace).Get(ctx, rec.Target.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("get workload: %w", err)
+	}
+	pod, err := podFromTemplate(workload)
+	if err != nil {
+		return "", err
+	}
+
+	subject := fmt.Sprintf("%s: %s/%s", rec.Target.Kind, namespace, rec.Target.Name)
+	mode, reason := mutationModeFor(cluster, pod, namespace, workload.GetAnnotations())
+	if mode == MutationDisabled {
+		return "", nil
+	}
+	autoscalers := checkAutoscalers(cluster, namespace, rec.Target)
+	if autoscalers.Skip {
+		return "", nil
+	}
+	qos := qosSettingsFor(pod, workload.GetAnnotations())
+	if qos.skips() {
+		return "", nil
+	}
+
+	limits := limitSettingsFor(pod, workload.GetAnnotations())
+	guardrail := guardrailFor(clusterCfg, namespace)
+	patches := []modelWebhook.JSONPatch{
+		// Fail instead of patching the wrong container index if the template changed since the GET.
+		{Op: "test", Path: "/metadata/resourceVersion", Value: workload.GetResourceVersion()},
+	}
+	change := modelWebhook.AppliedChange{
+		Time:                          now.UTC().Truncate(time.Second),
+		Cluster:                       rec.Cluster,
+		Kind:                          rec.Target.Kind,
+		Namespace:                     namespace,
+		Name:                          rec.Target.Name,
+		RecommendationResourceVersion: rec.ResourceVersion,
+	}
+	var plans []*containerPlan
+	for _, ref := range mutableContainers(pod) {
+		targets, ok := rec.TemplateRequests[ref.Container.Name]
+		if !ok {
+			continue
+		}
+		plan, _ := planContainer(subject, ref, withoutResources(targets, autoscalers.SkipResources), limits, guardrail)
+		if !plan.empty() {
+			plans = append(plans, plan)
+		}
+	}
+	// A template the LimitRanges or quota reject would stall the rollout.
+	for _, note := range fitNamespacePolicies(cluster, pod, namespace, plans, false) {
+		log.Printf("[Controller] %s, %s", subject, note)
+	}
+	if after := qos.changedBy(pod, nonEmptyPlans(plans)); after != "" {
+		log.Printf("[Controller] %s, template change would move QoS class from %s to %s; skipping", subject, qos.Class, after)
+		return "", nil
+	}
+	applied := plans[:0]
+	for _, plan := range plans {
+		if plan.empty() {
+			continue
+		}
+		applied = append(applied, plan)
+		for _, p := range plan.patches() {
+			p.Path = "/spec/template" + p.Path
+			patches = append(patches, p)
+		}
+		appliedContainer := modelWebhook.AppliedContainer{
+			Name:        plan.Container.Name,
+			OldRequests: resourceStrings(plan.Container.Resources.Requests),
+			Requests:    resourceStrings(plan.Requests),
+			Limits:      resourceStrings(plan.Limits),
+		}
+		for _, name := range plan.RemoveLimits {
+			appliedContainer.RemoveLimits = append(appliedContainer.RemoveLimits, string(name))
+		}
+		change.Containers = append(change.Containers, appliedContainer)
+	}
+	plans = applied
+	if len(change.Containers) == 0 {
+		return "", nil
+	}
+
+	patchBytes, err := json.Marshal(patches)
+	if err != nil {
+		return "", err
+	}
+	if mode == MutationDryRun {
+		// Log a dry-run patch once per recommendation and workload version, not on every pass.
+		version := rec.ResourceVersion + "/" + workload.GetResourceVersion()
+		if c.dryRunReported[rec.WorkloadKey] == version {
+			return "", nil
+		}
+		c.dryRunReported[rec.WorkloadKey] = version
+		log.Printf("[Controller] [Dry Run] %s, %s, patch not applied: %s", subject, reason, patchBytes)
+		return ControllerResultDryRun, nil
+	}
+
+	if _, err := cluster.Client.Resource(gvr).Namespace(namespace).Patch(ctx, rec.Target.Name, types.JSONPatchType, patchBytes, metav1.PatchOptions{}); err != nil {
+		return "", fmt.Errorf("patch workload: %w", err)
+	}
+	log.Printf("[Controller] %s, applied Recommendation %s/%s: %s", subject, rec.Namespace, rec.Name, patchBytes)
+	c.rollouts[rec.WorkloadKey] = rollout{Cluster: rec.Cluster, GVR: gvr, Namespace: namespace, Name: rec.Target.Name, Started: now}
+	// Pods created from the new template are already at target, so the webhook cannot scale the HPAs.
+	for _, hpa := range autoscalers.ScaleHPAs {
+		for _, w := range scaleHPATargets(cluster, hpa, pod, applyPlans(pod, plans), false) {
+			log.Printf("[Controller] %s, %s", subject, w)
+		}
+	}
+
+	// The workload is already patched; a failure here only loses the record. For a Once strategy the
+	// next pass finds the template at target and does nothing.
+	if err := recordAppliedChange(rec, change); err != nil {
+		log.Printf("[Controller] Failed to record the applied change on Recommendation %s/%s: %v", rec.Namespace, rec.Name, err)
+	}
+	return ControllerResultApplied, nil
+}
+
+// completionDue applies spec.completionStrategy: Once recommendations are applied a single time,
+// Periodical ones again after periodSeconds (webhook.controller.default-period when unset).
+func completionDue(rec *cachedRecommendation, now time.Time) bool {
+	if len(rec.Applied) == 0 {
+		return true
+	}
+	if rec.CompletionStrategy != completionStrategyPeriodical {
+		return false
+	}
+	period := rec.Period
+	if period <= 0 {
+		period = global.GVA_CONFIG.Webhook.Controller.DefaultPeriod
+	}
+	if period <= 0 {
+		period = defaultCompletionPeriod
+	}
+	return now.Sub(rec.Applied[0].Time) >= period
+}
+
+// recordAppliedChange prepends a change to the AppliedChangesAnnotation of the Recommendation CR.
+func recordAppliedChange(rec *cachedRecommendation, change modelWebhook.AppliedChange) error {
+	changes := append([]modelWebhook.AppliedChange{change}, rec.Applied...)
+	if len(changes) > maxAppliedChanges {
+		changes = changes[:maxAppliedChanges]
+	}
+	value, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{AppliedChangesAnnotation: string(value)},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), controllerRequestTimeout)
+	defer cancel()
+	_, err = global.GVA_K8S_DYNAMIC.Resource(recommendationGVR).Namespace(rec.Namespace).Patch(ctx, rec.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
+
+// pruneRollouts forgets the workloads whose rollout finished, disappeared or exceeded
+// webhook.controller.rollout-timeout. Rollouts started by a previous leader are not known here.
+func (c *recommendationController) pruneRollouts(now time.Time) {
+	timeout := global.GVA_CONFIG.Webhook.Controller.RolloutTimeout
+	if timeout <= 0 {
+		timeout = defaultRolloutTimeout
+	}
+	for key, r := range c.rollouts {
+		cluster := memberClusters.get(r.Cluster)
+		if cluster == nil || cluster.Client == nil {
+			delete(c.rollouts, key)
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), controllerRequestTimeout)
+		u, err := cluster.Client.Resource(r.GVR).Namespace(r.Namespace).Get(ctx, r.Name, metav1.GetOptions{})
+		cancel()
+		switch {
+		case apierrors.IsNotFound(err):
+			delete(c.rollouts, key)
+		case err != nil:
+			log.Printf("[Controller] Failed to get rollout status of %s %s/%s: %v", r.GVR.Resource, r.Namespace, r.Name, err)
+		case rolloutComplete(u):
+			log.Printf("[Controller] Rollout of %s %s/%s finished after %s", r.GVR.Resource, r.Namespace, r.Name, now.Sub(r.Started).Round(time.Second))
+			delete(c.rollouts, key)
+		case now.Sub(r.Started) > timeout:
+			log.Printf("[Controller] Rollout of %s %s/%s not finished after %s, no longer waiting for it", r.GVR.Resource, r.Namespace, r.Name, timeout)
+			delete(c.rollouts, key)
+		}
+	}
+	rolloutsInProgress.Set(float64(len(c.rollouts)))
+}
+
+// rolloutComplete reports whether every replica of a Deployment or StatefulSet runs the current
+// template. StatefulSets with the OnDelete strategy only roll when pods are deleted, so they count
+// as complete right away.
+func rolloutComplete(u *unstructured.Unstructured) bool {
+	observed, _, _ := unstructured.NestedInt64(u.Object, "status", "observedGeneration")
+	if observed < u.GetGeneration() {
+		return false
+	}
+	replicas, found, _ := unstructured.NestedInt64(u.Object, "spec", "replicas")
+	if !found {
+		replicas = 1
+	}
+	updated, _, _ := unstructured.NestedInt64(u.Object, "status", "updatedReplicas")
+	total, _, _ := unstructured.NestedInt64(u.Object, "status", "replicas")
+
+	switch u.GetKind() {
+	case "StatefulSet":
+		if strategy, _, _ := unstructured.NestedString(u.Object, "spec", "updateStrategy", "type"); strategy == "OnDelete" {
+			return true
+		}
+		current, _, _ := unstructured.NestedString(u.Object, "status", "currentRevision")
+		update, _, _ := unstructured.NestedString(u.Object, "status", "updateRevision")
+		ready, _, _ := unstructured.NestedInt64(u.Object, "status", "readyReplicas")
+		return updated >= replicas && ready >= replicas && current == update
+	default:
+		available, _, _ := unstructured.NestedInt64(u.Object, "status", "availableReplicas")
+		// Old pods still terminating show up in status.replicas.
+		return updated >= replicas && available >= replicas && total <= replicas
+	}
+}
+
+// podFromTemplate builds a pod from a workload's spec.template so the admission logic can be
+// reused. Annotations of the template, such as the limit policy, apply as they would on the pod.
+func podFromTemplate(workload *unstructured.Unstructured) (*corev1.Pod, error) {
+	raw, found, err := unstructured.NestedMap(workload.Object, "spec", "template")
+	if err != nil || !found {
+		return nil, fmt.Errorf("spec.template not found: %v", err)
+	}
+	var template corev1.PodTemplateSpec
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(raw, &template); err != nil {
+		return nil, fmt.Errorf("convert spec.template: %w", err)
+	}
+	pod := &corev1.Pod{ObjectMeta: template.ObjectMeta, Spec: template.Spec}
+	pod.Namespace = workload.GetNamespace()
+	pod.GenerateName = workload.GetName() + "-"
+	return pod, nil
+}
+
+// resourceStrings renders a resource list as name to quantity strings, nil when empty.
+func resourceStrings(l corev1.ResourceList) map[string]string {
+	if len(l) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(l))
+	for name, q := range l {
+		out[string(name)] = q.String()
+	}
+	return out
+}
+
+// workloadGroups returns the Recommendations of every workload, ordered by workload key so a
+// limited number of rollouts is spread deterministically.
+func (s *recommendationStore) workloadGroups() [][]*cachedRecommendation {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	keys := make([]string, 0, len(s.byWorkload))
+	for key := range s.byWorkload {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	groups := make([][]*cachedRecommendation, 0, len(keys))
+	for _, key := range keys {
+		group := make([]*cachedRecommendation, 0, len(s.byWorkload[key]))
+		for _, rec := range s.byWorkload[key] {
+			group = append(group, rec)
+		}
+		groups = append(groups, group)
+	}
+	return groups
+}
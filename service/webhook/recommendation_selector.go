@@ -0,0 +1,86 @@
+package webhook
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"main.go/global"
+)
+
+const (
+	// recommendationMessageAnnotation carries the outcome of the recommender's last run.
+	recommendationMessageAnnotation = "bcs.finops.io/message"
+	recommendationSuccessMessage    = "Success"
+)
+
+// defaultAdoptionTypes are acted on when webhook.adoption-types is empty. Auto is left out because
+// the recommender's own controller already applies those recommendations to the workload.
+var defaultAdoptionTypes = []string{"Status", "StatusAndAnnotation"}
+
+// selectRecommendation picks the Recommendation CR to apply among those indexed for one workload.
+// Ineligible CRs are dropped and the most recently updated remaining one wins, ties broken by name,
+// so the choice does not depend on informer ordering. When nothing is eligible the reasons are returned.
+func selectRecommendation(recs []*cachedRecommendation, now time.Time) (*cachedRecommendation, []string) {
+	var candidates []*cachedRecommendation
+	var reasons []string
+	for _, rec := range recs {
+		if rec == nil {
+			continue
+		}
+		if reason := recommendationIneligible(rec, now); reason != "" {
+			reasons = append(reasons, fmt.Sprintf("%s: %s", rec.Name, reason))
+			continue
+		}
+		candidates = append(candidates, rec)
+	}
+	if len(candidates) == 0 {
+		return nil, reasons
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		ti, tj := candidates[i].updateTime(), candidates[j].updateTime()
+		if !ti.Equal(tj) {
+			return ti.After(tj)
+		}
+		return candidates[i].Name < candidates[j].Name
+	})
+	return candidates[0], nil
+}
+
+// recommendationIneligible returns why a CR must not be applied, or "" when it may be. It is evaluated
+// on every lookup rather than when the CR is parsed, because it depends on the clock and the config.
+func recommendationIneligible(rec *cachedRecommendation, now time.Time) string {
+	cfg := global.GVA_CONFIG.Webhook
+
+	if rec.Message != recommendationSuccessMessage {
+		return fmt.Sprintf("last run was not %s (%s=%q)", recommendationSuccessMessage, recommendationMessageAnnotation, rec.Message)
+	}
+
+	allowed := cfg.AdoptionTypes
+	if len(allowed) == 0 {
+		allowed = defaultAdoptionTypes
+	}
+	if !containsString(allowed, rec.AdoptionType) {
+		return fmt.Sprintf("adoptionType %q is not handled by the webhook", rec.AdoptionType)
+	}
+
+	if cfg.MaxRecommendationAge > 0 {
+		if rec.LastUpdateTime.IsZero() {
+			return "status.lastUpdateTime is missing"
+		}
+		if age := now.Sub(rec.LastUpdateTime); age > cfg.MaxRecommendationAge {
+			return fmt.Sprintf("stale, last updated %s ago (max %s)", age.Truncate(time.Second), cfg.MaxRecommendationAge)
+		}
+	}
+	return ""
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
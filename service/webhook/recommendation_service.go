@@ -1,11 +1,13 @@
 package webhook
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
 	"strings"
 	"time"
 
+	"main.go/config"
 	"main.go/global"
 	modelWebhook "main.go/model/webhook"
 
@@ -15,10 +17,10 @@ import (
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/dynamic/dynamicinformer"
-	"k8s.io/client-go/rest"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/metadata"
+	"k8s.io/client-go/metadata/metadatainformer"
 	"k8s.io/client-go/tools/cache"
-	"k8s.io/client-go/tools/clientcmd"
-	"sigs.k8s.io/yaml"
 )
 
 type RecommendationService struct{}
@@ -29,36 +31,38 @@ var recommendationGVR = schema.GroupVersionResource{
 	Resource: "recommendations",
 }
 
-func (s *RecommendationService) InitK8s() {
-	// 1. Initialize Config
-	var config *rest.Config
-	var err error
-
-	k8sConfig := global.GVA_CONFIG.K8s
-	if k8sConfig.KubeConfig != "" {
-		config, err = clientcmd.BuildConfigFromFlags("", k8sConfig.KubeConfig)
-	} else if k8sConfig.Host != "" && k8sConfig.BearerToken != "" {
-		config = &rest.Config{
-			Host:        k8sConfig.Host,
-			BearerToken: k8sConfig.BearerToken,
-			TLSClientConfig: rest.TLSClientConfig{
-				Insecure: true,
-			},
-		}
-	} else {
-		config, err = rest.InClusterConfig()
-	}
+const targetWorkloadIndex = "targetWorkloadIndex"
 
+// targetWorkloadIndexKey builds the targetWorkloadIndex key. The kind is part of the key so that
+// a Deployment and a StatefulSet with the same name in one namespace never share recommendations.
+func targetWorkloadIndexKey(cluster, namespace, kind, name string) string {
+	return fmt.Sprintf("%s/%s/%s/%s", cluster, namespace, kind, name)
+}
+
+// InitK8s builds the clients and informers and waits for the caches to sync. On failure the
+// informers of this attempt are stopped and the error is returned so StartK8s can retry.
+func (s *RecommendationService) InitK8s() error {
+	// 1. Initialize Config
+	config, err := restConfigFor(global.GVA_CONFIG.K8s)
 	if err != nil {
-		log.Fatalf("Failed to load kubeconfig: %v", err)
+		return fmt.Errorf("load kubeconfig: %w", err)
 	}
 
 	// 2. Initialize Dynamic Client
 	dynamicClient, err := dynamic.NewForConfig(config)
 	if err != nil {
-		log.Fatalf("Failed to create dynamic client: %v", err)
+		return fmt.Errorf("create dynamic client: %w", err)
 	}
-	global.GVA_K8S_DYNAMIC = dynamicClient
+	metadataClient, err := metadata.NewForConfig(config)
+	if err != nil {
+		return fmt.Errorf("create metadata client: %w", err)
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return fmt.Errorf("create clientset: %w", err)
+	}
+	k8sStatus.setClient(dynamicClient)
+	startLeaderElection(clientset)
 
 	// 3. Initialize Dynamic Informer Factory
 	factory := dynamicinformer.NewDynamicSharedInformerFactory(dynamicClient, 10*time.Minute)
@@ -66,238 +70,367 @@ func (s *RecommendationService) InitK8s() {
 
 	// 4. Add Custom Indexer
 	err = informer.AddIndexers(cache.Indexers{
-		"targetWorkloadIndex": func(obj interface{}) ([]string, error) {
+		targetWorkloadIndex: func(obj interface{}) ([]string, error) {
 			u, ok := obj.(*unstructured.Unstructured)
 			if !ok {
 				return nil, nil
 			}
-			labels := u.GetLabels()
-			targetNs := labels["bcs.finops.io/recommendation-target-namespace"]
-			targetName := labels["bcs.finops.io/recommendation-target-name"]
-			cluster, _, _ := unstructured.NestedString(u.Object, "spec", "cluster")
-
-			if targetNs != "" && targetName != "" {
-				return []string{fmt.Sprintf("%s/%s/%s", cluster, targetNs, targetName)}, nil
+			if key := recommendationWorkloadKey(u); key != "" {
+				return []string{key}, nil
 			}
 			return nil, nil
 		},
 	})
 	if err != nil {
-		log.Fatalf("Failed to add indexer: %v", err)
+		return fmt.Errorf("add indexer: %w", err)
 	}
 
-	global.GVA_K8S_INDEXER = informer.GetIndexer()
+	// Admission reads the parsed store rather than the raw objects, so each CR version is parsed once.
+	store := newRecommendationStore()
+	if _, err := informer.AddEventHandler(store.eventHandler()); err != nil {
+		return fmt.Errorf("add recommendation event handler: %w", err)
+	}
 
-	// 5. Start Informer
-	stopCh := make(chan struct{})
-	// We do not close stopCh to keep the informer running
+	// The owner, namespace and autoscaler caches of the local cluster come from the same factory.
+	watchVPA, err := servesResource(config, vpaGVR)
+	if err != nil {
+		return err
+	}
+	metadataFactory := metadatainformer.NewSharedInformerFactory(metadataClient, 10*time.Minute)
+	ci, err := addClusterInformers(factory, metadataFactory, watchVPA)
+	if err != nil {
+		return err
+	}
+	informers := map[schema.GroupVersionResource]cache.SharedIndexInformer{recommendationGVR: informer}
+	for gvr, inf := range ci.informers {
+		informers[gvr] = inf
+	}
 
+	// 5. Start Informer
 	log.Println("Starting Informer and waiting for cache sync...")
-	factory.Start(stopCh)
-	if !cache.WaitForCacheSync(stopCh, informer.HasSynced) {
-		log.Fatalf("Failed to sync cache for recommendations CR")
+	if err := syncInformers(global.GVA_CONFIG.System.ClusterId, []informerFactory{factory, metadataFactory}, informers, true); err != nil {
+		return err
+	}
+
+	// Publish the caches only once they are complete, so admission never sees a partial view.
+	global.GVA_K8S_DYNAMIC = dynamicClient
+	global.GVA_K8S_CLIENT = clientset
+	global.GVA_K8S_INDEXER = informer.GetIndexer()
+	recommendationCache = store
+	// The local cluster, and any configured cluster without its own connection, share these caches.
+	local := &memberCluster{
+		Client:         dynamicClient,
+		Resolver:       NewOwnerResolver(dynamicClient, ci.ownerIndexers),
+		Namespaces:     ci.namespaces,
+		HPAs:           ci.hpas,
+		VPAs:           ci.vpas,
+		LimitRanges:    ci.limitRanges,
+		ResourceQuotas: ci.resourceQuotas,
+		Events:         newEventRecorder(clientset),
+		ready:          true,
+	}
+	localIDs := []string{global.GVA_CONFIG.System.ClusterId}
+	for _, c := range global.GVA_CONFIG.Webhook.Clusters {
+		if c.ID != "" && !hasOwnConnection(c.K8s) {
+			localIDs = append(localIDs, c.ID)
+		}
+	}
+	for _, id := range localIDs {
+		cluster := *local
+		cluster.ID = id
+		memberClusters.set(&cluster)
 	}
+	k8sStatus.setReady()
 	log.Println("Cache synced successfully. Webhook is ready.")
+	return nil
 }
 
-func (s *RecommendationService) MutatePod(pod *corev1.Pod, namespace string) ([]modelWebhook.JSONPatch, error) {
+// MutatePod computes the patch for a pod created in the given member cluster; an empty clusterID is
+// the cluster of system.cluster-id. dryRun is the dryRun flag of the AdmissionRequest: the patch is
+// still returned, but nothing outside the admission response is written.
+func (s *RecommendationService) MutatePod(clusterID string, pod *corev1.Pod, namespace string, dryRun bool) (*modelWebhook.MutationResult, error) {
+	if clusterID == "" {
+		clusterID = global.GVA_CONFIG.System.ClusterId
+	}
+	result := &modelWebhook.MutationResult{Cluster: clusterID, DryRun: dryRun}
 	var patches []modelWebhook.JSONPatch
 
+	// Until the caches have synced the webhook admits pods unchanged rather than guessing.
+	if ready, reason := s.Ready(); !ready {
+		log.Printf("Webhook not ready, admitting pod %s in %s unchanged: %s", pod.GenerateName, namespace, reason)
+		return skipPod(result, SkipReasonNotReady), nil
+	}
+
+	clusterCfg, served := clusterConfig(clusterID)
+	if !served {
+		log.Printf("Cluster %s is not served by this webhook, admitting pod %s in %s unchanged", clusterID, pod.GenerateName, namespace)
+		return skipPod(result, SkipReasonUnknownCluster), nil
+	}
+	if !clusterEnabled(clusterCfg) {
+		log.Printf("Mutation disabled for cluster %s, admitting pod %s in %s unchanged", clusterID, pod.GenerateName, namespace)
+		return skipPod(result, SkipReasonMutationDisabled), nil
+	}
+	cluster := memberClusters.get(clusterID)
+	if cluster == nil || !cluster.ready {
+		log.Printf("Caches of cluster %s not synced, admitting pod %s in %s unchanged", clusterID, pod.GenerateName, namespace)
+		return skipPod(result, SkipReasonNotReady), nil
+	}
+
+	// 0. Check the namespace and pod opt-in/opt-out controls
+	mode, reason := mutationModeFor(cluster, pod, namespace, nil)
+	result.Mode = string(mode)
+	if mode == MutationDisabled {
+		log.Printf("Mutation disabled for pod %s in %s: %s", pod.GenerateName, namespace, reason)
+		return skipPod(result, SkipReasonMutationDisabled), nil
+	}
+
 	// 1. Get Workload Info
-	workloadName, _ := s.getWorkloadInfo(pod)
-	if workloadName == "" {
-		return patches, nil
+	workload := s.getWorkloadInfo(cluster, pod, namespace)
+	result.Workload = workload
+	if workload.Name == "" {
+		return skipPod(result, SkipReasonNoWorkload), nil
 	}
 
 	// 2. Get Recommendation from Cache
-	recommendationMap := s.getRecommendationFromCache(namespace, workloadName)
-	if len(recommendationMap) == 0 {
-		return patches, nil
+	rec, skipReason := s.getRecommendationFromCache(clusterID, namespace, workload.Kind, workload.Name)
+	if rec != nil {
+		result.RecommendationName = rec.Name
+		result.RecommendationResourceVersion = rec.ResourceVersion
+	}
+	if rec == nil || len(rec.Containers) == 0 {
+		if skipReason == "" {
+			skipReason = SkipReasonNoRecommendation
+		}
+		return skipPod(result, skipReason), nil
+	}
+	// The workload annotations are only read once there is something to apply; the mutation mode
+	// they set can only narrow the mode decided so far.
+	annotations := cluster.resolver().WorkloadAnnotations(namespace, workload)
+	mode, reason = narrowMutationMode(mode, reason, "workload annotation", annotations, pod, namespace)
+	result.Mode = string(mode)
+	if mode == MutationDisabled {
+		log.Printf("Mutation disabled for pod %s in %s: %s", pod.GenerateName, namespace, reason)
+		return skipPod(result, SkipReasonMutationDisabled), nil
+	}
+
+	// 3. Check the autoscalers of the workload
+	autoscalers := checkAutoscalers(cluster, namespace, workload)
+	result.Warnings = append(result.Warnings, autoscalers.Warnings...)
+	if autoscalers.Skip {
+		log.Printf("Pod: %s, %s", pod.GenerateName, strings.Join(autoscalers.Warnings, "; "))
+		return skipPod(result, SkipReasonAutoscaler), nil
+	}
+
+	// 4. Check the QoS policy
+	qos := qosSettingsFor(pod, annotations)
+	if qos.skips() {
+		log.Printf("Pod: %s is %s and QoS policy is %s, admitting it unchanged", pod.GenerateName, qos.Class, qos.Policy)
+		return skipPod(result, SkipReasonGuaranteedQoS), nil
 	}
 
-	// 3. Generate Patches
-	for i, container := range pod.Spec.Containers {
-		targetRes, ok := recommendationMap[container.Name]
+	// 5. Generate Patches
+	limits := limitSettingsFor(pod, annotations)
+	guardrail := guardrailFor(clusterCfg, namespace)
+	var plans []*containerPlan
+	audits := map[*containerPlan]int{}
+	for _, ref := range mutableContainers(pod) {
+		container := ref.Container
+		audit := modelWebhook.ContainerDecision{
+			Name:      container.Name,
+			Kind:      ref.kindLabel(),
+			OldCPU:    requestString(container, corev1.ResourceCPU),
+			OldMemory: requestString(container, corev1.ResourceMemory),
+		}
+		targets, ok := rec.Containers[container.Name]
 		if !ok {
+			resourceDecisions.WithLabelValues("all", SkipReasonNoContainerTarget).Inc()
+			audit.Reason = SkipReasonNoContainerTarget
+			result.Containers = append(result.Containers, audit)
 			continue
 		}
 
-		hasRequests := container.Resources.Requests != nil
-
-		// Determine CPU to patch
-		cpuToPatch := ""
-		if targetRes.CPU != "" {
-			targetCPUQty, err := resource.ParseQuantity(targetRes.CPU)
-			if err != nil {
-				log.Printf("Failed to parse recommended CPU %s: %v", targetRes.CPU, err)
-			} else {
-				shouldPatch := true
-				if container.Resources.Limits != nil {
-					if limitQty, ok2 := container.Resources.Limits[corev1.ResourceCPU]; ok2 && targetCPUQty.Cmp(limitQty) > 0 {
-						log.Printf("Recommended CPU %s exceeds limit %s for container %s, skipping", targetRes.CPU, limitQty.String(), container.Name)
-						shouldPatch = false
-					}
-				}
-				if shouldPatch && hasRequests {
-					if currentCPU, exists := container.Resources.Requests[corev1.ResourceCPU]; exists && currentCPU.Cmp(targetCPUQty) == 0 {
-						log.Printf("CPU already at recommended value %s for container %s, skipping", targetRes.CPU, container.Name)
-						shouldPatch = false
-					}
-				}
-				if shouldPatch {
-					cpuToPatch = targetRes.CPU
-				}
-			}
+		audit.Reason = SkipReasonAlreadyAtTarget
+		if rec.InvalidContainers[container.Name] {
+			// Reported when the CR was parsed; apply whatever else is valid.
+			audit.Reason = SkipReasonParseError
+			resourceDecisions.WithLabelValues("all", SkipReasonParseError).Inc()
 		}
-
-		// Determine Memory to patch
-		memoryToPatch := ""
-		if targetRes.Memory != "" {
-			targetMemQty, err := resource.ParseQuantity(targetRes.Memory)
-			if err != nil {
-				log.Printf("Failed to parse recommended Memory %s: %v", targetRes.Memory, err)
-			} else {
-				shouldPatch := true
-				if container.Resources.Limits != nil {
-					if limitQty, ok2 := container.Resources.Limits[corev1.ResourceMemory]; ok2 && targetMemQty.Cmp(limitQty) > 0 {
-						log.Printf("Recommended Memory %s exceeds limit %s for container %s, skipping", targetRes.Memory, limitQty.String(), container.Name)
-						shouldPatch = false
-					}
-				}
-				if shouldPatch && hasRequests {
-					if currentMem, exists := container.Resources.Requests[corev1.ResourceMemory]; exists && currentMem.Cmp(targetMemQty) == 0 {
-						log.Printf("Memory already at recommended value %s for container %s, skipping", targetRes.Memory, container.Name)
-						shouldPatch = false
-					}
-				}
-				if shouldPatch {
-					memoryToPatch = targetRes.Memory
-				}
-			}
+		plan, warnings := planContainer("Pod: "+pod.GenerateName, ref, withoutResources(targets, autoscalers.SkipResources), limits, guardrail)
+		result.Warnings = append(result.Warnings, warnings...)
+		audit.NewCPU, audit.NewMemory = audit.OldCPU, audit.OldMemory
+		result.Containers = append(result.Containers, audit)
+		if !plan.empty() {
+			plans = append(plans, plan)
+			audits[plan] = len(result.Containers) - 1
 		}
+	}
 
-		if cpuToPatch == "" && memoryToPatch == "" {
+	// 6. Keep the patched pod within the namespace's LimitRanges and ResourceQuotas
+	if len(plans) > 0 {
+		for _, note := range fitNamespacePolicies(cluster, pod, namespace, plans, false) {
+			log.Printf("Pod: %s, %s", pod.GenerateName, note)
+			result.Warnings = append(result.Warnings, "finops "+note)
+		}
+	}
+	if after := qos.changedBy(pod, nonEmptyPlans(plans)); after != "" {
+		log.Printf("Pod: %s, patch would change QoS class from %s to %s, admitting it unchanged", pod.GenerateName, qos.Class, after)
+		result.Warnings = append(result.Warnings, fmt.Sprintf("finops qos: patch would change QoS class from %s to %s, not applied", qos.Class, after))
+		for _, plan := range plans {
+			result.Containers[audits[plan]].Reason = SkipReasonQoSChange
+		}
+		return skipPod(result, SkipReasonQoSChange), nil
+	}
+	applied := plans[:0]
+	for _, plan := range plans {
+		audit := &result.Containers[audits[plan]]
+		if plan.empty() {
+			audit.Reason = plan.Reverted
 			continue
 		}
-
-		if !hasRequests {
-			// No requests object exists — create it with all recommended values at once
-			requestsValue := map[string]string{}
-			if cpuToPatch != "" {
-				requestsValue["cpu"] = cpuToPatch
-			}
-			if memoryToPatch != "" {
-				requestsValue["memory"] = memoryToPatch
-			}
-			patches = append(patches, modelWebhook.JSONPatch{
-				Op:    "add",
-				Path:  fmt.Sprintf("/spec/containers/%d/resources/requests", i),
-				Value: requestsValue,
-			})
-		} else {
-			// Requests object exists — patch each key individually
-			if cpuToPatch != "" {
-				_, hasCPU := container.Resources.Requests[corev1.ResourceCPU]
-				op := "replace"
-				if !hasCPU {
-					op = "add"
-				}
-				patches = append(patches, modelWebhook.JSONPatch{
-					Op:    op,
-					Path:  fmt.Sprintf("/spec/containers/%d/resources/requests/cpu", i),
-					Value: cpuToPatch,
-				})
-			}
-			if memoryToPatch != "" {
-				_, hasMemory := container.Resources.Requests[corev1.ResourceMemory]
-				op := "replace"
-				if !hasMemory {
-					op = "add"
-				}
-				patches = append(patches, modelWebhook.JSONPatch{
-					Op:    op,
-					Path:  fmt.Sprintf("/spec/containers/%d/resources/requests/memory", i),
-					Value: memoryToPatch,
-				})
-			}
+		patchedContainer := plan.Container.DeepCopy()
+		plan.applyTo(patchedContainer)
+		audit.Reason = DecisionReasonPatched
+		audit.NewCPU = requestString(patchedContainer, corev1.ResourceCPU)
+		audit.NewMemory = requestString(patchedContainer, corev1.ResourceMemory)
+		applied = append(applied, plan)
+		patches = append(patches, plan.patches()...)
+
+		log.Printf("[O(1) Cache Hit] Pod: %s, %s: %s, Limit policy: %s, Set Requests: [%s], Set Limits: [%s], Remove Limits: %v",
+			pod.GenerateName, audit.Kind, audit.Name, limits.Policy, formatResources(plan.Requests), formatResources(plan.Limits), plan.RemoveLimits)
+	}
+	plans = applied
+
+	if len(plans) > 0 {
+		// Init containers and sidecars take part in the pod's effective request, which is what the
+		// scheduler and ResourceQuota see, so report it rather than only the per-container values.
+		patched := applyPlans(pod, plans)
+		log.Printf("Pod: %s, effective request [%s] -> [%s]",
+			pod.GenerateName, formatResources(podEffectiveRequests(pod)), formatResources(podEffectiveRequests(patched)))
+		for _, hpa := range autoscalers.ScaleHPAs {
+			result.Warnings = append(result.Warnings, scaleHPATargets(cluster, hpa, pod, patched, mode == MutationDryRun || dryRun)...)
 		}
-
-		log.Printf("[O(1) Cache Hit] Pod: %s, Container: %s, Set CPU: %s, Set Memory: %s",
-			pod.GenerateName, container.Name, cpuToPatch, memoryToPatch)
 	}
 
-	return patches, nil
-}
+	if mode == MutationDryRun && len(patches) > 0 {
+		// Report what would have changed without applying it
+		patchBytes, err := json.Marshal(patches)
+		if err != nil {
+			return result, err
+		}
+		log.Printf("[Dry Run] Pod: %s, %s, patch not applied: %s", pod.GenerateName, reason, patchBytes)
+		result.AddAuditAnnotation("dry-run-patch", string(patchBytes))
+		for _, p := range patches {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("finops dry-run: would %s %s %v", p.Op, p.Path, p.Value))
+		}
+		return skipPod(result, SkipReasonDryRun), nil
+	}
 
-func (s *RecommendationService) getRecommendationFromCache(namespace, workloadName string) map[string]struct {
-	CPU    string
-	Memory string
-} {
-	if global.GVA_K8S_INDEXER == nil {
-		return nil
+	if len(patches) == 0 {
+		return skipPod(result, SkipReasonAlreadyAtTarget), nil
+	}
+	recorded, err := recordPatches(pod, rec, plans)
+	if err != nil {
+		return result, err
 	}
+	result.Patches = append(patches, recorded...)
+	if !dryRun {
+		// The pod of a server-side dry run is never created, so there is nothing to report on the workload.
+		recordMutationEvent(cluster, namespace, workload, pod, rec, plans)
+	}
+	podDecisions.WithLabelValues("patched", DecisionReasonPatched).Inc()
+	return result, nil
+}
 
-	targetCluster := global.GVA_CONFIG.System.ClusterId
-	indexKey := fmt.Sprintf("%s/%s/%s", targetCluster, namespace, workloadName)
+// planContainer decides the new requests and limits of one container from its recommended targets,
+// applying the guardrail and the limit policy. subject names the pod or workload in logs. Guardrail
+// adjustments are returned as warnings.
+func planContainer(subject string, ref containerRef, targets corev1.ResourceList, limits limitSettings, guardrail config.Guardrail) (*containerPlan, []string) {
+	container := ref.Container
+	plan := newContainerPlan(ref.Path, container)
+	var warnings []string
+	for _, name := range sortedResourceNames(targets) {
+		stored := targets[name]
+		// Store entries are shared between admissions; work on a copy.
+		targetQty := stored.DeepCopy()
+
+		var currentReq *resource.Quantity
+		if q, exists := container.Resources.Requests[name]; exists {
+			currentReq = &q
+		}
+		targetQty, notes := clampRecommendation(guardrail, name, targetQty, currentReq)
+		for _, note := range notes {
+			log.Printf("[Guardrail] %s, %s: %s, %s", subject, ref.kindLabel(), container.Name, note)
+			warnings = append(warnings, fmt.Sprintf("finops guardrail: %s %s: %s", ref.kindLabel(), container.Name, note))
+		}
+		if len(notes) > 0 {
+			resourceDecisions.WithLabelValues(string(name), DecisionReasonGuardrailClamped).Inc()
+		}
 
-	objs, err := global.GVA_K8S_INDEXER.ByIndex("targetWorkloadIndex", indexKey)
-	if err != nil || len(objs) == 0 {
-		return nil
+		decision := limits.decide(name, targetQty, container.Resources)
+		if decision.Capped {
+			resourceDecisions.WithLabelValues(string(name), DecisionReasonLimitCapped).Inc()
+		}
+		if currentQty, exists := container.Resources.Requests[name]; exists && currentQty.Cmp(decision.Request) == 0 {
+			log.Printf("%s already at recommended value %s for %s %s, skipping", name, decision.Request.String(), ref.kindLabel(), container.Name)
+			resourceDecisions.WithLabelValues(string(name), SkipReasonAlreadyAtTarget).Inc()
+			continue
+		}
+		plan.apply(name, decision)
+		resourceDecisions.WithLabelValues(string(name), DecisionReasonPatched).Inc()
 	}
+	return plan, warnings
+}
 
-	cr, ok := objs[0].(*unstructured.Unstructured)
-	if !ok {
-		return nil
-	}
+// skipPod records why a pod is admitted without a patch.
+func skipPod(result *modelWebhook.MutationResult, reason string) *modelWebhook.MutationResult {
+	result.SkipReason = reason
+	recordPodSkipped(reason)
+	return result
+}
 
-	if cr == nil {
-		return nil
+// requestString returns the container's request for a resource, or "" when it has none.
+func requestString(c *corev1.Container, name corev1.ResourceName) string {
+	if q, ok := c.Resources.Requests[name]; ok {
+		return q.String()
 	}
+	return ""
+}
 
-	status, found, err := unstructured.NestedMap(cr.Object, "status")
-	if !found || err != nil {
-		return nil
+// getRecommendationFromCache returns the selected Recommendation for a workload, or a skip reason when there is none.
+// The Recommendation is also returned with a parse error so the decision can name the CR.
+func (s *RecommendationService) getRecommendationFromCache(clusterID, namespace, workloadKind, workloadName string) (*cachedRecommendation, string) {
+	store := recommendationCache
+	if store == nil {
+		recommendationCacheLookups.WithLabelValues("miss").Inc()
+		return nil, SkipReasonNoRecommendation
 	}
 
-	recommendedValStr, ok := status["recommendedValue"].(string)
-	if !ok || recommendedValStr == "" {
-		return nil
-	}
+	indexKey := targetWorkloadIndexKey(clusterID, namespace, workloadKind, workloadName)
 
-	var recValue modelWebhook.RecommendedValue
-	if err := yaml.Unmarshal([]byte(recommendedValStr), &recValue); err != nil {
-		log.Printf("Failed to unmarshal recommendedValue: %v", err)
-		return nil
+	recs := store.forWorkload(indexKey)
+	if len(recs) == 0 {
+		recommendationCacheLookups.WithLabelValues("miss").Inc()
+		return nil, SkipReasonNoRecommendation
 	}
 
-	result := make(map[string]struct {
-		CPU    string
-		Memory string
-	})
-	for _, c := range recValue.ResourceRequest.Containers {
-		result[c.ContainerName] = struct {
-			CPU    string
-			Memory string
-		}{
-			CPU:    c.Target.CPU,
-			Memory: c.Target.Memory,
-		}
+	rec, skipped := selectRecommendation(recs, time.Now())
+	if rec == nil {
+		log.Printf("No eligible Recommendation for %s: %v", indexKey, skipped)
+		recommendationCacheLookups.WithLabelValues("ineligible").Inc()
+		return nil, SkipReasonIneligible
 	}
+	recommendationCacheLookups.WithLabelValues("hit").Inc()
 
-	return result
+	if rec.ParseErr != nil {
+		return rec, SkipReasonParseError
+	}
+	return rec, ""
 }
 
-func (s *RecommendationService) getWorkloadInfo(pod *corev1.Pod) (name string, kind string) {
+// getWorkloadInfo resolves the top-level workload owning the pod by walking its ownerReferences.
+func (s *RecommendationService) getWorkloadInfo(cluster *memberCluster, pod *corev1.Pod, namespace string) modelWebhook.WorkloadRef {
 	if len(pod.OwnerReferences) == 0 {
-		return "", ""
-	}
-	owner := pod.OwnerReferences[0]
-
-	if owner.Kind == "ReplicaSet" {
-		lastDash := strings.LastIndex(owner.Name, "-")
-		if lastDash > 0 {
-			return owner.Name[:lastDash], "Deployment"
-		}
+		return modelWebhook.WorkloadRef{}
 	}
-	return owner.Name, owner.Kind
+	return cluster.resolver().Resolve(namespace, pod.OwnerReferences)
 }
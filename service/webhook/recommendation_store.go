@@ -0,0 +1,309 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	modelWebhook "main.go/model/webhook"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/yaml"
+)
+
+// cachedRecommendation is a Recommendation CR reduced to what admission needs, parsed once when the
+// informer delivers it. Entries are replaced, never modified, so readers may keep them.
+type cachedRecommendation struct {
+	Namespace       string
+	Name            string
+	ResourceVersion string
+	Generation      int64
+	// WorkloadKey is the targetWorkloadIndex key of the target workload, empty when the labels are missing.
+	WorkloadKey string
+	Cluster     string
+	Target      modelWebhook.WorkloadRef
+	// TargetNamespace is spec.targetRef.namespace, falling back to the target namespace label.
+	TargetNamespace string
+
+	Message        string
+	AdoptionType   string
+	LastUpdateTime time.Time // zero when status.lastUpdateTime is missing or invalid
+	CreationTime   time.Time
+
+	// Containers holds the recommended requests by container name.
+	Containers map[string]corev1.ResourceList
+	// InvalidContainers lists containers with a quantity that could not be parsed; the rest of their targets still apply.
+	InvalidContainers map[string]bool
+	// ParseErr is set when status.recommendedValue is missing or unreadable.
+	ParseErr error
+
+	// The fields below are only used by the controller.
+
+	// CompletionStrategy is spec.completionStrategy.completionStrategyType, Once when unset.
+	CompletionStrategy string
+	Period             time.Duration // spec.completionStrategy.periodSeconds
+	// TemplateRequests holds the requests of status.recommendedInfo by container name.
+	TemplateRequests map[string]corev1.ResourceList
+	// TemplateErr is set when status.recommendedInfo is missing or unreadable.
+	TemplateErr error
+	// Applied are the changes recorded in the AppliedChangesAnnotation, newest first.
+	Applied []modelWebhook.AppliedChange
+}
+
+// updateTime is status.lastUpdateTime, falling back to the creation time for ordering.
+func (r *cachedRecommendation) updateTime() time.Time {
+	if !r.LastUpdateTime.IsZero() {
+		return r.LastUpdateTime
+	}
+	return r.CreationTime
+}
+
+// recommendationStore keeps the parsed Recommendations by namespace/name and by target workload.
+type recommendationStore struct {
+	mu         sync.RWMutex
+	byName     map[string]*cachedRecommendation
+	byWorkload map[string]map[string]*cachedRecommendation
+}
+
+// recommendationCache is set up by InitK8s and fed by the Recommendation informer.
+var recommendationCache *recommendationStore
+
+func newRecommendationStore() *recommendationStore {
+	return &recommendationStore{
+		byName:     map[string]*cachedRecommendation{},
+		byWorkload: map[string]map[string]*cachedRecommendation{},
+	}
+}
+
+// eventHandler keeps the store in line with the informer.
+func (s *recommendationStore) eventHandler() cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { s.upsert(obj) },
+		UpdateFunc: func(_, obj interface{}) { s.upsert(obj) },
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				s.delete(tombstone.Key)
+				return
+			}
+			if key, err := cache.MetaNamespaceKeyFunc(obj); err == nil {
+				s.delete(key)
+			}
+		},
+	}
+}
+
+// upsert parses a Recommendation and stores it. Resyncs deliver the same resourceVersion again and
+// are ignored, and a parse error is only reported once per generation.
+func (s *recommendationStore) upsert(obj interface{}) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+	key, err := cache.MetaNamespaceKeyFunc(u)
+	if err != nil {
+		return
+	}
+
+	s.mu.RLock()
+	prev := s.byName[key]
+	s.mu.RUnlock()
+	if prev != nil && prev.ResourceVersion != "" && prev.ResourceVersion == u.GetResourceVersion() {
+		return
+	}
+
+	rec, quantityErrs := parseRecommendation(u)
+	reported := prev != nil && prev.Generation == rec.Generation && (prev.ParseErr != nil || len(prev.InvalidContainers) > 0)
+	if !reported {
+		if rec.ParseErr != nil {
+			log.Printf("Recommendation %s generation %d cannot be applied: %v", key, rec.Generation, rec.ParseErr)
+			recommendationParseErrors.Inc()
+		}
+		for _, err := range quantityErrs {
+			log.Printf("Recommendation %s generation %d: %v", key, rec.Generation, err)
+			recommendationParseErrors.Inc()
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.removeLocked(key)
+	s.byName[key] = rec
+	if rec.WorkloadKey != "" {
+		if s.byWorkload[rec.WorkloadKey] == nil {
+			s.byWorkload[rec.WorkloadKey] = map[string]*cachedRecommendation{}
+		}
+		s.byWorkload[rec.WorkloadKey][key] = rec
+	}
+}
+
+func (s *recommendationStore) delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.removeLocked(key)
+}
+
+func (s *recommendationStore) removeLocked(key string) {
+	prev, ok := s.byName[key]
+	if !ok {
+		return
+	}
+	delete(s.byName, key)
+	if group := s.byWorkload[prev.WorkloadKey]; group != nil {
+		delete(group, key)
+		if len(group) == 0 {
+			delete(s.byWorkload, prev.WorkloadKey)
+		}
+	}
+}
+
+// forWorkload returns the Recommendations targeting a workload.
+func (s *recommendationStore) forWorkload(workloadKey string) []*cachedRecommendation {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	group := s.byWorkload[workloadKey]
+	recs := make([]*cachedRecommendation, 0, len(group))
+	for _, rec := range group {
+		recs = append(recs, rec)
+	}
+	return recs
+}
+
+// recommendationWorkloadKey is the targetWorkloadIndex key of a Recommendation CR, or "" when its
+// target labels are incomplete.
+func recommendationWorkloadKey(u *unstructured.Unstructured) string {
+	labels := u.GetLabels()
+	targetNs := labels["bcs.finops.io/recommendation-target-namespace"]
+	targetName := labels["bcs.finops.io/recommendation-target-name"]
+	targetKind, _, _ := unstructured.NestedString(u.Object, "spec", "targetRef", "kind")
+	if targetKind == "" {
+		targetKind = labels["bcs.finops.io/recommendation-target-kind"]
+	}
+	cluster, _, _ := unstructured.NestedString(u.Object, "spec", "cluster")
+
+	if targetNs == "" || targetName == "" || targetKind == "" {
+		return ""
+	}
+	return targetWorkloadIndexKey(cluster, targetNs, targetKind, targetName)
+}
+
+// parseRecommendation converts a Recommendation CR. Errors in single quantities are returned
+// separately because the other targets of the CR remain usable.
+func parseRecommendation(u *unstructured.Unstructured) (*cachedRecommendation, []error) {
+	rec := &cachedRecommendation{
+		Namespace:       u.GetNamespace(),
+		Name:            u.GetName(),
+		ResourceVersion: u.GetResourceVersion(),
+		Generation:      u.GetGeneration(),
+		WorkloadKey:     recommendationWorkloadKey(u),
+		Message:         u.GetAnnotations()[recommendationMessageAnnotation],
+		CreationTime:    u.GetCreationTimestamp().Time,
+	}
+	rec.AdoptionType, _, _ = unstructured.NestedString(u.Object, "spec", "adoptionType")
+	if v, _, _ := unstructured.NestedString(u.Object, "status", "lastUpdateTime"); v != "" {
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			rec.LastUpdateTime = t
+		}
+	}
+	parseControllerFields(u, rec)
+
+	recommendedValStr, _, _ := unstructured.NestedString(u.Object, "status", "recommendedValue")
+	if recommendedValStr == "" {
+		rec.ParseErr = fmt.Errorf("status.recommendedValue is empty")
+		return rec, nil
+	}
+	var recValue modelWebhook.RecommendedValue
+	if err := yaml.Unmarshal([]byte(recommendedValStr), &recValue); err != nil {
+		rec.ParseErr = fmt.Errorf("unmarshal status.recommendedValue: %w", err)
+		return rec, nil
+	}
+
+	var quantityErrs []error
+	rec.Containers = make(map[string]corev1.ResourceList)
+	containers := append(recValue.ResourceRequest.Containers, recValue.ResourceRequest.InitContainers...)
+	for _, c := range containers {
+		targets := corev1.ResourceList{}
+		for name, value := range c.Target {
+			if value == "" {
+				continue
+			}
+			q, err := resource.ParseQuantity(value)
+			if err == nil && !containerResourceSupported(name) {
+				err = fmt.Errorf("not a container resource")
+			}
+			if err != nil {
+				quantityErrs = append(quantityErrs, fmt.Errorf("container %s: invalid %s %q: %w", c.ContainerName, name, value, err))
+				if rec.InvalidContainers == nil {
+					rec.InvalidContainers = map[string]bool{}
+				}
+				rec.InvalidContainers[c.ContainerName] = true
+				continue
+			}
+			targets[name] = q
+		}
+		rec.Containers[c.ContainerName] = targets
+	}
+	return rec, quantityErrs
+}
+
+// parseControllerFields reads the target, completion strategy, status.recommendedInfo and the
+// applied changes of a Recommendation CR.
+func parseControllerFields(u *unstructured.Unstructured, rec *cachedRecommendation) {
+	labels := u.GetLabels()
+	rec.Cluster, _, _ = unstructured.NestedString(u.Object, "spec", "cluster")
+	rec.Target.APIVersion, _, _ = unstructured.NestedString(u.Object, "spec", "targetRef", "apiVersion")
+	rec.Target.Kind, _, _ = unstructured.NestedString(u.Object, "spec", "targetRef", "kind")
+	if rec.Target.Kind == "" {
+		rec.Target.Kind = labels["bcs.finops.io/recommendation-target-kind"]
+	}
+	rec.Target.Name, _, _ = unstructured.NestedString(u.Object, "spec", "targetRef", "name")
+	if rec.Target.Name == "" {
+		rec.Target.Name = labels["bcs.finops.io/recommendation-target-name"]
+	}
+	rec.TargetNamespace, _, _ = unstructured.NestedString(u.Object, "spec", "targetRef", "namespace")
+	if rec.TargetNamespace == "" {
+		rec.TargetNamespace = labels["bcs.finops.io/recommendation-target-namespace"]
+	}
+
+	rec.CompletionStrategy, _, _ = unstructured.NestedString(u.Object, "spec", "completionStrategy", "completionStrategyType")
+	if rec.CompletionStrategy == "" {
+		rec.CompletionStrategy = completionStrategyOnce
+	}
+	if seconds, found, _ := unstructured.NestedInt64(u.Object, "spec", "completionStrategy", "periodSeconds"); found {
+		rec.Period = time.Duration(seconds) * time.Second
+	}
+
+	if v := u.GetAnnotations()[AppliedChangesAnnotation]; v != "" {
+		if err := json.Unmarshal([]byte(v), &rec.Applied); err != nil {
+			// Treat it as never applied; the next change overwrites the annotation.
+			log.Printf("Recommendation %s/%s has an invalid %s annotation: %v", rec.Namespace, rec.Name, AppliedChangesAnnotation, err)
+			rec.Applied = nil
+		}
+	}
+
+	info, _, _ := unstructured.NestedString(u.Object, "status", "recommendedInfo")
+	if info == "" {
+		rec.TemplateErr = fmt.Errorf("status.recommendedInfo is empty")
+		return
+	}
+	var workload struct {
+		Spec struct {
+			Template corev1.PodTemplateSpec `json:"template"`
+		} `json:"spec"`
+	}
+	if err := json.Unmarshal([]byte(info), &workload); err != nil {
+		rec.TemplateErr = fmt.Errorf("unmarshal status.recommendedInfo: %w", err)
+		return
+	}
+	podSpec := workload.Spec.Template.Spec
+	rec.TemplateRequests = make(map[string]corev1.ResourceList)
+	for _, c := range append(podSpec.InitContainers, podSpec.Containers...) {
+		if len(c.Resources.Requests) > 0 {
+			rec.TemplateRequests[c.Name] = c.Resources.Requests
+		}
+	}
+}
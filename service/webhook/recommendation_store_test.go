@@ -0,0 +1,153 @@
+package webhook
+
+import (
+	"fmt"
+	"testing"
+
+	modelWebhook "main.go/model/webhook"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/yaml"
+)
+
+const benchmarkWorkloads = 1000
+
+// recommendationFixture builds one Recommendation CR per workload, shaped like doc/recommend_v2.yaml.
+func recommendationFixture(n int) []*unstructured.Unstructured {
+	objs := make([]*unstructured.Unstructured, 0, n)
+	for i := 0; i < n; i++ {
+		name := fmt.Sprintf("app-%d", i)
+		objs = append(objs, &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "bcs.finops.io/v1alpha1",
+			"kind":       "Recommendation",
+			"metadata": map[string]interface{}{
+				"name":            "recommend-" + name,
+				"namespace":       "bcs-finops-system",
+				"resourceVersion": fmt.Sprint(i + 1),
+				"generation":      int64(1),
+				"annotations": map[string]interface{}{
+					"bcs.finops.io/message": "Success",
+				},
+				"labels": map[string]interface{}{
+					"bcs.finops.io/recommendation-target-kind":      "Deployment",
+					"bcs.finops.io/recommendation-target-name":      name,
+					"bcs.finops.io/recommendation-target-namespace": "default",
+				},
+			},
+			"spec": map[string]interface{}{
+				"cluster":      "cluster-a",
+				"adoptionType": "Status",
+				"targetRef":    map[string]interface{}{"apiVersion": "apps/v1", "kind": "Deployment", "name": name, "namespace": "default"},
+			},
+			"status": map[string]interface{}{
+				"lastUpdateTime": "2026-02-26T09:00:30Z",
+				"recommendedValue": `resourceRequest:
+  containers:
+  - containerName: app
+    target:
+      cpu: 1265m
+      memory: 1150Mi
+  - containerName: agent
+    target:
+      cpu: 114m
+      memory: 230Mi
+`,
+			},
+		}})
+	}
+	return objs
+}
+
+// recommendationIndexer is the informer indexer with the targetWorkloadIndex InitK8s installs.
+func recommendationIndexer(tb testing.TB, objs []*unstructured.Unstructured) cache.Indexer {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{
+		targetWorkloadIndex: func(obj interface{}) ([]string, error) {
+			if key := recommendationWorkloadKey(obj.(*unstructured.Unstructured)); key != "" {
+				return []string{key}, nil
+			}
+			return nil, nil
+		},
+	})
+	for _, obj := range objs {
+		if err := indexer.Add(obj); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	return indexer
+}
+
+func recommendationStoreOf(objs []*unstructured.Unstructured) *recommendationStore {
+	store := newRecommendationStore()
+	handler := store.eventHandler()
+	for _, obj := range objs {
+		handler.OnAdd(obj, true)
+	}
+	return store
+}
+
+// BenchmarkRecommendationStoreLookup is the admission path: the CR was parsed by the informer handler.
+func BenchmarkRecommendationStoreLookup(b *testing.B) {
+	store := recommendationStoreOf(recommendationFixture(benchmarkWorkloads))
+	keys := fixtureKeys(benchmarkWorkloads)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		recs := store.forWorkload(keys[i%len(keys)])
+		if len(recs) != 1 || len(recs[0].Containers) != 2 {
+			b.Fatalf("unexpected lookup result: %v", recs)
+		}
+	}
+}
+
+// BenchmarkRecommendationIndexerParse is the former admission path: look the CR up in the informer
+// index and parse status.recommendedValue on every admission.
+func BenchmarkRecommendationIndexerParse(b *testing.B) {
+	indexer := recommendationIndexer(b, recommendationFixture(benchmarkWorkloads))
+	keys := fixtureKeys(benchmarkWorkloads)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		objs, err := indexer.ByIndex(targetWorkloadIndex, keys[i%len(keys)])
+		if err != nil || len(objs) != 1 {
+			b.Fatalf("unexpected index result: %v, %v", objs, err)
+		}
+		status, _, _ := unstructured.NestedMap(objs[0].(*unstructured.Unstructured).Object, "status")
+		var value modelWebhook.RecommendedValue
+		if err := yaml.Unmarshal([]byte(status["recommendedValue"].(string)), &value); err != nil {
+			b.Fatal(err)
+		}
+		if len(value.ResourceRequest.Containers) != 2 {
+			b.Fatalf("unexpected recommendedValue: %+v", value)
+		}
+	}
+}
+
+// TestRecommendationStoreMatchesIndexer makes sure both benchmarks look at the same workloads.
+func TestRecommendationStoreMatchesIndexer(t *testing.T) {
+	objs := recommendationFixture(10)
+	store := recommendationStoreOf(objs)
+	indexer := recommendationIndexer(t, objs)
+	for _, key := range fixtureKeys(10) {
+		indexed, err := indexer.ByIndex(targetWorkloadIndex, key)
+		if err != nil || len(indexed) != 1 {
+			t.Fatalf("%s: indexer returned %v, %v", key, indexed, err)
+		}
+		recs := store.forWorkload(key)
+		if len(recs) != 1 || recs[0].Name != indexed[0].(*unstructured.Unstructured).GetName() {
+			t.Fatalf("%s: store returned %v", key, recs)
+		}
+		if cpu := recs[0].Containers["app"][corev1.ResourceCPU]; cpu.String() != "1265m" {
+			t.Errorf("%s: app cpu = %s, want 1265m", key, cpu.String())
+		}
+	}
+}
+
+func fixtureKeys(n int) []string {
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = targetWorkloadIndexKey("cluster-a", "default", "Deployment", fmt.Sprintf("app-%d", i))
+	}
+	return keys
+}
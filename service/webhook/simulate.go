@@ -0,0 +1,86 @@
+package webhook
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+)
+
+// offlineGVRs maps the kinds LoadOffline accepts to the resource whose cache they fill.
+var offlineGVRs = map[schema.GroupKind]schema.GroupVersionResource{
+	{Group: recommendationGVR.Group, Kind: "Recommendation"}: recommendationGVR,
+	{Group: "apps", Kind: "ReplicaSet"}:                      intermediateOwnerGVRs[schema.GroupKind{Group: "apps", Kind: "ReplicaSet"}],
+	{Group: "batch", Kind: "Job"}:                            intermediateOwnerGVRs[schema.GroupKind{Group: "batch", Kind: "Job"}],
+	{Kind: "Namespace"}:                                      namespaceGVR,
+	{Kind: "LimitRange"}:                                     limitRangeGVR,
+	{Kind: "ResourceQuota"}:                                  resourceQuotaGVR,
+	{Group: hpaGVR.Group, Kind: "HorizontalPodAutoscaler"}:   hpaGVR,
+	{Group: vpaGVR.Group, Kind: "VerticalPodAutoscaler"}:     vpaGVR,
+	{Group: "apps", Kind: "Deployment"}:                      {Group: "apps", Version: "v1", Resource: "deployments"},
+	{Group: "apps", Kind: "StatefulSet"}:                     {Group: "apps", Version: "v1", Resource: "statefulsets"},
+	{Group: "apps", Kind: "DaemonSet"}:                       {Group: "apps", Version: "v1", Resource: "daemonsets"},
+	{Group: "batch", Kind: "CronJob"}:                        {Group: "batch", Version: "v1", Resource: "cronjobs"},
+}
+
+// LoadOffline fills the recommendation store and the caches of cluster clusterID from objects
+// instead of informers, so MutatePod runs without a cluster for the simulate command. Recommendation
+// CRs go to the store; ReplicaSets, Jobs, Deployments, StatefulSets, DaemonSets, CronJobs, Namespaces,
+// LimitRanges, ResourceQuotas, HPAs and VPAs to the cluster's caches. Live lookups are disabled, so an owner that is not given resolves to itself,
+// and writes such as HPA target scaling are skipped. Events go to events, which may be nil.
+func (s *RecommendationService) LoadOffline(clusterID string, objects []*unstructured.Unstructured, events record.EventRecorder) error {
+	newIndexer := func(indexers cache.Indexers) cache.Indexer {
+		indexers[cache.NamespaceIndex] = cache.MetaNamespaceIndexFunc
+		return cache.NewIndexer(cache.MetaNamespaceKeyFunc, indexers)
+	}
+	indexers := map[schema.GroupVersionResource]cache.Indexer{
+		namespaceGVR:     newIndexer(cache.Indexers{}),
+		limitRangeGVR:    newIndexer(cache.Indexers{}),
+		resourceQuotaGVR: newIndexer(cache.Indexers{}),
+		hpaGVR:           newIndexer(cache.Indexers{scaleTargetIndex: scaleTargetIndexFunc("scaleTargetRef")}),
+		vpaGVR:           newIndexer(cache.Indexers{scaleTargetIndex: scaleTargetIndexFunc("targetRef")}),
+	}
+	ownerIndexers := make(map[schema.GroupVersionResource]cache.Indexer, len(intermediateOwnerGVRs)+len(workloadMetadataGVRs))
+	for _, gvr := range intermediateOwnerGVRs {
+		ownerIndexers[gvr] = newIndexer(cache.Indexers{})
+		indexers[gvr] = ownerIndexers[gvr]
+	}
+	// Top-level workloads are cached for their finops.io/mutation annotation.
+	for _, gvr := range workloadMetadataGVRs {
+		ownerIndexers[gvr] = newIndexer(cache.Indexers{})
+		indexers[gvr] = ownerIndexers[gvr]
+	}
+
+	store := newRecommendationStore()
+	for _, obj := range objects {
+		gvk := obj.GroupVersionKind()
+		gvr, ok := offlineGVRs[gvk.GroupKind()]
+		if !ok {
+			return fmt.Errorf("%s %s: unsupported kind", gvk.Kind, obj.GetName())
+		}
+		if gvr == recommendationGVR {
+			store.upsert(obj)
+			continue
+		}
+		if err := indexers[gvr].Add(obj); err != nil {
+			return fmt.Errorf("%s %s: %w", gvk.Kind, obj.GetName(), err)
+		}
+	}
+
+	recommendationCache = store
+	memberClusters.set(&memberCluster{
+		ID:             clusterID,
+		Resolver:       NewOwnerResolver(nil, ownerIndexers),
+		Namespaces:     indexers[namespaceGVR],
+		HPAs:           indexers[hpaGVR],
+		VPAs:           indexers[vpaGVR],
+		LimitRanges:    indexers[limitRangeGVR],
+		ResourceQuotas: indexers[resourceQuotaGVR],
+		Events:         events,
+		ready:          true,
+	})
+	k8sStatus.setReady()
+	return nil
+}
@@ -0,0 +1,38 @@
+package webhook
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestLoadOfflineWorkloadMutationMode(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		annotations map[string]interface{}
+		skip        string
+	}{
+		{"no annotation", nil, ""},
+		{"workload opted out", map[string]interface{}{MutationAnnotation: string(MutationDisabled)}, SkipReasonMutationDisabled},
+		{"workload in dry run", map[string]interface{}{MutationAnnotation: string(MutationDryRun)}, SkipReasonDryRun},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			metadata := map[string]interface{}{"name": "app-0", "namespace": "default"}
+			if tc.annotations != nil {
+				metadata["annotations"] = tc.annotations
+			}
+			loadQoSCluster(t, string(QoSPolicyIgnore), &unstructured.Unstructured{Object: map[string]interface{}{
+				"apiVersion": "apps/v1", "kind": "Deployment", "metadata": metadata,
+			}})
+
+			_, skip, patches := admit(t, deploymentPod(corev1.ResourceRequirements{Requests: resources("cpu", "2", "memory", "2Gi")}))
+			if skip != tc.skip {
+				t.Errorf("skip reason = %q, want %q", skip, tc.skip)
+			}
+			if tc.skip == "" && patches == 0 {
+				t.Error("pod not patched")
+			}
+		})
+	}
+}
@@ -56,6 +56,8 @@ CREATE TABLE `prometheus_alert` (
   `daily_notify_count` int(11) NOT NULL DEFAULT 0 COMMENT '当日通知次数',
   `last_notify_date` date DEFAULT NULL COMMENT '最后通知日期',
   `notify_pending` tinyint(1) NOT NULL DEFAULT 0 COMMENT '是否有待发送的通知',
+  `notify_result` json DEFAULT NULL COMMENT '各通知渠道最近一次发送结果(JSON格式)',
+  `silence_id` int(11) NOT NULL DEFAULT 0 COMMENT '最近一次抑制通知的静默ID, 0为未被静默',
   `is_deleted` tinyint(4) NOT NULL DEFAULT '0' COMMENT '删除标识字段(0-未删除 1-已删除)',
   `create_time` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
   `update_time` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '修改时间',
@@ -65,6 +67,102 @@ CREATE TABLE `prometheus_alert` (
   UNIQUE KEY `uq_fingerprint_not_deleted` (`fingerprint`, `is_deleted`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 ROW_FORMAT=DYNAMIC COMMENT='告警信息表';
 
+-- ----------------------------
+-- 准入决策审计表
+-- ----------------------------
+DROP TABLE IF EXISTS `admission_audit`;
+
+CREATE TABLE `admission_audit` (
+  `audit_id` bigint(20) NOT NULL AUTO_INCREMENT COMMENT '审计ID',
+  `admission_uid` varchar(64) NOT NULL DEFAULT '' COMMENT 'AdmissionReview UID',
+  `cluster` varchar(128) NOT NULL DEFAULT '' COMMENT '集群ID',
+  `namespace` varchar(128) NOT NULL DEFAULT '' COMMENT '命名空间',
+  `workload_kind` varchar(64) NOT NULL DEFAULT '' COMMENT '工作负载类型',
+  `workload_name` varchar(255) NOT NULL DEFAULT '' COMMENT '工作负载名称',
+  `pod_generate_name` varchar(255) NOT NULL DEFAULT '' COMMENT 'Pod generateName',
+  `container` varchar(255) NOT NULL DEFAULT '' COMMENT '容器名称, Pod级跳过时为空',
+  `container_kind` varchar(32) NOT NULL DEFAULT '' COMMENT '容器类型(container/init/sidecar)',
+  `mutation_mode` varchar(32) NOT NULL DEFAULT '' COMMENT '变更模式(enabled/disabled/dry-run)',
+  `decision` varchar(32) NOT NULL DEFAULT '' COMMENT '决策(patched/skipped)',
+  `skip_reason` varchar(64) NOT NULL DEFAULT '' COMMENT '跳过原因',
+  `old_cpu` varchar(32) NOT NULL DEFAULT '' COMMENT '原CPU request',
+  `new_cpu` varchar(32) NOT NULL DEFAULT '' COMMENT '新CPU request',
+  `old_memory` varchar(32) NOT NULL DEFAULT '' COMMENT '原内存 request',
+  `new_memory` varchar(32) NOT NULL DEFAULT '' COMMENT '新内存 request',
+  `recommendation_name` varchar(255) NOT NULL DEFAULT '' COMMENT 'Recommendation CR 名称',
+  `recommendation_resource_version` varchar(64) NOT NULL DEFAULT '' COMMENT 'Recommendation CR resourceVersion',
+  `create_time` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '准入时间',
+  PRIMARY KEY (`audit_id`) USING BTREE,
+  KEY `idx_create_time` (`create_time`) USING BTREE,
+  KEY `idx_workload` (`cluster`, `namespace`, `workload_kind`, `workload_name`) USING BTREE,
+  KEY `idx_decision` (`decision`, `skip_reason`) USING BTREE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 ROW_FORMAT=DYNAMIC COMMENT='准入决策审计表';
+
+-- ----------------------------
+-- 告警路由表
+-- ----------------------------
+DROP TABLE IF EXISTS `alert_route`;
+
+CREATE TABLE `alert_route` (
+  `route_id` int(11) NOT NULL AUTO_INCREMENT COMMENT '路由ID',
+  `parent_id` int(11) NOT NULL DEFAULT 0 COMMENT '父路由ID, 0为顶层路由',
+  `name` varchar(128) NOT NULL DEFAULT '' COMMENT '路由名称',
+  `matchers` json DEFAULT NULL COMMENT '标签匹配器(JSON格式)',
+  `channels` json DEFAULT NULL COMMENT '通知渠道, 为空继承父路由(JSON格式)',
+  `receivers` json DEFAULT NULL COMMENT '接收人, 为空继承父路由(JSON格式)',
+  `continue_matching` tinyint(1) NOT NULL DEFAULT 0 COMMENT '命中后是否继续匹配同级路由',
+  `sort_order` int(11) NOT NULL DEFAULT 0 COMMENT '同级路由匹配顺序(升序)',
+  `is_deleted` tinyint(4) NOT NULL DEFAULT '0' COMMENT '删除标识字段(0-未删除 1-已删除)',
+  `create_time` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+  `update_time` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '修改时间',
+  PRIMARY KEY (`route_id`) USING BTREE,
+  KEY `idx_parent` (`parent_id`, `is_deleted`) USING BTREE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 ROW_FORMAT=DYNAMIC COMMENT='告警路由表';
+
+-- ----------------------------
+-- 告警通知发件箱表
+-- ----------------------------
+DROP TABLE IF EXISTS `alert_notify_outbox`;
+
+CREATE TABLE `alert_notify_outbox` (
+  `outbox_id` bigint(20) NOT NULL AUTO_INCREMENT COMMENT '记录ID',
+  `alert_id` int(11) NOT NULL COMMENT '告警ID',
+  `route_id` int(11) NOT NULL DEFAULT 0 COMMENT '命中的告警路由, 0为默认路由',
+  `channel` varchar(32) NOT NULL DEFAULT '' COMMENT '通知渠道',
+  `receivers` json DEFAULT NULL COMMENT '接收人, 为空表示渠道默认接收人(JSON格式)',
+  `status` varchar(16) NOT NULL DEFAULT 'pending' COMMENT '状态(pending/sending/sent/dead)',
+  `attempts` int(11) NOT NULL DEFAULT 0 COMMENT '已尝试次数',
+  `next_attempt_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '下次尝试时间',
+  `locked_by` varchar(128) NOT NULL DEFAULT '' COMMENT '领取该记录的实例',
+  `locked_until` datetime DEFAULT NULL COMMENT '领取租约到期时间',
+  `last_error` varchar(1024) NOT NULL DEFAULT '' COMMENT '最近一次失败原因',
+  `sent_time` datetime DEFAULT NULL COMMENT '发送成功时间',
+  `create_time` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+  `update_time` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '修改时间',
+  PRIMARY KEY (`outbox_id`) USING BTREE,
+  KEY `idx_status_next_attempt` (`status`, `next_attempt_at`) USING BTREE,
+  KEY `idx_alert` (`alert_id`) USING BTREE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 ROW_FORMAT=DYNAMIC COMMENT='告警通知发件箱表';
+
+-- ----------------------------
+-- 告警静默表
+-- ----------------------------
+DROP TABLE IF EXISTS `alert_silence`;
+
+CREATE TABLE `alert_silence` (
+  `silence_id` int(11) NOT NULL AUTO_INCREMENT COMMENT '静默ID',
+  `matchers` json DEFAULT NULL COMMENT '标签匹配器(JSON格式)',
+  `starts_at` datetime NOT NULL COMMENT '开始时间',
+  `ends_at` datetime NOT NULL COMMENT '结束时间',
+  `created_by` varchar(64) NOT NULL DEFAULT '' COMMENT '创建人',
+  `comment` varchar(512) NOT NULL DEFAULT '' COMMENT '静默原因',
+  `is_deleted` tinyint(4) NOT NULL DEFAULT '0' COMMENT '删除标识字段(0-未删除 1-已删除)',
+  `create_time` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+  `update_time` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '修改时间',
+  PRIMARY KEY (`silence_id`) USING BTREE,
+  KEY `idx_window` (`is_deleted`, `starts_at`, `ends_at`) USING BTREE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 ROW_FORMAT=DYNAMIC COMMENT='告警静默表';
+
 -- ----------------------------
 -- 唯一约束升级脚本 (用于已存在的数据库升级)
 -- ----------------------------
@@ -106,4 +204,16 @@ CREATE TABLE `exa_file_upload_and_downloads` (
 -- ALTER TABLE `prometheus_alert`
 -- ADD COLUMN `notify_pending` tinyint(1) NOT NULL DEFAULT 0 COMMENT '是否有待发送的通知' AFTER `last_notify_date`;
 
+-- ----------------------------
+-- 多渠道通知结果字段 (用于已存在的数据库升级)
+-- ----------------------------
+-- ALTER TABLE `prometheus_alert`
+-- ADD COLUMN `notify_result` json DEFAULT NULL COMMENT '各通知渠道最近一次发送结果(JSON格式)' AFTER `notify_pending`;
+
+-- ----------------------------
+-- 静默字段 (用于已存在的数据库升级)
+-- ----------------------------
+-- ALTER TABLE `prometheus_alert`
+-- ADD COLUMN `silence_id` int(11) NOT NULL DEFAULT 0 COMMENT '最近一次抑制通知的静默ID, 0为未被静默' AFTER `notify_result`;
+
 SET FOREIGN_KEY_CHECKS = 1;